@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/nimajalali/go-force/force"
+)
+
+// generatedHeader starts every generated file.
+const generatedHeader = "// Code generated by force-gen. DO NOT EDIT."
+
+// Fields of sobjects.BaseSObject, which every generated struct embeds.
+var baseFields = map[string]bool{
+	"Id":               true,
+	"IsDeleted":        true,
+	"Name":             true,
+	"CreatedDate":      true,
+	"CreatedById":      true,
+	"LastModifiedDate": true,
+	"LastModifiedById": true,
+	"SystemModstamp":   true,
+}
+
+// generator renders Go source for a set of sobject descriptions.
+type generator struct {
+	pkg     string
+	objects map[string]*force.SObjectDescription
+}
+
+func newGenerator(pkg string, descriptions []*force.SObjectDescription) *generator {
+	objects := map[string]*force.SObjectDescription{}
+	for _, description := range descriptions {
+		objects[description.Name] = description
+	}
+
+	return &generator{pkg: pkg, objects: objects}
+}
+
+// generate returns the formatted source of every object keyed by file name.
+func (gen *generator) generate() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, name := range gen.objectNames() {
+		src, err := gen.generateObject(gen.objects[name])
+		if err != nil {
+			return nil, fmt.Errorf("Error generating %v: %v", name, err)
+		}
+
+		files[strings.ToLower(name)+".go"] = src
+	}
+
+	return files, nil
+}
+
+func (gen *generator) objectNames() []string {
+	names := make([]string, 0, len(gen.objects))
+	for name := range gen.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (gen *generator) generateObject(description *force.SObjectDescription) ([]byte, error) {
+	typeName := goName(description.Name)
+	fields := sortedFields(description.Fields)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%v\n\n", generatedHeader)
+	fmt.Fprintf(&buf, "package %v\n\n", gen.pkg)
+
+	imports := []string{}
+	if gen.pkg != "sobjects" {
+		imports = append(imports, `"github.com/nimajalali/go-force/sobjects"`)
+	}
+	if len(imports) > 0 {
+		fmt.Fprintf(&buf, "import (\n%v\n)\n\n", strings.Join(imports, "\n"))
+	}
+
+	if description.Label != "" {
+		fmt.Fprintf(&buf, "// %v is the %v object.\n", typeName, description.Label)
+	}
+	fmt.Fprintf(&buf, "type %v struct {\n", typeName)
+	fmt.Fprintf(&buf, "%vBaseSObject\n", gen.qualifier())
+	for _, field := range fields {
+		if baseFields[field.Name] {
+			continue
+		}
+
+		goType, ok := gen.goType(field)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&buf, "%v %v `force:\"%v,omitempty\"`\n", goName(field.Name), goType, field.Name)
+
+		if related := gen.relationship(field); related != "" {
+			fmt.Fprintf(&buf, "%v *%v `force:\"%v,omitempty\"`\n", goName(field.RelationshipName), goName(related), field.RelationshipName)
+		}
+	}
+	fmt.Fprintf(&buf, "}\n\n")
+
+	fmt.Fprintf(&buf, "func (t *%v) ApiName() string {\n\treturn %q\n}\n\n", typeName, description.Name)
+
+	if externalId := externalIdField(fields); externalId != "" {
+		fmt.Fprintf(&buf, "func (t *%v) ExternalIdApiName() string {\n\treturn %q\n}\n\n", typeName, externalId)
+	}
+
+	for _, field := range fields {
+		writePicklistConstants(&buf, typeName, field)
+	}
+
+	fmt.Fprintf(&buf, "type %vQueryResponse struct {\n", typeName)
+	fmt.Fprintf(&buf, "%vBaseQuery\n", gen.qualifier())
+	fmt.Fprintf(&buf, "Records []%v `json:\"Records\" force:\"records\"`\n", typeName)
+	fmt.Fprintf(&buf, "}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Error formatting generated source: %v\n%s", err, buf.Bytes())
+	}
+
+	return src, nil
+}
+
+func (gen *generator) qualifier() string {
+	if gen.pkg == "sobjects" {
+		return ""
+	}
+
+	return "sobjects."
+}
+
+// goType maps a field to a Go type. Booleans and numbers are pointers, so
+// that fields left unset are not sent while false and 0 still can be;
+// Salesforce treats an empty string as null. Compound address and location
+// fields are skipped, as their components are separate fields.
+func (gen *generator) goType(field *force.SObjectField) (string, bool) {
+	switch field.Type {
+	case "boolean":
+		return "*bool", true
+	case "int":
+		return "*int64", true
+	case "double", "currency", "percent":
+		return "*float64", true
+	case "date", "datetime":
+		return "*" + gen.qualifier() + "Time", true
+	case "address", "location":
+		return "", false
+	case "anyType", "complexvalue":
+		return "interface{}", true
+	default:
+		return "string", true
+	}
+}
+
+// relationship returns the object a reference field points to if a struct is
+// generated for it, so that related records can be queried and set.
+func (gen *generator) relationship(field *force.SObjectField) string {
+	if field.Type != "reference" || field.RelationshipName == "" || len(field.ReferenceTo) != 1 {
+		return ""
+	}
+	if _, ok := gen.objects[field.ReferenceTo[0]]; !ok {
+		return ""
+	}
+
+	return field.ReferenceTo[0]
+}
+
+// externalIdField returns the first external id field by name, if any.
+func externalIdField(fields []*force.SObjectField) string {
+	for _, field := range fields {
+		if field.ExternalId {
+			return field.Name
+		}
+	}
+
+	return ""
+}
+
+func writePicklistConstants(buf *bytes.Buffer, typeName string, field *force.SObjectField) {
+	if field.Type != "picklist" && field.Type != "multipicklist" {
+		return
+	}
+
+	values := []*force.PicklistValue{}
+	for _, value := range field.PicklistValues {
+		if value.Active {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	prefix := typeName + "_" + goName(field.Name) + "_"
+	seen := map[string]int{}
+
+	fmt.Fprintf(buf, "// Values of the %v picklist.\n", field.Name)
+	fmt.Fprintf(buf, "const (\n")
+	for _, value := range values {
+		name := prefix + identifier(value.Value)
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%v_%d", name, seen[name])
+		}
+		fmt.Fprintf(buf, "%v = %q\n", name, value.Value)
+	}
+	fmt.Fprintf(buf, ")\n\n")
+}
+
+func sortedFields(fields []*force.SObjectField) []*force.SObjectField {
+	sorted := make([]*force.SObjectField, len(fields))
+	copy(sorted, fields)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// goName returns an exported Go identifier for an api name, which is already
+// made of letters, digits and underscores.
+func goName(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+
+	return string(runes)
+}
+
+// identifier turns a picklist value into a Go identifier suffix, replacing
+// runs of other characters with an underscore.
+func identifier(value string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range value {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+
+	s := strings.TrimSuffix(b.String(), "_")
+	if s == "" {
+		return "Empty"
+	}
+
+	return s
+}
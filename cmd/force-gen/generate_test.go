@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerate(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("No describe fixtures: %v", err)
+	}
+
+	descriptions, err := readDescriptions(paths)
+	if err != nil {
+		t.Fatalf("Failed to read describes: %v", err)
+	}
+
+	files, err := newGenerator("sobjects", descriptions).generate()
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if len(files) != len(paths) {
+		t.Fatalf("Expected %d files, got %d", len(paths), len(files))
+	}
+
+	for name, src := range files {
+		golden := filepath.Join("testdata", "golden", name)
+		if *update {
+			if err := ioutil.WriteFile(golden, src, 0644); err != nil {
+				t.Fatalf("Failed to update %v: %v", golden, err)
+			}
+			continue
+		}
+
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("Failed to read %v: %v", golden, err)
+		}
+		if !bytes.Equal(src, want) {
+			t.Errorf("Generated %v differs from %v:\n%s", name, golden, src)
+		}
+	}
+
+	// The output must not depend on the order of the input.
+	reversed := make([]string, len(paths))
+	for i, path := range paths {
+		reversed[len(paths)-1-i] = path
+	}
+	descriptions, _ = readDescriptions(reversed)
+	again, err := newGenerator("sobjects", descriptions).generate()
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	for name, src := range again {
+		if !bytes.Equal(src, files[name]) {
+			t.Errorf("Generated %v differs between runs", name)
+		}
+	}
+}
+
+func TestGenerateOtherPackage(t *testing.T) {
+	descriptions, err := readDescriptions([]string{"testdata/Invoice__c.json"})
+	if err != nil {
+		t.Fatalf("Failed to read describes: %v", err)
+	}
+
+	files, err := newGenerator("models", descriptions).generate()
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+
+	src := string(files["invoice__c.go"])
+	for _, want := range []string{
+		"package models",
+		`"github.com/nimajalali/go-force/sobjects"`,
+		"sobjects.BaseSObject",
+		"*sobjects.Time",
+		"sobjects.BaseQuery",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Expected %q in:\n%v", want, src)
+		}
+	}
+	if strings.Contains(src, "Account__r") {
+		t.Errorf("Expected no relationship to an object that was not generated:\n%v", src)
+	}
+}
@@ -0,0 +1,312 @@
+// Command force-gen generates Go sobject structs from describe metadata.
+//
+// Objects are described either live:
+//
+//	force-gen -objects Account,Invoice__c -out ./salesforce
+//
+// authenticating with the FORCE_* environment variables or the matching
+// flags, or offline from describe JSON saved with -save:
+//
+//	force-gen -describe describe/Account.json,describe/Invoice__c.json -out ./salesforce
+//
+// One file is written per object, holding a struct embedding
+// sobjects.BaseSObject, its ApiName and ExternalIdApiName methods, constants
+// for active picklist values and a QueryResponse wrapper. Fields and objects
+// are sorted by name, so the output only changes with the metadata.
+// Reference fields get a relationship struct when the referenced object is
+// generated in the same run.
+//
+// Files in the output directory that were not generated by force-gen are
+// never overwritten, and generation fails if the package in the output
+// directory already declares one of the generated names, such as the
+// hand-written types of package sobjects.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nimajalali/go-force/force"
+)
+
+type config struct {
+	objects  string
+	describe string
+	save     string
+	out      string
+	pkg      string
+
+	version       string
+	clientId      string
+	clientSecret  string
+	userName      string
+	password      string
+	securityToken string
+	environment   string
+	accessToken   string
+	instanceUrl   string
+}
+
+func main() {
+	cfg := &config{}
+	flag.StringVar(&cfg.objects, "objects", "", "comma separated api names of the objects to describe live")
+	flag.StringVar(&cfg.describe, "describe", "", "comma separated describe JSON files to read instead of describing live")
+	flag.StringVar(&cfg.save, "save", "", "directory to save the describe JSON of live described objects to")
+	flag.StringVar(&cfg.out, "out", ".", "directory to write the generated files to")
+	flag.StringVar(&cfg.pkg, "package", "salesforce", "package name of the generated files")
+	flag.StringVar(&cfg.version, "version", env("FORCE_VERSION", "v36.0"), "api version")
+	flag.StringVar(&cfg.clientId, "client-id", os.Getenv("FORCE_CLIENT_ID"), "connected app client id")
+	flag.StringVar(&cfg.clientSecret, "client-secret", os.Getenv("FORCE_CLIENT_SECRET"), "connected app client secret")
+	flag.StringVar(&cfg.userName, "username", os.Getenv("FORCE_USERNAME"), "user name")
+	flag.StringVar(&cfg.password, "password", os.Getenv("FORCE_PASSWORD"), "password")
+	flag.StringVar(&cfg.securityToken, "security-token", os.Getenv("FORCE_SECURITY_TOKEN"), "security token")
+	flag.StringVar(&cfg.environment, "environment", env("FORCE_ENVIRONMENT", "production"), "production or sandbox")
+	flag.StringVar(&cfg.accessToken, "access-token", os.Getenv("FORCE_ACCESS_TOKEN"), "access token to use instead of logging in")
+	flag.StringVar(&cfg.instanceUrl, "instance-url", os.Getenv("FORCE_INSTANCE_URL"), "instance url of the access token")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "force-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config) error {
+	var descriptions []*force.SObjectDescription
+	var err error
+	switch {
+	case cfg.describe != "" && cfg.objects != "":
+		return fmt.Errorf("-describe and -objects are mutually exclusive")
+	case cfg.describe != "":
+		descriptions, err = readDescriptions(split(cfg.describe))
+	case cfg.objects != "":
+		descriptions, err = describe(cfg, split(cfg.objects))
+	default:
+		return fmt.Errorf("One of -describe or -objects is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	if cfg.save != "" {
+		if err := saveDescriptions(cfg.save, descriptions); err != nil {
+			return err
+		}
+	}
+
+	files, err := newGenerator(cfg.pkg, descriptions).generate()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOutput(cfg.out, cfg.pkg, files); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cfg.out, 0755); err != nil {
+		return fmt.Errorf("Error creating output directory: %v", err)
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(cfg.out, name), src, 0644); err != nil {
+			return fmt.Errorf("Error writing %v: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// checkOutput makes sure that files can be written to dir without
+// overwriting hand-written files or redeclaring names of the package there.
+func checkOutput(dir, pkg string, files map[string][]byte) error {
+	existing, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return fmt.Errorf("Error listing output directory: %v", err)
+	}
+
+	declared := map[string]string{}
+	fset := token.NewFileSet()
+	for _, path := range existing {
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("Error reading %v: %v", path, err)
+		}
+
+		if _, ok := files[name]; ok {
+			if !bytes.HasPrefix(src, []byte(generatedHeader)) {
+				return fmt.Errorf("Refusing to overwrite %v, which was not generated by force-gen", path)
+			}
+			// Replaced by this run.
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, path, src, 0)
+		if err != nil {
+			return fmt.Errorf("Error parsing %v: %v", path, err)
+		}
+		if file.Name.Name != pkg {
+			return fmt.Errorf("%v is in package %v, not %v; set -package to match", path, file.Name.Name, pkg)
+		}
+		for _, name := range declaredNames(file) {
+			declared[name] = path
+		}
+	}
+
+	for name, src := range files {
+		file, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil {
+			return fmt.Errorf("Error parsing generated %v: %v", name, err)
+		}
+		for _, declaredName := range declaredNames(file) {
+			if path, ok := declared[declaredName]; ok {
+				return fmt.Errorf("%v is already declared in %v", declaredName, path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// declaredNames returns the package level names declared in file, with
+// methods named as Type.Method.
+func declaredNames(file *ast.File) []string {
+	names := []string{}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			name := decl.Name.Name
+			if decl.Recv != nil && len(decl.Recv.List) == 1 {
+				recv := decl.Recv.List[0].Type
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv = star.X
+				}
+				if ident, ok := recv.(*ast.Ident); ok {
+					name = ident.Name + "." + name
+				}
+			}
+			names = append(names, name)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, spec.Name.Name)
+				case *ast.ValueSpec:
+					for _, ident := range spec.Names {
+						names = append(names, ident.Name)
+					}
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+// apiName names an object to describe.
+type apiName string
+
+func (name apiName) ApiName() string {
+	return string(name)
+}
+
+func (name apiName) ExternalIdApiName() string {
+	return ""
+}
+
+func describe(cfg *config, objects []string) ([]*force.SObjectDescription, error) {
+	var forceApi *force.ForceApi
+	var err error
+	if cfg.accessToken != "" {
+		forceApi, err = force.CreateWithAccessToken(cfg.version, cfg.clientId, cfg.accessToken, cfg.instanceUrl)
+	} else {
+		forceApi, err = force.Create(cfg.version, cfg.clientId, cfg.clientSecret, cfg.userName, cfg.password,
+			cfg.securityToken, cfg.environment)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions := make([]*force.SObjectDescription, 0, len(objects))
+	for _, object := range objects {
+		description, err := forceApi.DescribeSObject(apiName(object))
+		if err != nil {
+			return nil, fmt.Errorf("Error describing %v: %v", object, err)
+		}
+		descriptions = append(descriptions, description)
+	}
+
+	return descriptions, nil
+}
+
+func readDescriptions(paths []string) ([]*force.SObjectDescription, error) {
+	descriptions := make([]*force.SObjectDescription, 0, len(paths))
+	for _, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading describe: %v", err)
+		}
+
+		description := &force.SObjectDescription{}
+		if err := json.Unmarshal(contents, description); err != nil {
+			return nil, fmt.Errorf("Error reading describe %v: %v", path, err)
+		}
+		if description.Name == "" {
+			return nil, fmt.Errorf("Error reading describe %v: missing object name", path)
+		}
+		descriptions = append(descriptions, description)
+	}
+
+	return descriptions, nil
+}
+
+func saveDescriptions(dir string, descriptions []*force.SObjectDescription) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error creating describe directory: %v", err)
+	}
+
+	for _, description := range descriptions {
+		contents, err := json.MarshalIndent(description, "", "  ")
+		if err != nil {
+			return fmt.Errorf("Error marshaling describe of %v: %v", description.Name, err)
+		}
+
+		path := filepath.Join(dir, description.Name+".json")
+		if err := ioutil.WriteFile(path, append(contents, '\n'), 0644); err != nil {
+			return fmt.Errorf("Error writing describe: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func split(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func env(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
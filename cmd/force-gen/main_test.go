@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunOutput(t *testing.T) {
+	out := t.TempDir()
+	cfg := &config{describe: "testdata/Account.json,testdata/Invoice__c.json", out: out, pkg: "salesforce"}
+
+	// Generated files are replaced on the next run.
+	for i := 0; i < 2; i++ {
+		if err := run(cfg); err != nil {
+			t.Fatalf("Failed to generate: %v", err)
+		}
+	}
+	if src, err := ioutil.ReadFile(filepath.Join(out, "invoice__c.go")); err != nil || !strings.Contains(string(src), "package salesforce") {
+		t.Fatalf("Unexpected generated file: %s %v", src, err)
+	}
+}
+
+func TestRunOutputConflicts(t *testing.T) {
+	// The hand-written types of package sobjects, as in the example of
+	// generating into ./sobjects.
+	sobjects, err := filepath.Glob("../../sobjects/*.go")
+	if err != nil || len(sobjects) == 0 {
+		t.Fatalf("No sobjects sources: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		pkg   string
+		err   string
+	}{
+		{"hand-written file", map[string]string{"account.go": "package salesforce\n"}, "salesforce", "Refusing to overwrite"},
+		{"declared type", map[string]string{"types.go": "package salesforce\n\ntype Account struct{}\n"}, "salesforce", "Account is already declared"},
+		{"declared method", map[string]string{"types.go": "package salesforce\n\nfunc (t Invoice__c) ApiName() string { return \"\" }\n"}, "salesforce", "Invoice__c.ApiName is already declared"},
+		{"other package", map[string]string{"doc.go": "package models\n"}, "salesforce", "set -package"},
+		{"sobjects", nil, "sobjects", "Refusing to overwrite"},
+	}
+
+	for _, test := range tests {
+		out := t.TempDir()
+		files := test.files
+		if files == nil {
+			files = map[string]string{}
+			for _, path := range sobjects {
+				src, err := ioutil.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Failed to read %v: %v", path, err)
+				}
+				files[filepath.Base(path)] = string(src)
+			}
+		}
+		for name, src := range files {
+			if err := ioutil.WriteFile(filepath.Join(out, name), []byte(src), 0644); err != nil {
+				t.Fatalf("Failed to write %v: %v", name, err)
+			}
+		}
+
+		err := run(&config{describe: "testdata/Account.json,testdata/Invoice__c.json", out: out, pkg: test.pkg})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("%v: expected %q, got %v", test.name, test.err, err)
+		}
+
+		for name, src := range files {
+			if written, _ := ioutil.ReadFile(filepath.Join(out, name)); string(written) != src {
+				t.Fatalf("%v: %v was modified", test.name, name)
+			}
+		}
+		if generated, _ := filepath.Glob(filepath.Join(out, "invoice__c.go")); len(generated) != 0 {
+			t.Fatalf("%v: files were generated", test.name)
+		}
+	}
+}
+
+// roundTripTest inserts generated structs into a fake server described by the
+// same describe JSON, checking what the server stored.
+const roundTripTest = `package salesforce
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/nimajalali/go-force/force"
+	"github.com/nimajalali/go-force/forcetest"
+)
+
+func describedSObject(t *testing.T, path string) forcetest.SObject {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	description := &force.SObjectDescription{}
+	if err := json.Unmarshal(src, description); err != nil {
+		t.Fatal(err)
+	}
+
+	object := forcetest.SObject{Name: description.Name, Custom: description.Custom}
+	for _, field := range description.Fields {
+		if field.Type == "id" {
+			continue
+		}
+		object.Fields = append(object.Fields, forcetest.Field{
+			Name:             field.Name,
+			Type:             field.Type,
+			Nillable:         field.Nillable,
+			ExternalId:       field.ExternalId,
+			ReferenceTo:      field.ReferenceTo,
+			RelationshipName: field.RelationshipName,
+		})
+	}
+
+	return object
+}
+
+func TestInsert(t *testing.T) {
+	server := forcetest.NewServer(describedSObject(t, %q), describedSObject(t, %q))
+	defer server.Close()
+	accountId := server.Seed("Account", forcetest.Record{"Name": "Acme"})[0]
+
+	forceApi, err := force.CreateWithAccessToken("v36.0", "client", server.AccessToken(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paid, discount := false, 0.0
+	resp, err := forceApi.InsertSObject(&Invoice__c{Account__c: accountId, Paid__c: &paid, Discount__c: &discount}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, _ := server.Record("Invoice__c", resp.Id)
+	if record["Account__c"] != accountId || record["Paid__c"] != false || record["Discount__c"] != 0.0 {
+		t.Fatalf("Unexpected record: %%v", record)
+	}
+}
+`
+
+func TestRunRoundTrip(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil || testing.Short() {
+		t.Skip("go tool not available")
+	}
+
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	describe := []string{filepath.Join(root, "cmd/force-gen/testdata/Account.json"), filepath.Join(root, "cmd/force-gen/testdata/Invoice__c.json")}
+
+	// Build the generated package in a module that uses this one.
+	dir := t.TempDir()
+	goMod := fmt.Sprintf("module roundtrip\n\ngo 1.18\n\nrequire github.com/nimajalali/go-force v0.0.0\n\nreplace github.com/nimajalali/go-force => %v\n", root)
+	goSum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":                       goMod,
+		"go.sum":                       string(goSum),
+		"salesforce/roundtrip_test.go": fmt.Sprintf(roundTripTest, describe[0], describe[1]),
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := run(&config{describe: strings.Join(describe, ","), out: filepath.Join(dir, "salesforce"), pkg: "salesforce"}); err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+
+	cmd := exec.Command(goTool, "test", "./salesforce")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Generated structs failed to round trip: %v\n%s", err, out)
+	}
+}
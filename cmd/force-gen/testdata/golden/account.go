@@ -0,0 +1,30 @@
+// Code generated by force-gen. DO NOT EDIT.
+
+package sobjects
+
+// Account is the Account object.
+type Account struct {
+	BaseSObject
+	AnnualRevenue     *float64 `force:"AnnualRevenue,omitempty"`
+	BillingCity       string   `force:"BillingCity,omitempty"`
+	Industry          string   `force:"Industry,omitempty"`
+	NumberOfEmployees *int64   `force:"NumberOfEmployees,omitempty"`
+	OwnerId           string   `force:"OwnerId,omitempty"`
+	ParentId          string   `force:"ParentId,omitempty"`
+	Parent            *Account `force:"Parent,omitempty"`
+}
+
+func (t *Account) ApiName() string {
+	return "Account"
+}
+
+// Values of the Industry picklist.
+const (
+	Account_Industry_Banking         = "Banking"
+	Account_Industry_Retail_Consumer = "Retail & Consumer"
+)
+
+type AccountQueryResponse struct {
+	BaseQuery
+	Records []Account `json:"Records" force:"records"`
+}
@@ -0,0 +1,46 @@
+// Code generated by force-gen. DO NOT EDIT.
+
+package sobjects
+
+// Invoice__c is the Invoice object.
+type Invoice__c struct {
+	BaseSObject
+	Account__c     string   `force:"Account__c,omitempty"`
+	Account__r     *Account `force:"Account__r,omitempty"`
+	Amount__c      *float64 `force:"Amount__c,omitempty"`
+	Discount__c    *float64 `force:"Discount__c,omitempty"`
+	Due_Date__c    *Time    `force:"Due_Date__c,omitempty"`
+	External_Id__c string   `force:"External_Id__c,omitempty"`
+	Lines__c       *float64 `force:"Lines__c,omitempty"`
+	Paid__c        *bool    `force:"Paid__c,omitempty"`
+	Status__c      string   `force:"Status__c,omitempty"`
+	Tags__c        string   `force:"Tags__c,omitempty"`
+	What__c        string   `force:"What__c,omitempty"`
+}
+
+func (t *Invoice__c) ApiName() string {
+	return "Invoice__c"
+}
+
+func (t *Invoice__c) ExternalIdApiName() string {
+	return "External_Id__c"
+}
+
+// Values of the Status__c picklist.
+const (
+	Invoice__c_Status__c_Draft        = "Draft"
+	Invoice__c_Status__c_Sent         = "Sent"
+	Invoice__c_Status__c_Paid_in_full = "Paid in full"
+)
+
+// Values of the Tags__c picklist.
+const (
+	Invoice__c_Tags__c_2026  = "2026"
+	Invoice__c_Tags__c_Q_1   = "Q-1"
+	Invoice__c_Tags__c_Q_1_2 = "Q 1"
+)
+
+type Invoice__cQueryResponse struct {
+	BaseQuery
+	Records []Invoice__c `json:"Records" force:"records"`
+}
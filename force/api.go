@@ -1,7 +1,10 @@
 package force
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"sync"
 )
 
 const (
@@ -18,6 +21,9 @@ const (
 	resourcesUri = "/services/data/%v"
 )
 
+// ForceApi is safe for concurrent use by multiple goroutines. When requests
+// made concurrently find the session expired, a single one of them
+// reauthenticates and the others retry with the new session.
 type ForceApi struct {
 	apiVersion             string
 	oauth                  *forceOauth
@@ -25,11 +31,21 @@ type ForceApi struct {
 	apiSObjects            map[string]*SObjectMetaData
 	apiSObjectDescriptions map[string]*SObjectDescription
 	apiMaxBatchSize        int64
+	httpClient             *http.Client
+	middleware             []Middleware
+	retryPolicy            *RetryPolicy
+	quota                  quotaTracker
 	logger                 ForceApiLogger
 	logPrefix              string
 	debugMode              bool
+
+	// mu guards apiSObjects, apiSObjectDescriptions, apiMaxBatchSize and
+	// the trace settings. apiResources is only written while the ForceApi is
+	// created.
+	mu sync.RWMutex
 }
 
+// Deprecated: RefreshTokenResponse is no longer used; see Token.
 type RefreshTokenResponse struct {
 	ID          string `json:"id"`
 	IssuedAt    string `json:"issued_at"`
@@ -171,21 +187,24 @@ type ChildRelationship struct {
 	RelationshipName    string `json:"relationshipName"`
 }
 
-func (forceApi *ForceApi) getApiResources() error {
+func (forceApi *ForceApi) getApiResources(ctx context.Context) error {
 	uri := fmt.Sprintf(resourcesUri, forceApi.apiVersion)
 
-	return forceApi.Get(uri, nil, &forceApi.apiResources)
+	return forceApi.GetContext(ctx, uri, nil, &forceApi.apiResources)
 }
 
-func (forceApi *ForceApi) getApiSObjects() error {
+func (forceApi *ForceApi) getApiSObjects(ctx context.Context) error {
 	uri := forceApi.apiResources[sObjectsKey]
 
 	list := &SObjectApiResponse{}
-	err := forceApi.Get(uri, nil, list)
+	err := forceApi.GetContext(ctx, uri, nil, list)
 	if err != nil {
 		return err
 	}
 
+	forceApi.mu.Lock()
+	defer forceApi.mu.Unlock()
+
 	forceApi.apiMaxBatchSize = list.MaxBatchSize
 
 	// The API doesn't return the list of sobjects in a map. Convert it.
@@ -196,44 +215,58 @@ func (forceApi *ForceApi) getApiSObjects() error {
 	return nil
 }
 
-func (forceApi *ForceApi) getApiSObjectDescriptions() error {
+func (forceApi *ForceApi) getApiSObjectDescriptions(ctx context.Context) error {
+	forceApi.mu.RLock()
+	uris := make(map[string]string, len(forceApi.apiSObjects))
 	for name, metaData := range forceApi.apiSObjects {
-		uri := metaData.URLs[sObjectDescribeKey]
+		uris[name] = metaData.URLs[sObjectDescribeKey]
+	}
+	forceApi.mu.RUnlock()
 
+	for name, uri := range uris {
 		desc := &SObjectDescription{}
-		err := forceApi.Get(uri, nil, desc)
+		err := forceApi.GetContext(ctx, uri, nil, desc)
 		if err != nil {
 			return err
 		}
 
+		forceApi.mu.Lock()
 		forceApi.apiSObjectDescriptions[name] = desc
+		forceApi.mu.Unlock()
 	}
 
 	return nil
 }
 
-func (forceApi *ForceApi) GetInstanceURL() string {
-	return forceApi.oauth.InstanceUrl
-}
+// sObject returns the metadata of the named sobject.
+func (forceApi *ForceApi) sObject(name string) (*SObjectMetaData, bool) {
+	forceApi.mu.RLock()
+	defer forceApi.mu.RUnlock()
 
-func (forceApi *ForceApi) GetAccessToken() string {
-	return forceApi.oauth.AccessToken
+	metaData, ok := forceApi.apiSObjects[name]
+
+	return metaData, ok
 }
 
-func (forceApi *ForceApi) RefreshToken() error {
-	res := &RefreshTokenResponse{}
-	payload := map[string]string{
-		"grant_type":    "refresh_token",
-		"refresh_token": forceApi.oauth.refreshToken,
-		"client_id":     forceApi.oauth.clientId,
-		"client_secret": forceApi.oauth.clientSecret,
+// sObjectURL returns the url of the named sobject resource, or "" if the
+// sobject is unknown.
+func (forceApi *ForceApi) sObjectURL(name, key string) string {
+	metaData, ok := forceApi.sObject(name)
+	if !ok {
+		return ""
 	}
 
-	err := forceApi.Post("/services/oauth2/token", nil, payload, res)
-	if err != nil {
-		return err
-	}
+	return metaData.URLs[key]
+}
 
-	forceApi.oauth.AccessToken = res.AccessToken
-	return nil
+func (forceApi *ForceApi) GetInstanceURL() string {
+	instanceUrl, _ := forceApi.oauth.session()
+
+	return instanceUrl
+}
+
+func (forceApi *ForceApi) GetAccessToken() string {
+	_, accessToken := forceApi.oauth.session()
+
+	return accessToken
 }
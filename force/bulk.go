@@ -0,0 +1,365 @@
+package force
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	jobsKey = "jobs"
+
+	csvContentType = "text/csv"
+)
+
+// BulkOperation is the operation of a Bulk API 2.0 job.
+type BulkOperation string
+
+const (
+	BulkInsert     BulkOperation = "insert"
+	BulkUpdate     BulkOperation = "update"
+	BulkUpsert     BulkOperation = "upsert"
+	BulkDelete     BulkOperation = "delete"
+	BulkHardDelete BulkOperation = "hardDelete"
+	BulkQuery      BulkOperation = "query"
+	BulkQueryAll   BulkOperation = "queryAll"
+)
+
+// States of a Bulk API 2.0 job.
+const (
+	JobStateOpen           = "Open"
+	JobStateUploadComplete = "UploadComplete"
+	JobStateInProgress     = "InProgress"
+	JobStateJobComplete    = "JobComplete"
+	JobStateFailed         = "Failed"
+	JobStateAborted        = "Aborted"
+)
+
+// Polling interval bounds of WaitIngestJob and WaitQueryJob.
+var (
+	bulkPollInterval    = time.Second
+	bulkMaxPollInterval = 30 * time.Second
+)
+
+// BulkJob describes a Bulk API 2.0 job.
+type BulkJob struct {
+	Id                     string  `force:"id,omitempty"`
+	Operation              string  `force:"operation,omitempty"`
+	Object                 string  `force:"object,omitempty"`
+	Query                  string  `force:"query,omitempty"`
+	ExternalIdFieldName    string  `force:"externalIdFieldName,omitempty"`
+	ContentType            string  `force:"contentType,omitempty"`
+	LineEnding             string  `force:"lineEnding,omitempty"`
+	ColumnDelimiter        string  `force:"columnDelimiter,omitempty"`
+	State                  string  `force:"state,omitempty"`
+	CreatedById            string  `force:"createdById,omitempty"`
+	CreatedDate            string  `force:"createdDate,omitempty"`
+	SystemModstamp         string  `force:"systemModstamp,omitempty"`
+	ConcurrencyMode        string  `force:"concurrencyMode,omitempty"`
+	ApiVersion             float64 `force:"apiVersion,omitempty"`
+	JobType                string  `force:"jobType,omitempty"`
+	NumberRecordsProcessed int64   `force:"numberRecordsProcessed,omitempty"`
+	NumberRecordsFailed    int64   `force:"numberRecordsFailed,omitempty"`
+	Retries                int64   `force:"retries,omitempty"`
+	TotalProcessingTime    int64   `force:"totalProcessingTime,omitempty"`
+	ErrorMessage           string  `force:"errorMessage,omitempty"`
+}
+
+// Done reports whether the job reached a final state.
+func (job *BulkJob) Done() bool {
+	switch job.State {
+	case JobStateJobComplete, JobStateFailed, JobStateAborted:
+		return true
+	}
+
+	return false
+}
+
+type bulkJobState struct {
+	State string `force:"state"`
+}
+
+// CreateIngestJob creates a Bulk API 2.0 job applying operation to records
+// of in's type. Upserts match records on in's ExternalIdApiName field. Bulk
+// API 2.0 requires api version 41.0 or later.
+//
+// A job is loaded in four steps: create it, upload its records with
+// UploadIngestJob, close it with CloseIngestJob and wait for Salesforce to
+// process it with WaitIngestJob. The results are then available through
+// GetIngestSuccessfulResults, GetIngestFailedResults and
+// GetIngestUnprocessedRecords.
+func (forceApi *ForceApi) CreateIngestJob(in SObject, operation BulkOperation) (*BulkJob, error) {
+	return forceApi.CreateIngestJobContext(context.Background(), in, operation)
+}
+
+// CreateIngestJobContext is like CreateIngestJob but carries ctx through the request.
+func (forceApi *ForceApi) CreateIngestJobContext(ctx context.Context, in SObject, operation BulkOperation) (*BulkJob, error) {
+	payload := &BulkJob{
+		Object:      in.ApiName(),
+		Operation:   string(operation),
+		ContentType: "CSV",
+		LineEnding:  "LF",
+	}
+	if operation == BulkUpsert {
+		payload.ExternalIdFieldName = in.ExternalIdApiName()
+	}
+
+	job := &BulkJob{}
+	if err := forceApi.PostContext(ctx, forceApi.ingestUri(""), nil, payload, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// UploadIngestJob uploads records to an open job as CSV. The columns are the
+// fields of the records' struct that the job's operation can set according to
+// the object description, named by their force tags. Zero values of fields
+// tagged omitempty are left empty, which leaves the field unchanged. A job
+// accepts a single upload of at most 150 MB.
+func (forceApi *ForceApi) UploadIngestJob(job *BulkJob, records []SObject) error {
+	return forceApi.UploadIngestJobContext(context.Background(), job, records)
+}
+
+// UploadIngestJobContext is like UploadIngestJob but carries ctx through the request.
+func (forceApi *ForceApi) UploadIngestJobContext(ctx context.Context, job *BulkJob, records []SObject) error {
+	i := 0
+	return forceApi.uploadIngestJob(ctx, job, func() (SObject, bool) {
+		if i == len(records) {
+			return nil, false
+		}
+		i++
+		return records[i-1], true
+	})
+}
+
+// UploadIngestJobStream is like UploadIngestJob but streams the records
+// received from records until it is closed, so they need not be held in
+// memory at once.
+func (forceApi *ForceApi) UploadIngestJobStream(job *BulkJob, records <-chan SObject) error {
+	return forceApi.UploadIngestJobStreamContext(context.Background(), job, records)
+}
+
+// UploadIngestJobStreamContext is like UploadIngestJobStream but carries ctx through the request.
+func (forceApi *ForceApi) UploadIngestJobStreamContext(ctx context.Context, job *BulkJob, records <-chan SObject) error {
+	return forceApi.uploadIngestJob(ctx, job, func() (SObject, bool) {
+		select {
+		case record, ok := <-records:
+			return record, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	})
+}
+
+func (forceApi *ForceApi) uploadIngestJob(ctx context.Context, job *BulkJob, next func() (SObject, bool)) error {
+	first, ok := next()
+	if !ok {
+		return fmt.Errorf("Error uploading bulk job %v: no records", job.Id)
+	}
+	if first.ApiName() != job.Object {
+		return fmt.Errorf("Error uploading bulk job %v: %v records do not match job object %v", job.Id, first.ApiName(), job.Object)
+	}
+
+	columns, err := forceApi.bulkColumns(ctx, first, BulkOperation(job.Operation), job.ExternalIdFieldName)
+	if err != nil {
+		return err
+	}
+
+	reader, writer := io.Pipe()
+	defer reader.Close()
+
+	go func() {
+		writer.CloseWithError(writeBulkCSV(writer, columns, job.Object, first, next))
+	}()
+
+	resp, err := forceApi.stream(ctx, "PUT", forceApi.ingestUri(job.Id)+"/batches", nil, csvContentType, responseType, reader)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// CloseIngestJob marks the upload of a job as complete, queuing it for processing.
+func (forceApi *ForceApi) CloseIngestJob(jobId string) (*BulkJob, error) {
+	return forceApi.CloseIngestJobContext(context.Background(), jobId)
+}
+
+// CloseIngestJobContext is like CloseIngestJob but carries ctx through the request.
+func (forceApi *ForceApi) CloseIngestJobContext(ctx context.Context, jobId string) (*BulkJob, error) {
+	return forceApi.setJobState(ctx, forceApi.ingestUri(jobId), JobStateUploadComplete)
+}
+
+// AbortIngestJob stops a job. Records already processed are not rolled back.
+func (forceApi *ForceApi) AbortIngestJob(jobId string) (*BulkJob, error) {
+	return forceApi.AbortIngestJobContext(context.Background(), jobId)
+}
+
+// AbortIngestJobContext is like AbortIngestJob but carries ctx through the request.
+func (forceApi *ForceApi) AbortIngestJobContext(ctx context.Context, jobId string) (*BulkJob, error) {
+	return forceApi.setJobState(ctx, forceApi.ingestUri(jobId), JobStateAborted)
+}
+
+func (forceApi *ForceApi) setJobState(ctx context.Context, uri, state string) (*BulkJob, error) {
+	job := &BulkJob{}
+	if err := forceApi.PatchContext(ctx, uri, nil, bulkJobState{State: state}, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetIngestJob returns the current state of a job.
+func (forceApi *ForceApi) GetIngestJob(jobId string) (*BulkJob, error) {
+	return forceApi.GetIngestJobContext(context.Background(), jobId)
+}
+
+// GetIngestJobContext is like GetIngestJob but carries ctx through the request.
+func (forceApi *ForceApi) GetIngestJobContext(ctx context.Context, jobId string) (*BulkJob, error) {
+	job := &BulkJob{}
+	if err := forceApi.GetContext(ctx, forceApi.ingestUri(jobId), nil, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// DeleteIngestJob deletes a closed job along with its results.
+func (forceApi *ForceApi) DeleteIngestJob(jobId string) error {
+	return forceApi.DeleteIngestJobContext(context.Background(), jobId)
+}
+
+// DeleteIngestJobContext is like DeleteIngestJob but carries ctx through the request.
+func (forceApi *ForceApi) DeleteIngestJobContext(ctx context.Context, jobId string) error {
+	return forceApi.DeleteContext(ctx, forceApi.ingestUri(jobId), nil)
+}
+
+// WaitIngestJob polls a closed job until it is done, backing off from one
+// to thirty seconds between polls. A job that failed or was aborted is
+// returned along with an error.
+func (forceApi *ForceApi) WaitIngestJob(jobId string) (*BulkJob, error) {
+	return forceApi.WaitIngestJobContext(context.Background(), jobId)
+}
+
+// WaitIngestJobContext is like WaitIngestJob but stops waiting when ctx is done.
+func (forceApi *ForceApi) WaitIngestJobContext(ctx context.Context, jobId string) (*BulkJob, error) {
+	return waitBulkJob(ctx, func() (*BulkJob, error) {
+		return forceApi.GetIngestJobContext(ctx, jobId)
+	})
+}
+
+func waitBulkJob(ctx context.Context, get func() (*BulkJob, error)) (*BulkJob, error) {
+	interval := bulkPollInterval
+	for {
+		job, err := get()
+		if err != nil {
+			return nil, err
+		}
+
+		if job.Done() {
+			if job.State != JobStateJobComplete {
+				return job, fmt.Errorf("Bulk job %v %v: %v", job.Id, strings.ToLower(job.State), job.ErrorMessage)
+			}
+			return job, nil
+		}
+
+		if err := sleepContext(ctx, interval); err != nil {
+			return job, err
+		}
+
+		if interval *= 2; interval > bulkMaxPollInterval {
+			interval = bulkMaxPollInterval
+		}
+	}
+}
+
+// IngestResult is a processed record of an ingest job. Error is set for
+// failed records.
+type IngestResult[T any] struct {
+	Id      string
+	Created bool
+	Error   *ApiError
+	Record  T
+}
+
+// GetIngestSuccessfulResults returns the records a job processed
+// successfully, decoded into T by their force tags.
+func GetIngestSuccessfulResults[T any](ctx context.Context, forceApi *ForceApi, jobId string) ([]IngestResult[T], error) {
+	return getIngestResults[T](ctx, forceApi, jobId, "successfulResults")
+}
+
+// GetIngestFailedResults returns the records a job failed to process along
+// with the error reported for each of them.
+func GetIngestFailedResults[T any](ctx context.Context, forceApi *ForceApi, jobId string) ([]IngestResult[T], error) {
+	return getIngestResults[T](ctx, forceApi, jobId, "failedResults")
+}
+
+// GetIngestUnprocessedRecords returns the records a job did not process
+// because it failed or was aborted.
+func GetIngestUnprocessedRecords[T any](ctx context.Context, forceApi *ForceApi, jobId string) ([]T, error) {
+	results, err := getIngestResults[T](ctx, forceApi, jobId, "unprocessedrecords")
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]T, len(results))
+	for i := range results {
+		records[i] = results[i].Record
+	}
+
+	return records, nil
+}
+
+func getIngestResults[T any](ctx context.Context, forceApi *ForceApi, jobId, resource string) ([]IngestResult[T], error) {
+	resp, err := forceApi.stream(ctx, "GET", forceApi.ingestUri(jobId)+"/"+resource+"/", nil, csvContentType, csvContentType, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	decoder, err := newBulkCSVDecoder[T](csv.NewReader(resp.Body))
+	if err == io.EOF {
+		return []IngestResult[T]{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading bulk job %v %v: %v", jobId, resource, err)
+	}
+
+	results := []IngestResult[T]{}
+	for {
+		result, err := decoder.next()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading bulk job %v %v: %v", jobId, resource, err)
+		}
+
+		results = append(results, result)
+	}
+}
+
+// ingestUri returns the uri of the ingest job with the given id, or of the
+// ingest jobs resource if jobId is empty.
+func (forceApi *ForceApi) ingestUri(jobId string) string {
+	return forceApi.jobsUri("ingest", jobId)
+}
+
+func (forceApi *ForceApi) jobsUri(jobType, jobId string) string {
+	uri, ok := forceApi.apiResources[jobsKey]
+	if !ok {
+		uri = fmt.Sprintf(resourcesUri, forceApi.apiVersion) + "/jobs"
+	}
+
+	uri += "/" + jobType
+	if jobId != "" {
+		uri += "/" + jobId
+	}
+
+	return uri
+}
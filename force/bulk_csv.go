@@ -0,0 +1,342 @@
+package force
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+// Columns prepended by Salesforce to the records of ingest job results.
+const (
+	bulkIdColumn      = "sf__Id"
+	bulkCreatedColumn = "sf__Created"
+	bulkErrorColumn   = "sf__Error"
+)
+
+// forceField is a field of an SObject struct named by its force tag, such as
+// a CSV column.
+type forceField struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// forceFields returns the fields of a struct type named by their force tags,
+// flattening embedded structs in place. Fields of the outer struct shadow
+// those of embedded structs.
+func forceFields(rt reflect.Type) []forceField {
+	var fields []forceField
+	outer := map[string]bool{}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, tagged := forceFieldName(field)
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && !tagged && fieldType.Kind() == reflect.Struct {
+			for _, inner := range forceFields(fieldType) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+
+		outer[strings.ToLower(name)] = true
+		fields = append(fields, forceField{
+			name:      name,
+			index:     []int{i},
+			typ:       field.Type,
+			omitEmpty: strings.Contains(field.Tag.Get("force"), ",omitempty"),
+		})
+	}
+
+	visible := fields[:0]
+	for _, field := range fields {
+		if len(field.index) == 1 || !outer[strings.ToLower(field.name)] {
+			visible = append(visible, field)
+		}
+	}
+
+	return visible
+}
+
+func findForceField(fields []forceField, name string) *forceField {
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+
+	return nil
+}
+
+// value returns the field of ref, or the invalid Value if it is inside a nil
+// embedded pointer.
+func (field *forceField) value(ref reflect.Value) reflect.Value {
+	for i, index := range field.index {
+		if i > 0 && ref.Kind() == reflect.Pointer {
+			if ref.IsNil() {
+				return reflect.Value{}
+			}
+			ref = ref.Elem()
+		}
+		ref = ref.Field(index)
+	}
+
+	return ref
+}
+
+// bulkColumns returns the fields of in's struct that the operation can set
+// according to the object description.
+func (forceApi *ForceApi) bulkColumns(ctx context.Context, in SObject, operation BulkOperation, externalId string) ([]forceField, error) {
+	description, err := forceApi.DescribeSObjectContext(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	described := map[string]*SObjectField{}
+	for _, field := range description.Fields {
+		described[strings.ToLower(field.Name)] = field
+	}
+
+	required := "Id"
+	if operation == BulkUpsert {
+		required = externalId
+	}
+
+	columns := []forceField{}
+	for _, field := range forceFields(reflect.Indirect(reflect.ValueOf(in)).Type()) {
+		describedField, ok := described[strings.ToLower(field.name)]
+		if !ok {
+			continue
+		}
+
+		var include bool
+		switch operation {
+		case BulkInsert:
+			include = describedField.Createable
+		case BulkUpdate:
+			include = describedField.Updateable || strings.EqualFold(field.name, required)
+		case BulkUpsert:
+			include = describedField.Createable || describedField.Updateable || strings.EqualFold(field.name, required)
+		case BulkDelete, BulkHardDelete:
+			include = strings.EqualFold(field.name, required)
+		}
+
+		if include {
+			columns = append(columns, field)
+		}
+	}
+
+	if operation != BulkInsert && findForceField(columns, required) == nil {
+		return nil, fmt.Errorf("Unable to %v %v records without a %v field", operation, in.ApiName(), required)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("Unable to %v %v records without writable fields", operation, in.ApiName())
+	}
+
+	return columns, nil
+}
+
+// writeBulkCSV writes the header and a row for every record to w.
+func writeBulkCSV(w io.Writer, columns []forceField, object string, first SObject, next func() (SObject, bool)) error {
+	writer := csv.NewWriter(w)
+
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		row[i] = column.name
+	}
+	if err := writer.Write(row); err != nil {
+		return err
+	}
+
+	recordType := reflect.TypeOf(first)
+	for record, ok := first, true; ok; record, ok = next() {
+		if reflect.TypeOf(record) != recordType || record.ApiName() != object {
+			return fmt.Errorf("Unable to upload %T with %T records to a %v job", record, first, object)
+		}
+
+		ref := reflect.Indirect(reflect.ValueOf(record))
+		for i := range columns {
+			value, err := formatBulkValue(columns[i].value(ref), columns[i].omitEmpty)
+			if err != nil {
+				return fmt.Errorf("Error encoding %v: %v", columns[i].name, err)
+			}
+			row[i] = value
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// formatBulkValue formats a field as a CSV value the way forcejson would
+// encode it. Empty values leave the field unchanged.
+func formatBulkValue(value reflect.Value, omitEmpty bool) (string, error) {
+	if !value.IsValid() || (omitEmpty && value.IsZero()) {
+		return "", nil
+	}
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		return "", nil
+	}
+
+	encoded, err := forcejson.Marshal(value.Interface())
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case string(encoded) == "null":
+		return "", nil
+	case len(encoded) > 0 && encoded[0] == '"':
+		var s string
+		err := json.Unmarshal(encoded, &s)
+		return s, err
+	}
+
+	return string(encoded), nil
+}
+
+// bulkCSVDecoder decodes the rows of a CSV result into IngestResults.
+type bulkCSVDecoder[T any] struct {
+	reader *csv.Reader
+	header []string
+	fields []*forceField
+}
+
+func newBulkCSVDecoder[T any](reader *csv.Reader) (*bulkCSVDecoder[T], error) {
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	recordType := reflect.TypeOf((*T)(nil)).Elem()
+	if recordType.Kind() == reflect.Pointer {
+		recordType = recordType.Elem()
+	}
+	if recordType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to decode records into %v", recordType)
+	}
+
+	structFields := forceFields(recordType)
+	fields := make([]*forceField, len(header))
+	for i, column := range header {
+		fields[i] = findForceField(structFields, column)
+	}
+
+	return &bulkCSVDecoder[T]{
+		reader: reader,
+		header: header,
+		fields: fields,
+	}, nil
+}
+
+func (decoder *bulkCSVDecoder[T]) next() (IngestResult[T], error) {
+	result := IngestResult[T]{}
+
+	row, err := decoder.reader.Read()
+	if err != nil {
+		return result, err
+	}
+
+	values := map[string]json.RawMessage{}
+	for i, value := range row {
+		switch decoder.header[i] {
+		case bulkIdColumn:
+			result.Id = value
+			continue
+		case bulkCreatedColumn:
+			result.Created = value == "true"
+			continue
+		case bulkErrorColumn:
+			result.Error = parseBulkError(value)
+			continue
+		}
+
+		field := decoder.fields[i]
+		if field == nil || value == "" {
+			continue
+		}
+
+		encoded, err := encodeBulkValue(value, field.typ)
+		if err != nil {
+			return result, err
+		}
+		values[field.name] = encoded
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return result, err
+	}
+	if err := forcejson.Unmarshal(encoded, &result.Record); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// encodeBulkValue encodes a CSV value as the JSON forcejson expects for a
+// field of type typ.
+func encodeBulkValue(value string, typ reflect.Type) (json.RawMessage, error) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("invalid %v value %q", typ, value)
+		}
+		return json.RawMessage(value), nil
+	}
+
+	return json.Marshal(value)
+}
+
+// parseBulkError parses an sf__Error value such as
+// "REQUIRED_FIELD_MISSING:Required fields are missing: [LastName]:LastName --".
+func parseBulkError(value string) *ApiError {
+	if value == "" {
+		return nil
+	}
+
+	code, rest, found := strings.Cut(value, ":")
+	if !found {
+		return &ApiError{Message: value}
+	}
+
+	apiErr := &ApiError{ErrorCode: code, Message: rest}
+	if i := strings.LastIndex(rest, ":"); i >= 0 && strings.HasSuffix(rest, "--") {
+		apiErr.Message = rest[:i]
+		for _, field := range strings.Split(strings.TrimSpace(strings.TrimSuffix(rest[i+1:], "--")), ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				apiErr.Fields = append(apiErr.Fields, field)
+			}
+		}
+	}
+
+	return apiErr
+}
@@ -0,0 +1,283 @@
+package force
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+const locatorHeader = "Sforce-Locator"
+
+// CreateQueryJob creates a Bulk API 2.0 job exporting the records matched by
+// query. Use BulkQueryAll as operation to include deleted and archived
+// records. Bulk API 2.0 requires api version 47.0 or later for query jobs.
+//
+// Once created, read the records with NewQueryJobIter, which waits for the
+// job to complete.
+func (forceApi *ForceApi) CreateQueryJob(query string, operation BulkOperation) (*BulkJob, error) {
+	return forceApi.CreateQueryJobContext(context.Background(), query, operation)
+}
+
+// CreateQueryJobContext is like CreateQueryJob but carries ctx through the request.
+func (forceApi *ForceApi) CreateQueryJobContext(ctx context.Context, query string, operation BulkOperation) (*BulkJob, error) {
+	payload := &BulkJob{
+		Operation:   string(operation),
+		Query:       query,
+		ContentType: "CSV",
+		LineEnding:  "LF",
+	}
+
+	job := &BulkJob{}
+	if err := forceApi.PostContext(ctx, forceApi.queryJobUri(""), nil, payload, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// GetQueryJob returns the current state of a query job.
+func (forceApi *ForceApi) GetQueryJob(jobId string) (*BulkJob, error) {
+	return forceApi.GetQueryJobContext(context.Background(), jobId)
+}
+
+// GetQueryJobContext is like GetQueryJob but carries ctx through the request.
+func (forceApi *ForceApi) GetQueryJobContext(ctx context.Context, jobId string) (*BulkJob, error) {
+	job := &BulkJob{}
+	if err := forceApi.GetContext(ctx, forceApi.queryJobUri(jobId), nil, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// AbortQueryJob stops a query job.
+func (forceApi *ForceApi) AbortQueryJob(jobId string) (*BulkJob, error) {
+	return forceApi.AbortQueryJobContext(context.Background(), jobId)
+}
+
+// AbortQueryJobContext is like AbortQueryJob but carries ctx through the request.
+func (forceApi *ForceApi) AbortQueryJobContext(ctx context.Context, jobId string) (*BulkJob, error) {
+	return forceApi.setJobState(ctx, forceApi.queryJobUri(jobId), JobStateAborted)
+}
+
+// DeleteQueryJob deletes a query job along with its results.
+func (forceApi *ForceApi) DeleteQueryJob(jobId string) error {
+	return forceApi.DeleteQueryJobContext(context.Background(), jobId)
+}
+
+// DeleteQueryJobContext is like DeleteQueryJob but carries ctx through the request.
+func (forceApi *ForceApi) DeleteQueryJobContext(ctx context.Context, jobId string) error {
+	return forceApi.DeleteContext(ctx, forceApi.queryJobUri(jobId), nil)
+}
+
+// WaitQueryJob polls a query job until it is done, backing off like
+// WaitIngestJob. A job that failed or was aborted is returned along with an
+// error.
+func (forceApi *ForceApi) WaitQueryJob(jobId string) (*BulkJob, error) {
+	return forceApi.WaitQueryJobContext(context.Background(), jobId)
+}
+
+// WaitQueryJobContext is like WaitQueryJob but stops waiting when ctx is done.
+func (forceApi *ForceApi) WaitQueryJobContext(ctx context.Context, jobId string) (*BulkJob, error) {
+	return waitBulkJob(ctx, func() (*BulkJob, error) {
+		return forceApi.GetQueryJobContext(ctx, jobId)
+	})
+}
+
+func (forceApi *ForceApi) queryJobUri(jobId string) string {
+	return forceApi.jobsUri("query", jobId)
+}
+
+// QueryJobIter iterates over the results of a query job one record at a
+// time, decoding the CSV columns into T by their force tags. Result pages are
+// streamed as they are read rather than held in memory.
+//
+//	iter := force.NewQueryJobIter[sobjects.Account](ctx, forceApi, job.Id)
+//	defer iter.Close()
+//	for iter.Next() {
+//		account := iter.Record()
+//		...
+//	}
+//	if err := iter.Err(); err != nil {
+//		...
+//	}
+//
+// To resume an interrupted export, save Locator as pages are processed and
+// pass it to WithLocator.
+type QueryJobIter[T any] struct {
+	forceApi    *ForceApi
+	ctx         context.Context
+	cancel      context.CancelFunc
+	jobId       string
+	maxRecords  int
+	started     bool
+	closed      bool
+	locator     string
+	nextLocator string
+	body        io.ReadCloser
+	decoder     *bulkCSVDecoder[T]
+	record      T
+	err         error
+}
+
+// QueryJobIterOption configures a QueryJobIter.
+type QueryJobIterOption func(*queryJobIterConfig)
+
+type queryJobIterConfig struct {
+	maxRecords int
+	locator    string
+}
+
+// WithMaxRecords limits the number of records of each result page. Salesforce
+// picks the page size when it is not set.
+func WithMaxRecords(maxRecords int) QueryJobIterOption {
+	return func(config *queryJobIterConfig) {
+		config.maxRecords = maxRecords
+	}
+}
+
+// WithLocator starts the iteration at the result page of the given locator,
+// as returned by QueryJobIter.Locator.
+func WithLocator(locator string) QueryJobIterOption {
+	return func(config *queryJobIterConfig) {
+		config.locator = locator
+	}
+}
+
+// NewQueryJobIter returns an iterator over the results of a query job. The
+// first call to Next waits for the job to complete.
+func NewQueryJobIter[T any](ctx context.Context, forceApi *ForceApi, jobId string, opts ...QueryJobIterOption) *QueryJobIter[T] {
+	config := &queryJobIterConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &QueryJobIter[T]{
+		forceApi:   forceApi,
+		ctx:        ctx,
+		cancel:     cancel,
+		jobId:      jobId,
+		maxRecords: config.maxRecords,
+		locator:    config.locator,
+	}
+}
+
+// Next advances to the next record, fetching the next result page if
+// needed. It returns false when the records are exhausted, an error occurs
+// or the iterator is closed.
+func (iter *QueryJobIter[T]) Next() bool {
+	if iter.closed || iter.err != nil {
+		return false
+	}
+
+	for {
+		if iter.decoder != nil {
+			result, err := iter.decoder.next()
+			if err == nil {
+				iter.record = result.Record
+				return true
+			}
+			if err != io.EOF {
+				iter.err = fmt.Errorf("Error reading bulk job %v results: %v", iter.jobId, err)
+				return false
+			}
+			iter.closeBody()
+		}
+
+		if iter.started {
+			if iter.nextLocator == "" {
+				return false
+			}
+			iter.locator = iter.nextLocator
+		}
+
+		if !iter.fetch() {
+			return false
+		}
+	}
+}
+
+// Record returns the current record.
+func (iter *QueryJobIter[T]) Record() T {
+	return iter.record
+}
+
+// Locator returns the locator of the result page holding the current record,
+// which is empty for the first page. An iterator created with this locator
+// starts over at the beginning of the page, so records of the page may be
+// seen twice but none are skipped.
+func (iter *QueryJobIter[T]) Locator() string {
+	return iter.locator
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (iter *QueryJobIter[T]) Err() error {
+	return iter.err
+}
+
+// Close stops the iteration and releases the result page being read. It is
+// safe to call Close more than once.
+func (iter *QueryJobIter[T]) Close() error {
+	iter.closed = true
+	iter.closeBody()
+	iter.cancel()
+
+	return nil
+}
+
+// fetch opens the result page of the current locator.
+func (iter *QueryJobIter[T]) fetch() bool {
+	if !iter.started {
+		iter.started = true
+		if _, err := iter.forceApi.WaitQueryJobContext(iter.ctx, iter.jobId); err != nil {
+			iter.err = err
+			return false
+		}
+	}
+
+	params := url.Values{}
+	if iter.locator != "" {
+		params.Set("locator", iter.locator)
+	}
+	if iter.maxRecords > 0 {
+		params.Set("maxRecords", strconv.Itoa(iter.maxRecords))
+	}
+
+	uri := iter.forceApi.queryJobUri(iter.jobId) + "/results"
+	resp, err := iter.forceApi.stream(iter.ctx, "GET", uri, params, csvContentType, csvContentType, nil)
+	if err != nil {
+		iter.err = err
+		return false
+	}
+
+	iter.body = resp.Body
+	iter.nextLocator = resp.Header.Get(locatorHeader)
+	if iter.nextLocator == "null" {
+		iter.nextLocator = ""
+	}
+
+	iter.decoder, err = newBulkCSVDecoder[T](csv.NewReader(resp.Body))
+	if err == io.EOF {
+		iter.closeBody()
+		return true
+	}
+	if err != nil {
+		iter.err = fmt.Errorf("Error reading bulk job %v results: %v", iter.jobId, err)
+		return false
+	}
+
+	return true
+}
+
+func (iter *QueryJobIter[T]) closeBody() {
+	if iter.body != nil {
+		iter.body.Close()
+		iter.body = nil
+	}
+	iter.decoder = nil
+}
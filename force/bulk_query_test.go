@@ -0,0 +1,172 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bulkQueryAccount struct {
+	Id                string  `force:"Id"`
+	Name              string  `force:"Name"`
+	AnnualRevenue     float64 `force:"AnnualRevenue,omitempty"`
+	NumberOfEmployees int64   `force:"NumberOfEmployees,omitempty"`
+}
+
+// bulkQueryHandler serves the results of job 750000000000002 in pages of
+// maxRecords rows, using the index of the first row of a page as its locator.
+func bulkQueryHandler(t *testing.T, rows []string, requests *[]string) http.HandlerFunc {
+	polls := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		const jobUri = "/services/data/v36.0/jobs/query/750000000000002"
+		*requests = append(*requests, r.Method+" "+r.URL.RequestURI())
+		switch r.Method + " " + r.URL.Path {
+		case "POST /services/data/v36.0/jobs/query":
+			body := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["operation"] != "query" || body["query"] != "SELECT Id, Name FROM Account" {
+				t.Errorf("Unexpected job: %v", body)
+			}
+			fmt.Fprint(w, `{"id":"750000000000002","operation":"query","object":"Account","state":"UploadComplete"}`)
+		case "GET " + jobUri:
+			polls++
+			state := "InProgress"
+			if polls > 1 {
+				state = "JobComplete"
+			}
+			fmt.Fprintf(w, `{"id":"750000000000002","state":%q}`, state)
+		case "GET " + jobUri + "/results":
+			start := 0
+			fmt.Sscan(r.URL.Query().Get("locator"), &start)
+			end := len(rows)
+			if maxRecords := r.URL.Query().Get("maxRecords"); maxRecords != "" {
+				var n int
+				fmt.Sscan(maxRecords, &n)
+				if start+n < end {
+					end = start + n
+				}
+			}
+
+			locator := "null"
+			if end < len(rows) {
+				locator = fmt.Sprint(end)
+			}
+			w.Header().Set("Sforce-Locator", locator)
+			w.Header().Set("Content-Type", "text/csv")
+			fmt.Fprint(w, "\"Id\",\"Name\",\"AnnualRevenue\",\"NumberOfEmployees\"\n"+strings.Join(rows[start:end], ""))
+		default:
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		}
+	}
+}
+
+func bulkQueryRows(n int) []string {
+	rows := make([]string, n)
+	for i := range rows {
+		rows[i] = fmt.Sprintf("\"001%012d\",\"Account %d\",\"%d.5\",\"\"\n", i, i, i)
+	}
+
+	return rows
+}
+
+func TestQueryJobIter(t *testing.T) {
+	setBulkPollInterval(t, time.Millisecond)
+
+	var requests []string
+	forceApi := createBulkTestServer(t, bulkQueryHandler(t, bulkQueryRows(5), &requests))
+
+	job, err := forceApi.CreateQueryJob("SELECT Id, Name FROM Account", BulkQuery)
+	if err != nil {
+		t.Fatalf("Failed to create query job: %v", err)
+	}
+
+	iter := NewQueryJobIter[bulkQueryAccount](context.Background(), forceApi, job.Id, WithMaxRecords(2))
+	defer iter.Close()
+
+	var locators []string
+	count := 0
+	for iter.Next() {
+		account := iter.Record()
+		if account.Id != fmt.Sprintf("001%012d", count) || account.Name != fmt.Sprintf("Account %d", count) ||
+			account.AnnualRevenue != float64(count)+0.5 {
+			t.Fatalf("Unexpected record %d: %+v", count, account)
+		}
+		locators = append(locators, iter.Locator())
+		count++
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+
+	if count != 5 {
+		t.Fatalf("Expected 5 records, got %d", count)
+	}
+	if fmt.Sprint(locators) != "[  2 2 4]" {
+		t.Fatalf("Unexpected locators: %q", locators)
+	}
+
+	want := []string{
+		"POST /services/data/v36.0/jobs/query",
+		"GET /services/data/v36.0/jobs/query/750000000000002",
+		"GET /services/data/v36.0/jobs/query/750000000000002",
+		"GET /services/data/v36.0/jobs/query/750000000000002/results?maxRecords=2",
+		"GET /services/data/v36.0/jobs/query/750000000000002/results?locator=2&maxRecords=2",
+		"GET /services/data/v36.0/jobs/query/750000000000002/results?locator=4&maxRecords=2",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unexpected requests:\n%v", strings.Join(requests, "\n"))
+	}
+}
+
+func TestQueryJobIterResume(t *testing.T) {
+	setBulkPollInterval(t, time.Millisecond)
+
+	var requests []string
+	forceApi := createBulkTestServer(t, bulkQueryHandler(t, bulkQueryRows(5), &requests))
+
+	iter := NewQueryJobIter[*bulkQueryAccount](context.Background(), forceApi, "750000000000002", WithLocator("3"))
+	defer iter.Close()
+
+	var names []string
+	for iter.Next() {
+		names = append(names, iter.Record().Name)
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+
+	if fmt.Sprint(names) != "[Account 3 Account 4]" {
+		t.Fatalf("Unexpected records: %v", names)
+	}
+}
+
+func TestQueryJobIterEmpty(t *testing.T) {
+	setBulkPollInterval(t, time.Millisecond)
+
+	var requests []string
+	forceApi := createBulkTestServer(t, bulkQueryHandler(t, nil, &requests))
+
+	iter := NewQueryJobIter[bulkQueryAccount](context.Background(), forceApi, "750000000000002")
+	defer iter.Close()
+
+	if iter.Next() || iter.Err() != nil {
+		t.Fatalf("Expected no records, got error: %v", iter.Err())
+	}
+}
+
+func TestQueryJobIterFailedJob(t *testing.T) {
+	forceApi := createBulkTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"750000000000002","state":"Failed","errorMessage":"INVALID_FIELD: No such column 'Foo'"}`)
+	})
+
+	iter := NewQueryJobIter[bulkQueryAccount](context.Background(), forceApi, "750000000000002")
+	defer iter.Close()
+
+	if iter.Next() || iter.Err() == nil || !strings.Contains(iter.Err().Error(), "No such column") {
+		t.Fatalf("Expected failed job error, got %v", iter.Err())
+	}
+}
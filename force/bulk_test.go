@@ -0,0 +1,219 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type bulkContact struct {
+	sobjects.BaseSObject
+	LastName      string         `force:"LastName,omitempty"`
+	NumberOfPets  int64          `force:"Number_Of_Pets__c"`
+	DoNotCall     bool           `force:"DoNotCall,omitempty"`
+	Birthdate     *sobjects.Time `force:"Birthdate,omitempty"`
+	ExternalId    string         `force:"External_Id__c,omitempty"`
+	FormulaResult string         `force:"Formula__c,omitempty"`
+}
+
+func (c *bulkContact) ApiName() string {
+	return "Contact"
+}
+
+func (c *bulkContact) ExternalIdApiName() string {
+	return "External_Id__c"
+}
+
+func setBulkPollInterval(t *testing.T, interval time.Duration) {
+	min, max := bulkPollInterval, bulkMaxPollInterval
+	bulkPollInterval, bulkMaxPollInterval = interval, interval
+	t.Cleanup(func() {
+		bulkPollInterval, bulkMaxPollInterval = min, max
+	})
+}
+
+func createBulkTestServer(t *testing.T, handler http.HandlerFunc) *ForceApi {
+	forceApi := createTestServer(t, handler)
+	forceApi.apiResources[jobsKey] = "/services/data/v36.0/jobs"
+
+	return forceApi
+}
+
+func TestIngestJob(t *testing.T) {
+	setBulkPollInterval(t, time.Millisecond)
+
+	var uploaded string
+	polls := 0
+	forceApi := createBulkTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		const jobUri = "/services/data/v36.0/jobs/ingest/750000000000001"
+		switch r.Method + " " + r.URL.Path {
+		case "POST /services/data/v36.0/jobs/ingest":
+			body := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["object"] != "Contact" || body["operation"] != "insert" || body["contentType"] != "CSV" {
+				t.Errorf("Unexpected job: %v", body)
+			}
+			fmt.Fprint(w, `{"id":"750000000000001","object":"Contact","operation":"insert","state":"Open"}`)
+		case "PUT " + jobUri + "/batches":
+			if r.Header.Get("Content-Type") != "text/csv" {
+				t.Errorf("Unexpected content type: %v", r.Header.Get("Content-Type"))
+			}
+			body, _ := io.ReadAll(r.Body)
+			uploaded = string(body)
+			w.WriteHeader(http.StatusCreated)
+		case "PATCH " + jobUri:
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != `{"state":"UploadComplete"}` {
+				t.Errorf("Unexpected state change: %s", body)
+			}
+			fmt.Fprint(w, `{"id":"750000000000001","state":"UploadComplete"}`)
+		case "GET " + jobUri:
+			polls++
+			state := "InProgress"
+			if polls == 3 {
+				state = "JobComplete"
+			}
+			fmt.Fprintf(w, `{"id":"750000000000001","state":%q,"numberRecordsProcessed":3,"numberRecordsFailed":1}`, state)
+		case "GET " + jobUri + "/successfulResults/":
+			fmt.Fprint(w, "\"sf__Id\",\"sf__Created\",LastName,Number_Of_Pets__c,DoNotCall,Birthdate\n"+
+				"003000000000001,true,Smith,2,true,1990-05-01T00:00:00.000+0000\n"+
+				"003000000000002,true,\"Jones, Jr.\",0,,\n")
+		case "GET " + jobUri + "/failedResults/":
+			fmt.Fprint(w, "\"sf__Id\",\"sf__Error\",LastName,Number_Of_Pets__c,DoNotCall,Birthdate\n"+
+				",REQUIRED_FIELD_MISSING:Required fields are missing: [LastName]:LastName --,,1,,\n")
+		case "GET " + jobUri + "/unprocessedrecords/":
+			fmt.Fprint(w, "")
+		default:
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		}
+	})
+
+	birthdate := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	records := make(chan SObject, 3)
+	records <- &bulkContact{LastName: "Smith", NumberOfPets: 2, DoNotCall: true, Birthdate: sobjects.AsTime(birthdate), FormulaResult: "ignored"}
+	records <- &bulkContact{LastName: "Jones, Jr."}
+	records <- &bulkContact{NumberOfPets: 1}
+	close(records)
+
+	job, err := forceApi.CreateIngestJob(&bulkContact{}, BulkInsert)
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if err := forceApi.UploadIngestJobStream(job, records); err != nil {
+		t.Fatalf("Failed to upload job: %v", err)
+	}
+
+	want := "LastName,Number_Of_Pets__c,DoNotCall,Birthdate,External_Id__c\n" +
+		"Smith,2,true,1990-05-01T00:00:00.000+0000,\n" +
+		"\"Jones, Jr.\",0,,,\n" +
+		",1,,,\n"
+	if uploaded != want {
+		t.Fatalf("Unexpected upload:\n%v\nwant:\n%v", uploaded, want)
+	}
+
+	if job, err = forceApi.CloseIngestJob(job.Id); err != nil || job.State != JobStateUploadComplete {
+		t.Fatalf("Failed to close job: %+v %v", job, err)
+	}
+	if job, err = forceApi.WaitIngestJob(job.Id); err != nil || job.State != JobStateJobComplete || polls != 3 {
+		t.Fatalf("Failed to wait for job: %+v %v", job, err)
+	}
+
+	successful, err := GetIngestSuccessfulResults[bulkContact](context.Background(), forceApi, job.Id)
+	if err != nil {
+		t.Fatalf("Failed to get successful results: %v", err)
+	}
+	if len(successful) != 2 || successful[0].Id != "003000000000001" || !successful[0].Created {
+		t.Fatalf("Unexpected successful results: %+v", successful)
+	}
+	first := successful[0].Record
+	if first.LastName != "Smith" || first.NumberOfPets != 2 || !first.DoNotCall || !first.Birthdate.Time().Equal(birthdate) {
+		t.Fatalf("Unexpected record: %+v", first)
+	}
+	if successful[1].Record.LastName != "Jones, Jr." || successful[1].Record.Birthdate != nil {
+		t.Fatalf("Unexpected record: %+v", successful[1].Record)
+	}
+
+	failed, err := GetIngestFailedResults[*bulkContact](context.Background(), forceApi, job.Id)
+	if err != nil {
+		t.Fatalf("Failed to get failed results: %v", err)
+	}
+	wantErr := &ApiError{ErrorCode: "REQUIRED_FIELD_MISSING", Message: "Required fields are missing: [LastName]", Fields: []string{"LastName"}}
+	if len(failed) != 1 || !reflect.DeepEqual(failed[0].Error, wantErr) || failed[0].Record.NumberOfPets != 1 {
+		t.Fatalf("Unexpected failed results: %+v", failed)
+	}
+
+	unprocessed, err := GetIngestUnprocessedRecords[bulkContact](context.Background(), forceApi, job.Id)
+	if err != nil || len(unprocessed) != 0 {
+		t.Fatalf("Unexpected unprocessed records: %+v %v", unprocessed, err)
+	}
+}
+
+func TestUploadIngestJobColumns(t *testing.T) {
+	var uploaded string
+	forceApi := createBulkTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		uploaded = string(body)
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	contact := &bulkContact{LastName: "Smith", ExternalId: "ext-1"}
+	contact.Id = "003000000000001"
+
+	tests := []struct {
+		job  *BulkJob
+		want string
+	}{
+		{&BulkJob{Object: "Contact", Operation: "update"}, "Id,LastName,Number_Of_Pets__c,DoNotCall,Birthdate,External_Id__c\n003000000000001,Smith,0,,,ext-1\n"},
+		{&BulkJob{Object: "Contact", Operation: "upsert", ExternalIdFieldName: "External_Id__c"}, "LastName,Number_Of_Pets__c,DoNotCall,Birthdate,External_Id__c\nSmith,0,,,ext-1\n"},
+		{&BulkJob{Object: "Contact", Operation: "delete"}, "Id\n003000000000001\n"},
+	}
+	for _, test := range tests {
+		if err := forceApi.UploadIngestJob(test.job, []SObject{contact}); err != nil {
+			t.Fatalf("Failed to upload %v job: %v", test.job.Operation, err)
+		}
+		if uploaded != test.want {
+			t.Errorf("Unexpected %v upload:\n%v\nwant:\n%v", test.job.Operation, uploaded, test.want)
+		}
+	}
+
+	if err := forceApi.UploadIngestJob(&BulkJob{Object: "Account", Operation: "insert"}, []SObject{contact}); err == nil {
+		t.Error("Expected upload to a job of another object to fail")
+	}
+	mixed := []SObject{contact, &collectionContact{LastName: "Other"}}
+	if err := forceApi.UploadIngestJob(&BulkJob{Object: "Contact", Operation: "insert"}, mixed); err == nil {
+		t.Error("Expected upload of mixed record types to fail")
+	}
+}
+
+func TestWaitIngestJobFailed(t *testing.T) {
+	setBulkPollInterval(t, time.Millisecond)
+
+	forceApi := createBulkTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"750000000000001","state":"Failed","errorMessage":"InvalidBatch : Field name not found : Foo"}`)
+	})
+
+	job, err := forceApi.WaitIngestJob("750000000000001")
+	if err == nil || !strings.Contains(err.Error(), "Field name not found") || job.State != JobStateFailed {
+		t.Fatalf("Expected failed job, got %+v %v", job, err)
+	}
+}
+
+func TestIngestResultsError(t *testing.T) {
+	forceApi := createBulkTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `[{"errorCode":"NOT_FOUND","message":"The requested resource does not exist"}]`)
+	})
+
+	_, err := GetIngestSuccessfulResults[bulkContact](context.Background(), forceApi, "750000000000001")
+	if apiErrs, ok := err.(ApiErrors); !ok || apiErrs[0].ErrorCode != "NOT_FOUND" {
+		t.Fatalf("Expected ApiErrors, got %v", err)
+	}
+}
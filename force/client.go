@@ -2,6 +2,7 @@ package force
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"strings"
 
 	"github.com/nimajalali/go-force/forcejson"
 )
@@ -23,48 +25,59 @@ const (
 // Get issues a GET to the specified path with the given params and put the
 // umarshalled (json) result in the third parameter
 func (forceApi *ForceApi) Get(path string, params url.Values, out interface{}) error {
-	return forceApi.request("GET", path, params, nil, out)
+	return forceApi.GetContext(context.Background(), path, params, out)
+}
+
+// GetContext is like Get but carries ctx through the request.
+func (forceApi *ForceApi) GetContext(ctx context.Context, path string, params url.Values, out interface{}) error {
+	return forceApi.request(ctx, "GET", path, params, nil, out)
 }
 
 // Post issues a POST to the specified path with the given params and payload
 // and put the unmarshalled (json) result in the third parameter
 func (forceApi *ForceApi) Post(path string, params url.Values, payload, out interface{}) error {
-	return forceApi.request("POST", path, params, payload, out)
+	return forceApi.PostContext(context.Background(), path, params, payload, out)
+}
+
+// PostContext is like Post but carries ctx through the request.
+func (forceApi *ForceApi) PostContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error {
+	return forceApi.request(ctx, "POST", path, params, payload, out)
 }
 
 // Put issues a PUT to the specified path with the given params and payload
 // and put the unmarshalled (json) result in the third parameter
 func (forceApi *ForceApi) Put(path string, params url.Values, payload, out interface{}) error {
-	return forceApi.request("PUT", path, params, payload, out)
+	return forceApi.PutContext(context.Background(), path, params, payload, out)
+}
+
+// PutContext is like Put but carries ctx through the request.
+func (forceApi *ForceApi) PutContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error {
+	return forceApi.request(ctx, "PUT", path, params, payload, out)
 }
 
 // Patch issues a PATCH to the specified path with the given params and payload
 // and put the unmarshalled (json) result in the third parameter
 func (forceApi *ForceApi) Patch(path string, params url.Values, payload, out interface{}) error {
-	return forceApi.request("PATCH", path, params, payload, out)
+	return forceApi.PatchContext(context.Background(), path, params, payload, out)
+}
+
+// PatchContext is like Patch but carries ctx through the request.
+func (forceApi *ForceApi) PatchContext(ctx context.Context, path string, params url.Values, payload, out interface{}) error {
+	return forceApi.request(ctx, "PATCH", path, params, payload, out)
 }
 
 // Delete issues a DELETE to the specified path with the given payload
 func (forceApi *ForceApi) Delete(path string, params url.Values) error {
-	return forceApi.request("DELETE", path, params, nil, nil)
+	return forceApi.DeleteContext(context.Background(), path, params)
 }
 
-func (forceApi *ForceApi) request(method, path string, params url.Values, payload, out interface{}) error {
-	if err := forceApi.oauth.Validate(); err != nil {
-		return fmt.Errorf("Error creating %v request: %v", method, err)
-	}
-
-	// Build Uri
-	var uri bytes.Buffer
-	uri.WriteString(forceApi.oauth.InstanceUrl)
-	uri.WriteString(path)
-	if params != nil && len(params) != 0 {
-		uri.WriteString("?")
-		uri.WriteString(params.Encode())
-	}
+// DeleteContext is like Delete but carries ctx through the request.
+func (forceApi *ForceApi) DeleteContext(ctx context.Context, path string, params url.Values) error {
+	return forceApi.request(ctx, "DELETE", path, params, nil, nil)
+}
 
+func (forceApi *ForceApi) request(ctx context.Context, method, path string, params url.Values, payload, out interface{}) error {
 	// Build body
-	var body io.Reader
 	var jsonBytes []byte
 	if payload != nil {
 		var err error
@@ -78,111 +91,205 @@ func (forceApi *ForceApi) request(method, path string, params url.Values, payloa
 		if err != nil {
 			return fmt.Errorf("Error marshaling encoded payload: %v", err)
 		}
-
-		body = bytes.NewReader(jsonBytes)
 	}
 
-	if forceApi.debugMode {
-		fmt.Printf("debug: salesforce request url: %s\n", uri.String())
+	reauthenticated := false
+	for attempt := 1; ; {
+		req, resp, respBytes, err := forceApi.send(ctx, method, path, params, jsonBytes)
+		if err != nil {
+			if ctx.Err() == nil && forceApi.retryPolicy.shouldRetry(method, attempt, 0, nil, err) {
+				if err := sleepContext(ctx, forceApi.retryPolicy.backoff(attempt, "")); err != nil {
+					return err
+				}
+				attempt++
+				continue
+			}
+
+			return err
+		}
 
-		if body != nil {
-			reqBytes, err := ioutil.ReadAll(body)
-			if err != nil {
-				return fmt.Errorf("Error reading request bytes: %v", err)
+		// Sometimes the force API returns no body, we should catch this early
+		if resp.StatusCode == http.StatusNoContent {
+			return nil
+		}
+
+		// Attempt to parse response as a force.com api error
+		apiErrors := ApiErrors{}
+		if resp.StatusCode >= http.StatusMultipleChoices {
+			if marshalErr := forcejson.Unmarshal(respBytes, &apiErrors); marshalErr != nil || !apiErrors.Validate() {
+				apiErrors = nil
 			}
-			fmt.Printf("debug: salesforce request body: %s\n", string(reqBytes))
-			body = bytes.NewBuffer(reqBytes)
 		}
+
+		// Check if error is oauth token expired
+		if !reauthenticated && forceApi.oauth.Expired(apiErrors) {
+			// Reauthenticate then attempt request again
+			oauthErr := forceApi.oauth.reauthenticate(ctx, requestToken(req))
+			if oauthErr != nil {
+				return oauthErr
+			}
+
+			reauthenticated = true
+			continue
+		}
+
+		if resp.StatusCode >= http.StatusMultipleChoices && forceApi.retryPolicy.shouldRetry(method, attempt, resp.StatusCode, apiErrors, nil) {
+			if err := sleepContext(ctx, forceApi.retryPolicy.backoff(attempt, resp.Header.Get("Retry-After"))); err != nil {
+				return err
+			}
+			attempt++
+			continue
+		}
+
+		if apiErrors.Validate() {
+			apiErrors.setHTTPStatusCode(resp.StatusCode)
+			apiErrors[0].RequestURL = req.URL.String()
+			apiErrors[0].RequestBody = string(jsonBytes)
+
+			return apiErrors
+		}
+
+		// Attempt to parse response into out
+		var objectUnmarshalErr error
+		if out != nil {
+			objectUnmarshalErr = forcejson.Unmarshal(respBytes, out)
+		}
+
+		if objectUnmarshalErr != nil {
+			// Not a force.com api error. Just an unmarshalling error.
+			return fmt.Errorf("unable to unmarshal response to object: %v (response: %s)", objectUnmarshalErr, string(respBytes))
+		}
+
+		// Sometimes no response is expected. For example delete and update. We still have to make sure an error wasn't returned.
+		return nil
 	}
+}
 
-	// Build Request
-	req, err := http.NewRequest(method, uri.String(), body)
-	if err != nil {
-		return fmt.Errorf("Error creating %v request: %v", method, err)
+// send makes a single attempt at an api request and returns the request along
+// with the response, whose body has been read and closed.
+func (forceApi *ForceApi) send(ctx context.Context, method, path string, params url.Values, jsonBytes []byte) (*http.Request, *http.Response, []byte, error) {
+	if err := forceApi.oauth.Validate(); err != nil {
+		return nil, nil, nil, fmt.Errorf("Error creating %v request: %v", method, err)
 	}
 
-	// Add Headers
-	req.Header.Set("User-Agent", userAgent)
-	req.Header.Set("Content-Type", contentType)
-	req.Header.Set("Accept", responseType)
-	req.Header.Set("Authorization", fmt.Sprintf("%v %v", "Bearer", forceApi.oauth.AccessToken))
+	if err := forceApi.governQuota(ctx); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var body io.Reader
+	if jsonBytes != nil {
+		body = bytes.NewReader(jsonBytes)
+	}
+
+	req, err := forceApi.newRequest(ctx, method, path, params, contentType, responseType, body)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	// Send
-	forceApi.traceRequest(req)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := forceApi.client().Do(req)
 	if err != nil {
-		return fmt.Errorf("Error sending %v request: %v", method, err)
+		return nil, nil, nil, fmt.Errorf("Error sending %v request: %w", method, err)
 	}
 
 	defer resp.Body.Close()
-	forceApi.traceResponse(resp)
 
-	// Sometimes the force API returns no body, we should catch this early
-	if resp.StatusCode == http.StatusNoContent {
-		return nil
-	}
+	forceApi.recordApiUsage(resp.Header)
 
 	respBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("Error reading response bytes: %v", err)
+		return nil, nil, nil, fmt.Errorf("Error reading response bytes: %w", err)
 	}
 
-	forceApi.traceResponseBody(respBytes)
+	return req, resp, respBytes, nil
+}
+
+// stream makes a request whose body, if any, is read from body and sent with
+// the given content type. Unlike request, the response body is left for the
+// caller to read and close. An error response is returned as ApiErrors when
+// possible. A request without a body is retried once after reauthenticating
+// if the session expired; a body cannot be replayed.
+func (forceApi *ForceApi) stream(ctx context.Context, method, path string, params url.Values, bodyType, accept string, body io.Reader) (*http.Response, error) {
+	for reauthenticated := false; ; reauthenticated = true {
+		if err := forceApi.oauth.Validate(); err != nil {
+			return nil, fmt.Errorf("Error creating %v request: %v", method, err)
+		}
 
-	// Attempt to parse response into out
-	var objectUnmarshalErr error
-	if out != nil {
-		objectUnmarshalErr = forcejson.Unmarshal(respBytes, out)
-		if objectUnmarshalErr == nil {
-			return nil
+		if err := forceApi.governQuota(ctx); err != nil {
+			return nil, err
 		}
-	}
 
-	// Attempt to parse response as a force.com api error before returning object unmarshal err
-	apiErrors := ApiErrors{}
-	if marshalErr := forcejson.Unmarshal(respBytes, &apiErrors); marshalErr == nil {
-		if apiErrors.Validate() {
-			// Check if error is oauth token expired
-			if forceApi.oauth.Expired(apiErrors) {
-				// Reauthenticate then attempt query again
-				oauthErr := forceApi.oauth.Authenticate()
-				if oauthErr != nil {
-					return oauthErr
-				}
+		req, err := forceApi.newRequest(ctx, method, path, params, bodyType, accept, body)
+		if err != nil {
+			return nil, err
+		}
 
-				return forceApi.request(method, path, params, payload, out)
-			}
+		resp, err := forceApi.client().Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("Error sending %v request: %w", method, err)
+		}
 
-			apiErrors[0].RequestURL = uri.String()
-			apiErrors[0].RequestBody = string(jsonBytes)
+		forceApi.recordApiUsage(resp.Header)
 
-			return apiErrors
+		if resp.StatusCode < http.StatusMultipleChoices {
+			return resp, nil
 		}
-	}
 
-	if objectUnmarshalErr != nil {
-		// Not a force.com api error. Just an unmarshalling error.
-		return fmt.Errorf("unable to unmarshal response to object: %v (response: %s)", objectUnmarshalErr, string(respBytes))
-	}
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading response bytes: %w", err)
+		}
 
-	// Sometimes no response is expected. For example delete and update. We still have to make sure an error wasn't returned.
-	return nil
-}
+		apiErrors := ApiErrors{}
+		if marshalErr := forcejson.Unmarshal(respBytes, &apiErrors); marshalErr != nil || !apiErrors.Validate() {
+			return nil, fmt.Errorf("Error sending %v request: %v (response: %s)", method, resp.Status, string(respBytes))
+		}
 
-func (forceApi *ForceApi) traceRequest(req *http.Request) {
-	if forceApi.logger != nil {
-		forceApi.trace("Request:", req, "%v")
+		if body == nil && !reauthenticated && forceApi.oauth.Expired(apiErrors) {
+			if err := forceApi.oauth.reauthenticate(ctx, requestToken(req)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		apiErrors.setHTTPStatusCode(resp.StatusCode)
+		apiErrors[0].RequestURL = req.URL.String()
+
+		return nil, apiErrors
 	}
 }
 
-func (forceApi *ForceApi) traceResponse(resp *http.Response) {
-	if forceApi.logger != nil {
-		forceApi.trace("Response:", resp, "%v")
+// newRequest builds an authorized request for path on the instance.
+func (forceApi *ForceApi) newRequest(ctx context.Context, method, path string, params url.Values, bodyType, accept string, body io.Reader) (*http.Request, error) {
+	instanceUrl, accessToken := forceApi.oauth.session()
+
+	// Build Uri
+	var uri bytes.Buffer
+	uri.WriteString(instanceUrl)
+	uri.WriteString(path)
+	if params != nil && len(params) != 0 {
+		uri.WriteString("?")
+		uri.WriteString(params.Encode())
+	}
+
+	// Build Request
+	req, err := http.NewRequestWithContext(ctx, method, uri.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating %v request: %v", method, err)
 	}
+
+	// Add Headers
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", bodyType)
+	req.Header.Set("Accept", accept)
+	req.Header.Set("Authorization", fmt.Sprintf("%v %v", "Bearer", accessToken))
+	setRequestHeaders(ctx, req)
+
+	return req, nil
 }
 
-func (forceApi *ForceApi) traceResponseBody(body []byte) {
-	if forceApi.logger != nil {
-		forceApi.trace("Response Body:", string(body), "%s")
-	}
+// requestToken returns the access token req was authorized with.
+func requestToken(req *http.Request) string {
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
 }
@@ -0,0 +1,264 @@
+package force
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	compositeKey = "composite"
+
+	// Maximum number of records accepted by a single sObject Collections request.
+	collectionsBatchSize = 200
+)
+
+type collectionRequest struct {
+	AllOrNone bool                     `force:"allOrNone"`
+	Records   []map[string]interface{} `force:"records"`
+}
+
+// InsertSObjects creates up to 200 records per request using the sObject
+// Collections resource, so a slice of any size costs one api call per 200
+// records. The records may be of different types. Responses are returned in
+// the order of in.
+//
+// With allOrNone set, a failure rolls back every record of the same
+// 200-record request; earlier requests have already been committed. If a
+// request fails as a whole, the responses of the preceding requests are
+// returned along with the error.
+func (forceApi *ForceApi) InsertSObjects(in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	return forceApi.InsertSObjectsContext(context.Background(), in, allOrNone)
+}
+
+// InsertSObjectsContext is like InsertSObjects but carries ctx through the requests.
+func (forceApi *ForceApi) InsertSObjectsContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	uri := forceApi.compositeUri() + "/sobjects"
+
+	return forceApi.sendCollection(ctx, "POST", uri, in, allOrNone, func(record SObject) (map[string]interface{}, error) {
+		return forceApi.collectionRecord(ctx, record, true)
+	})
+}
+
+// UpdateSObjects updates records by their Id field using the sObject
+// Collections resource. See InsertSObjects for batching and allOrNone.
+func (forceApi *ForceApi) UpdateSObjects(in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	return forceApi.UpdateSObjectsContext(context.Background(), in, allOrNone)
+}
+
+// UpdateSObjectsContext is like UpdateSObjects but carries ctx through the requests.
+func (forceApi *ForceApi) UpdateSObjectsContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	uri := forceApi.compositeUri() + "/sobjects"
+
+	return forceApi.sendCollection(ctx, "PATCH", uri, in, allOrNone, func(record SObject) (map[string]interface{}, error) {
+		attributes, err := forceApi.collectionRecord(ctx, record, false)
+		if err != nil {
+			return nil, err
+		}
+
+		id, _ := sObjectFieldValue(record, "Id").(string)
+		if id == "" {
+			return nil, fmt.Errorf("Unable to update %v without an Id", record.ApiName())
+		}
+		attributes["Id"] = id
+
+		return attributes, nil
+	})
+}
+
+// UpsertSObjectsByExternalId inserts or updates records matched on their
+// ExternalIdApiName field using the sObject Collections resource. All records
+// must be of the same type. The Created field of each response reports
+// whether the record was inserted. See InsertSObjects for batching and
+// allOrNone.
+func (forceApi *ForceApi) UpsertSObjectsByExternalId(in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	return forceApi.UpsertSObjectsByExternalIdContext(context.Background(), in, allOrNone)
+}
+
+// UpsertSObjectsByExternalIdContext is like UpsertSObjectsByExternalId but carries ctx through the requests.
+func (forceApi *ForceApi) UpsertSObjectsByExternalIdContext(ctx context.Context, in []SObject, allOrNone bool) ([]*SObjectResponse, error) {
+	if len(in) == 0 {
+		return []*SObjectResponse{}, nil
+	}
+
+	apiName, externalId := in[0].ApiName(), in[0].ExternalIdApiName()
+	if externalId == "" {
+		return nil, fmt.Errorf("Unable to upsert %v without an external id field", apiName)
+	}
+
+	uri := fmt.Sprintf("%v/sobjects/%v/%v", forceApi.compositeUri(), apiName, externalId)
+
+	return forceApi.sendCollection(ctx, "PATCH", uri, in, allOrNone, func(record SObject) (map[string]interface{}, error) {
+		if record.ApiName() != apiName {
+			return nil, fmt.Errorf("Unable to upsert %v and %v in the same request", apiName, record.ApiName())
+		}
+
+		attributes, err := forceApi.collectionRecord(ctx, record, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := attributes[externalId]; !ok {
+			attributes[externalId] = sObjectFieldValue(record, externalId)
+		}
+
+		return attributes, nil
+	})
+}
+
+// DeleteSObjects deletes records by Id using the sObject Collections
+// resource. See InsertSObjects for batching and allOrNone.
+func (forceApi *ForceApi) DeleteSObjects(ids []string, allOrNone bool) ([]*SObjectResponse, error) {
+	return forceApi.DeleteSObjectsContext(context.Background(), ids, allOrNone)
+}
+
+// DeleteSObjectsContext is like DeleteSObjects but carries ctx through the requests.
+func (forceApi *ForceApi) DeleteSObjectsContext(ctx context.Context, ids []string, allOrNone bool) ([]*SObjectResponse, error) {
+	uri := forceApi.compositeUri() + "/sobjects"
+
+	responses := make([]*SObjectResponse, 0, len(ids))
+	for start := 0; start < len(ids); start += collectionsBatchSize {
+		end := start + collectionsBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		params := url.Values{
+			"ids":       {strings.Join(ids[start:end], ",")},
+			"allOrNone": {strconv.FormatBool(allOrNone)},
+		}
+
+		batch := []*SObjectResponse{}
+		if err := forceApi.request(ctx, "DELETE", uri, params, nil, &batch); err != nil {
+			return responses, err
+		}
+
+		responses = append(responses, normalizeCollectionResponses(batch)...)
+	}
+
+	return responses, nil
+}
+
+// sendCollection sends in, in batches of collectionsBatchSize, building each
+// record's payload with build.
+func (forceApi *ForceApi) sendCollection(ctx context.Context, method, uri string, in []SObject, allOrNone bool,
+	build func(SObject) (map[string]interface{}, error)) ([]*SObjectResponse, error) {
+	responses := make([]*SObjectResponse, 0, len(in))
+	for start := 0; start < len(in); start += collectionsBatchSize {
+		end := start + collectionsBatchSize
+		if end > len(in) {
+			end = len(in)
+		}
+
+		payload := collectionRequest{
+			AllOrNone: allOrNone,
+			Records:   make([]map[string]interface{}, 0, end-start),
+		}
+		for _, record := range in[start:end] {
+			attributes, err := build(record)
+			if err != nil {
+				return responses, err
+			}
+			payload.Records = append(payload.Records, attributes)
+		}
+
+		batch := []*SObjectResponse{}
+		if err := forceApi.request(ctx, method, uri, nil, payload, &batch); err != nil {
+			return responses, err
+		}
+
+		responses = append(responses, normalizeCollectionResponses(batch)...)
+	}
+
+	return responses, nil
+}
+
+// collectionRecord builds the payload of a single record, which unlike the
+// single record resources must name its type.
+func (forceApi *ForceApi) collectionRecord(ctx context.Context, in SObject, isInsert bool) (map[string]interface{}, error) {
+	attributes, err := forceApi.GetAttributesContext(ctx, in, nil, isInsert, false)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes["attributes"] = map[string]string{"type": in.ApiName()}
+
+	return attributes, nil
+}
+
+// normalizeCollectionResponses copies the statusCode reported for per-record
+// errors to ErrorCode, which is where every other error reports its code.
+func normalizeCollectionResponses(responses []*SObjectResponse) []*SObjectResponse {
+	for _, response := range responses {
+		for _, err := range response.Errors {
+			if err.ErrorCode == "" {
+				err.ErrorCode = err.StatusCode
+			}
+		}
+	}
+
+	return responses
+}
+
+func (forceApi *ForceApi) compositeUri() string {
+	if uri, ok := forceApi.apiResources[compositeKey]; ok {
+		return uri
+	}
+
+	return fmt.Sprintf(resourcesUri, forceApi.apiVersion) + "/composite"
+}
+
+// sObjectFieldValue returns the value of the field of in encoded under name,
+// or nil if there is no such field.
+func sObjectFieldValue(in SObject, name string) interface{} {
+	ref := reflect.ValueOf(in)
+	for ref.Kind() == reflect.Pointer || ref.Kind() == reflect.Interface {
+		if ref.IsNil() {
+			return nil
+		}
+		ref = ref.Elem()
+	}
+
+	return structFieldValue(ref, name)
+}
+
+func structFieldValue(ref reflect.Value, name string) interface{} {
+	if ref.Kind() != reflect.Struct {
+		return nil
+	}
+
+	// Fields of the outer struct shadow those of embedded structs.
+	rt := ref.Type()
+	var embedded []reflect.Value
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fieldName, tagged := forceFieldName(field)
+
+		if field.Anonymous && !tagged {
+			value := ref.Field(i)
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			embedded = append(embedded, value)
+			continue
+		}
+
+		if field.IsExported() && fieldName == name {
+			return ref.Field(i).Interface()
+		}
+	}
+
+	for _, value := range embedded {
+		if found := structFieldValue(value, name); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
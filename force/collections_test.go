@@ -0,0 +1,190 @@
+package force
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type collectionContact struct {
+	sobjects.BaseSObject
+	LastName   string `force:"LastName,omitempty"`
+	ExternalId string `force:"External_Id__c,omitempty"`
+	AccountId  string `force:"AccountId,omitempty"`
+}
+
+func (c *collectionContact) ApiName() string {
+	return "Contact"
+}
+
+func (c *collectionContact) ExternalIdApiName() string {
+	return "External_Id__c"
+}
+
+func createCollectionsTestServer(t *testing.T, handler func(method, path string, body map[string]interface{}) string) *ForceApi {
+	return createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		if r.Body != nil {
+			json.NewDecoder(r.Body).Decode(&body)
+		}
+		fmt.Fprint(w, handler(r.Method, r.URL.RequestURI(), body))
+	}))
+}
+
+func collectionContacts(n int) []SObject {
+	contacts := make([]SObject, n)
+	for i := range contacts {
+		contact := &collectionContact{LastName: fmt.Sprintf("Contact %d", i), ExternalId: fmt.Sprintf("ext-%d", i), AccountId: AccountId}
+		contact.Id = fmt.Sprintf("003%012d", i)
+		contacts[i] = contact
+	}
+
+	return contacts
+}
+
+// collectionResults answers each record of body with its LastName as id,
+// failing records whose name ends in 7.
+func collectionResults(body map[string]interface{}) string {
+	results := []string{}
+	for _, record := range body["records"].([]interface{}) {
+		name := record.(map[string]interface{})["LastName"].(string)
+		if strings.HasSuffix(name, "7") {
+			results = append(results, `{"success":false,"errors":[{"statusCode":"REQUIRED_FIELD_MISSING","message":"missing","fields":["Email"]}]}`)
+			continue
+		}
+		results = append(results, fmt.Sprintf(`{"id":%q,"success":true,"errors":[],"created":true}`, name))
+	}
+
+	return "[" + strings.Join(results, ",") + "]"
+}
+
+func TestInsertSObjects(t *testing.T) {
+	var batches []int
+	forceApi := createCollectionsTestServer(t, func(method, path string, body map[string]interface{}) string {
+		if method != "POST" || path != "/services/data/v36.0/composite/sobjects" || body["allOrNone"] != true {
+			t.Errorf("Unexpected request: %v %v %v", method, path, body["allOrNone"])
+		}
+
+		records := body["records"].([]interface{})
+		batches = append(batches, len(records))
+		for _, record := range records {
+			fields := record.(map[string]interface{})
+			if fields["attributes"].(map[string]interface{})["type"] != "Contact" || fields["Id"] != nil || fields["AccountId"] != AccountId {
+				t.Errorf("Unexpected record payload: %v", fields)
+			}
+		}
+
+		return collectionResults(body)
+	})
+
+	responses, err := forceApi.InsertSObjects(collectionContacts(450), true)
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if fmt.Sprint(batches) != "[200 200 50]" {
+		t.Fatalf("Unexpected batches: %v", batches)
+	}
+	if len(responses) != 450 {
+		t.Fatalf("Expected 450 responses, got %d", len(responses))
+	}
+	for i, response := range responses {
+		name := fmt.Sprintf("Contact %d", i)
+		if strings.HasSuffix(name, "7") {
+			if response.Success || response.Errors[0].ErrorCode != "REQUIRED_FIELD_MISSING" {
+				t.Fatalf("Expected record %d to fail: %+v", i, response)
+			}
+		} else if !response.Success || response.Id != name {
+			t.Fatalf("Response %d is not aligned with its record: %+v", i, response)
+		}
+	}
+}
+
+func TestUpdateSObjects(t *testing.T) {
+	forceApi := createCollectionsTestServer(t, func(method, path string, body map[string]interface{}) string {
+		if method != "PATCH" || path != "/services/data/v36.0/composite/sobjects" {
+			t.Errorf("Unexpected request: %v %v", method, path)
+		}
+		for i, record := range body["records"].([]interface{}) {
+			if id := record.(map[string]interface{})["Id"]; id != fmt.Sprintf("003%012d", i) {
+				t.Errorf("Unexpected record id: %v", id)
+			}
+		}
+
+		return collectionResults(body)
+	})
+
+	responses, err := forceApi.UpdateSObjects(collectionContacts(3), false)
+	if err != nil || len(responses) != 3 {
+		t.Fatalf("Failed to update: %v %v", responses, err)
+	}
+
+	if _, err := forceApi.UpdateSObjects([]SObject{&collectionContact{LastName: "No Id"}}, false); err == nil {
+		t.Fatal("Expected update without an Id to fail")
+	}
+}
+
+func TestUpsertSObjectsByExternalId(t *testing.T) {
+	forceApi := createCollectionsTestServer(t, func(method, path string, body map[string]interface{}) string {
+		if method != "PATCH" || path != "/services/data/v36.0/composite/sobjects/Contact/External_Id__c" {
+			t.Errorf("Unexpected request: %v %v", method, path)
+		}
+		for i, record := range body["records"].([]interface{}) {
+			if ext := record.(map[string]interface{})["External_Id__c"]; ext != fmt.Sprintf("ext-%d", i) {
+				t.Errorf("Unexpected external id: %v", ext)
+			}
+		}
+
+		return collectionResults(body)
+	})
+
+	responses, err := forceApi.UpsertSObjectsByExternalId(collectionContacts(2), false)
+	if err != nil || len(responses) != 2 || !responses[0].Created {
+		t.Fatalf("Failed to upsert: %v %v", responses, err)
+	}
+
+	mixed := append(collectionContacts(1), &sobjects.Account{})
+	if _, err := forceApi.UpsertSObjectsByExternalId(mixed, false); err == nil {
+		t.Fatal("Expected upsert of mixed types to fail")
+	}
+}
+
+func TestDeleteSObjects(t *testing.T) {
+	var requests []string
+	forceApi := createCollectionsTestServer(t, func(method, path string, body map[string]interface{}) string {
+		requests = append(requests, method+" "+path)
+		return `[{"id":"003000000000000","success":true,"errors":[]}]`
+	})
+
+	ids := make([]string, 201)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("003%012d", i)
+	}
+
+	responses, err := forceApi.DeleteSObjects(ids, true)
+	if err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if len(requests) != 2 || len(responses) != 2 {
+		t.Fatalf("Expected 2 batches, got %v", requests)
+	}
+	if !strings.HasPrefix(requests[1], "DELETE /services/data/v36.0/composite/sobjects?allOrNone=true&ids=003000000000200") {
+		t.Fatalf("Unexpected request: %v", requests[1])
+	}
+}
+
+func TestInsertSObjectsRequestError(t *testing.T) {
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `[{"message":"Invalid JSON","errorCode":"JSON_PARSER_ERROR"}]`)
+	}))
+
+	_, err := forceApi.InsertSObjects(collectionContacts(1), false)
+	if err == nil || !strings.Contains(err.Error(), "JSON_PARSER_ERROR") {
+		t.Fatalf("Expected request error, got: %v", err)
+	}
+}
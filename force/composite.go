@@ -0,0 +1,310 @@
+package force
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+// Maximum number of subrequests accepted by a single Composite request.
+const compositeMaxSubrequests = 25
+
+// CompositeRequest batches up to 25 subrequests into a single call to the
+// Composite resource. Later subrequests can use the results of earlier ones
+// through CompositeRef. Errors made while building the request are reported
+// by Send.
+type CompositeRequest struct {
+	forceApi    *ForceApi
+	allOrNone   bool
+	subrequests []*compositeSubrequest
+	err         error
+}
+
+type compositeSubrequest struct {
+	method      string
+	uri         string
+	referenceId string
+	in          SObject
+	isInsert    bool
+	omitField   string
+	out         interface{}
+}
+
+type compositeRequestBody struct {
+	AllOrNone   bool                      `force:"allOrNone"`
+	Subrequests []compositeSubrequestBody `force:"compositeRequest"`
+}
+
+type compositeSubrequestBody struct {
+	Method      string                 `force:"method"`
+	Url         string                 `force:"url"`
+	ReferenceId string                 `force:"referenceId"`
+	Body        map[string]interface{} `force:"body,omitempty"`
+}
+
+// CompositeResponse holds the result of every subrequest of a Composite
+// request, in the order they were added.
+type CompositeResponse struct {
+	Responses []*CompositeSubresponse `force:"compositeResponse"`
+}
+
+// CompositeSubresponse is the result of a single subrequest.
+type CompositeSubresponse struct {
+	Body           forcejson.RawMessage `force:"body"`
+	HttpHeaders    map[string]string    `force:"httpHeaders"`
+	HttpStatusCode int                  `force:"httpStatusCode"`
+	ReferenceId    string               `force:"referenceId"`
+}
+
+// CompositeRef returns a reference to field of the result of the subrequest
+// named referenceId, such as "@{account.id}", for use as a field value, an id
+// or in a query of a later subrequest.
+func CompositeRef(referenceId, field string) string {
+	return fmt.Sprintf("@{%v.%v}", referenceId, field)
+}
+
+// NewCompositeRequest starts a Composite request. With allOrNone set, a
+// failed subrequest rolls back all of them.
+func (forceApi *ForceApi) NewCompositeRequest(allOrNone bool) *CompositeRequest {
+	return &CompositeRequest{
+		forceApi:  forceApi,
+		allOrNone: allOrNone,
+	}
+}
+
+// Insert adds a subrequest creating in. Its id is available as
+// CompositeRef(referenceId, "id").
+func (req *CompositeRequest) Insert(referenceId string, in SObject) *CompositeRequest {
+	uri := req.sObjectUri(in, sObjectKey)
+
+	return req.add(&compositeSubrequest{method: "POST", uri: uri, referenceId: referenceId, in: in, isInsert: true})
+}
+
+// Update adds a subrequest updating the record with the given id, which may
+// be a CompositeRef.
+func (req *CompositeRequest) Update(referenceId, id string, in SObject) *CompositeRequest {
+	uri := strings.Replace(req.sObjectUri(in, rowTemplateKey), idKey, escapeCompositeId(id), 1)
+
+	return req.add(&compositeSubrequest{method: "PATCH", uri: uri, referenceId: referenceId, in: in})
+}
+
+// Upsert adds a subrequest inserting or updating the record whose
+// ExternalIdApiName field equals id.
+func (req *CompositeRequest) Upsert(referenceId, id string, in SObject) *CompositeRequest {
+	uri := fmt.Sprintf("%v/%v/%v", req.sObjectUri(in, sObjectKey), in.ExternalIdApiName(), escapeCompositeId(id))
+
+	return req.add(&compositeSubrequest{method: "PATCH", uri: uri, referenceId: referenceId, in: in, omitField: in.ExternalIdApiName()})
+}
+
+// Delete adds a subrequest deleting the record of in's type with the given id.
+func (req *CompositeRequest) Delete(referenceId, id string, in SObject) *CompositeRequest {
+	uri := strings.Replace(req.sObjectUri(in, rowTemplateKey), idKey, escapeCompositeId(id), 1)
+
+	return req.add(&compositeSubrequest{method: "DELETE", uri: uri, referenceId: referenceId})
+}
+
+// Get adds a subrequest retrieving the record with the given id. When the
+// request is sent, a successful result is decoded into out.
+func (req *CompositeRequest) Get(referenceId, id string, fields []string, out SObject) *CompositeRequest {
+	uri := strings.Replace(req.sObjectUri(out, rowTemplateKey), idKey, escapeCompositeId(id), 1)
+	if len(fields) > 0 {
+		uri += "?" + url.Values{"fields": {strings.Join(fields, ",")}}.Encode()
+	}
+
+	return req.add(&compositeSubrequest{method: "GET", uri: uri, referenceId: referenceId, out: out})
+}
+
+// Query adds a subrequest running a SOQL query. When the request is sent, a
+// successful result is decoded into out, which should embed
+// sobjects.BaseQuery like the argument of Query.
+func (req *CompositeRequest) Query(referenceId, query string, out interface{}) *CompositeRequest {
+	uri := req.forceApi.apiResources[queryKey] + "?" + url.Values{"q": {query}}.Encode()
+
+	return req.add(&compositeSubrequest{method: "GET", uri: uri, referenceId: referenceId, out: out})
+}
+
+// Send sends the request. A subrequest that failed does not make Send fail;
+// check each CompositeSubresponse instead.
+func (req *CompositeRequest) Send() (*CompositeResponse, error) {
+	return req.SendContext(context.Background())
+}
+
+// SendContext is like Send but carries ctx through the request.
+func (req *CompositeRequest) SendContext(ctx context.Context) (*CompositeResponse, error) {
+	if req.err != nil {
+		return nil, req.err
+	}
+	if len(req.subrequests) == 0 {
+		return nil, fmt.Errorf("Error sending composite request: no subrequests")
+	}
+
+	payload := compositeRequestBody{
+		AllOrNone:   req.allOrNone,
+		Subrequests: make([]compositeSubrequestBody, len(req.subrequests)),
+	}
+	for i, sub := range req.subrequests {
+		payload.Subrequests[i] = compositeSubrequestBody{
+			Method:      sub.method,
+			Url:         sub.uri,
+			ReferenceId: sub.referenceId,
+		}
+
+		if sub.in != nil {
+			attributes, err := req.forceApi.GetAttributesContext(ctx, sub.in, nil, sub.isInsert, false)
+			if err != nil {
+				return nil, err
+			}
+			if sub.omitField != "" {
+				delete(attributes, sub.omitField)
+			}
+			payload.Subrequests[i].Body = attributes
+		}
+	}
+
+	resp := &CompositeResponse{}
+	if err := req.forceApi.PostContext(ctx, req.forceApi.compositeUri(), nil, payload, resp); err != nil {
+		return nil, err
+	}
+
+	for i, sub := range req.subrequests {
+		if sub.out == nil || i >= len(resp.Responses) || !resp.Responses[i].Success() {
+			continue
+		}
+		if err := resp.Responses[i].Decode(sub.out); err != nil {
+			return resp, err
+		}
+	}
+
+	return resp, nil
+}
+
+func (req *CompositeRequest) add(sub *compositeSubrequest) *CompositeRequest {
+	if req.err != nil {
+		return req
+	}
+
+	switch {
+	case !isReferenceId(sub.referenceId):
+		req.err = fmt.Errorf("Error building composite request: invalid reference id %q", sub.referenceId)
+	case req.hasReference(sub.referenceId):
+		req.err = fmt.Errorf("Error building composite request: duplicate reference id %q", sub.referenceId)
+	case len(req.subrequests) == compositeMaxSubrequests:
+		req.err = fmt.Errorf("Error building composite request: more than %d subrequests", compositeMaxSubrequests)
+	default:
+		req.subrequests = append(req.subrequests, sub)
+	}
+
+	return req
+}
+
+// isReferenceId reports whether s can name a subrequest: a letter followed by
+// letters, digits and underscores.
+func isReferenceId(s string) bool {
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r == '_' || r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// escapeCompositeId escapes id for use in the url of a subrequest. References
+// made with CompositeRef are left for the server to resolve.
+func escapeCompositeId(id string) string {
+	if strings.HasPrefix(id, "@{") && strings.HasSuffix(id, "}") {
+		return id
+	}
+
+	return url.PathEscape(id)
+}
+
+func (req *CompositeRequest) hasReference(referenceId string) bool {
+	for _, sub := range req.subrequests {
+		if sub.referenceId == referenceId {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (req *CompositeRequest) sObjectUri(in SObject, key string) string {
+	metaData, ok := req.forceApi.sObject(in.ApiName())
+	if !ok {
+		if req.err == nil {
+			req.err = fmt.Errorf("Unable to find metadata for object: %v", in.ApiName())
+		}
+		return ""
+	}
+
+	return metaData.URLs[key]
+}
+
+// Get returns the result of the subrequest named referenceId, or nil.
+func (resp *CompositeResponse) Get(referenceId string) *CompositeSubresponse {
+	for _, sub := range resp.Responses {
+		if sub.ReferenceId == referenceId {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// Success reports whether the subrequest succeeded.
+func (sub *CompositeSubresponse) Success() bool {
+	return sub.HttpStatusCode >= 200 && sub.HttpStatusCode < 300
+}
+
+// Errors returns the errors reported by a failed subrequest. Subrequests
+// that were not run because another one failed with allOrNone set report
+// PROCESSING_HALTED.
+func (sub *CompositeSubresponse) Errors() ApiErrors {
+	if sub.Success() {
+		return nil
+	}
+
+	apiErrs := ApiErrors{}
+	if err := forcejson.Unmarshal(sub.Body, &apiErrs); err != nil {
+		apiErrs = ApiErrors{{Message: string(sub.Body)}}
+	}
+	apiErrs.setHTTPStatusCode(sub.HttpStatusCode)
+
+	return apiErrs
+}
+
+// SObjectResponse decodes the result of an insert or upsert subrequest.
+func (sub *CompositeSubresponse) SObjectResponse() (*SObjectResponse, error) {
+	if apiErrs := sub.Errors(); apiErrs.Validate() {
+		return nil, apiErrs
+	}
+
+	resp := &SObjectResponse{}
+	if len(sub.Body) == 0 || string(sub.Body) == "null" {
+		// Updates and deletes respond without a body.
+		resp.Success = true
+		return resp, nil
+	}
+
+	if err := sub.Decode(resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Decode unmarshals the body of the subrequest into out.
+func (sub *CompositeSubresponse) Decode(out interface{}) error {
+	if err := forcejson.Unmarshal(sub.Body, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal composite response %v: %v", sub.ReferenceId, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package force
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type compositeOpportunity struct {
+	sobjects.BaseSObject
+	Name      string `force:"Name,omitempty"`
+	AccountId string `force:"AccountId,omitempty"`
+}
+
+func (o *compositeOpportunity) ApiName() string {
+	return "Opportunity"
+}
+
+func createCompositeTestServer(t *testing.T, handler func(body map[string]interface{}) string) *ForceApi {
+	return createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/services/data/v36.0/composite" {
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		}
+
+		body := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		fmt.Fprint(w, handler(body))
+	}))
+}
+
+func TestCompositeRequest(t *testing.T) {
+	forceApi := createCompositeTestServer(t, func(body map[string]interface{}) string {
+		if body["allOrNone"] != true {
+			t.Errorf("Expected allOrNone, got %v", body["allOrNone"])
+		}
+
+		subrequests := body["compositeRequest"].([]interface{})
+		want := []string{
+			"POST /services/data/v36.0/sobjects/Opportunity opportunity",
+			"PATCH /services/data/v36.0/sobjects/Opportunity/@{opportunity.id} rename",
+			"GET /services/data/v36.0/sobjects/Account/001000000000001?fields=Id%2CName account",
+			"GET /services/data/v36.0/query?q=SELECT+Id+FROM+Opportunity+WHERE+Id+%3D+%27%40%7Bopportunity.id%7D%27 query",
+			"DELETE /services/data/v36.0/sobjects/Account/001000000000002 delete",
+		}
+		for i, sub := range subrequests {
+			fields := sub.(map[string]interface{})
+			got := fmt.Sprintf("%v %v %v", fields["method"], fields["url"], fields["referenceId"])
+			if got != want[i] {
+				t.Errorf("Unexpected subrequest %d: %v", i, got)
+			}
+		}
+
+		insertBody := subrequests[0].(map[string]interface{})["body"].(map[string]interface{})
+		if insertBody["AccountId"] != "@{account.id}" || insertBody["Name"] != "Acme" {
+			t.Errorf("Unexpected insert body: %v", insertBody)
+		}
+
+		return `{"compositeResponse":[
+			{"body":{"id":"006000000000001","success":true,"errors":[]},"httpHeaders":{"Location":"/services/data/v36.0/sobjects/Opportunity/006000000000001"},"httpStatusCode":201,"referenceId":"opportunity"},
+			{"body":null,"httpHeaders":{},"httpStatusCode":204,"referenceId":"rename"},
+			{"body":{"attributes":{"type":"Account"},"Id":"001000000000001","Name":"Acme"},"httpHeaders":{},"httpStatusCode":200,"referenceId":"account"},
+			{"body":{"totalSize":1,"done":true,"records":[{"Id":"006000000000001"}]},"httpHeaders":{},"httpStatusCode":200,"referenceId":"query"},
+			{"body":[{"errorCode":"ENTITY_IS_DELETED","message":"entity is deleted","fields":[]}],"httpHeaders":{},"httpStatusCode":404,"referenceId":"delete"}
+		]}`
+	})
+
+	account := &sobjects.Account{}
+	query := &struct {
+		sobjects.BaseQuery
+		Records []sobjects.BaseSObject `force:"records"`
+	}{}
+
+	resp, err := forceApi.NewCompositeRequest(true).
+		Insert("opportunity", &compositeOpportunity{Name: "Acme", AccountId: CompositeRef("account", "id")}).
+		Update("rename", CompositeRef("opportunity", "id"), &compositeOpportunity{Name: "Acme 2"}).
+		Get("account", "001000000000001", []string{"Id", "Name"}, account).
+		Query("query", "SELECT Id FROM Opportunity WHERE Id = '@{opportunity.id}'", query).
+		Delete("delete", "001000000000002", &sobjects.Account{}).
+		Send()
+	if err != nil {
+		t.Fatalf("Failed to send composite request: %v", err)
+	}
+
+	inserted, err := resp.Get("opportunity").SObjectResponse()
+	if err != nil || inserted.Id != "006000000000001" || !inserted.Success {
+		t.Fatalf("Unexpected insert response: %+v %v", inserted, err)
+	}
+	if updated, err := resp.Get("rename").SObjectResponse(); err != nil || !updated.Success {
+		t.Fatalf("Unexpected update response: %+v %v", updated, err)
+	}
+	if account.Id != "001000000000001" {
+		t.Fatalf("Expected account to be decoded, got %+v", account)
+	}
+	if query.TotalSize != 1 || len(query.Records) != 1 || query.Records[0].Id != "006000000000001" {
+		t.Fatalf("Expected query to be decoded, got %+v", query)
+	}
+
+	deleted := resp.Get("delete")
+	if deleted.Success() || deleted.Errors()[0].ErrorCode != "ENTITY_IS_DELETED" {
+		t.Fatalf("Expected delete to fail, got %+v", deleted)
+	}
+	if _, err := deleted.SObjectResponse(); err == nil {
+		t.Fatal("Expected SObjectResponse to return the subrequest errors")
+	}
+}
+
+func TestCompositeRequestEscapesIds(t *testing.T) {
+	forceApi := createCompositeTestServer(t, func(body map[string]interface{}) string {
+		want := []string{
+			"PATCH /services/data/v36.0/sobjects/Opportunity/006%2F1%3F",
+			"GET /services/data/v36.0/sobjects/Opportunity/006%2F2%3F",
+			"DELETE /services/data/v36.0/sobjects/Opportunity/006%2F3%3F",
+		}
+		for i, sub := range body["compositeRequest"].([]interface{}) {
+			fields := sub.(map[string]interface{})
+			if got := fmt.Sprintf("%v %v", fields["method"], fields["url"]); got != want[i] {
+				t.Errorf("Unexpected subrequest %d: %v", i, got)
+			}
+		}
+
+		return `{"compositeResponse":[]}`
+	})
+
+	_, err := forceApi.NewCompositeRequest(false).
+		Update("update", "006/1?", &compositeOpportunity{Name: "Acme"}).
+		Get("get", "006/2?", nil, &compositeOpportunity{}).
+		Delete("delete", "006/3?", &compositeOpportunity{}).
+		Send()
+	if err != nil {
+		t.Fatalf("Failed to send composite request: %v", err)
+	}
+}
+
+func TestCompositeRequestInvalid(t *testing.T) {
+	forceApi := createCompositeTestServer(t, func(body map[string]interface{}) string {
+		t.Error("Unexpected request")
+		return ""
+	})
+
+	tests := map[string]*CompositeRequest{
+		"invalid reference id":    forceApi.NewCompositeRequest(false).Delete("1st", "001", &sobjects.Account{}),
+		"duplicate reference id":  forceApi.NewCompositeRequest(false).Delete("a", "001", &sobjects.Account{}).Delete("a", "002", &sobjects.Account{}),
+		"no subrequests":          forceApi.NewCompositeRequest(false),
+		"Unable to find metadata": forceApi.NewCompositeRequest(false).Delete("lead", "00Q", &sobjects.Lead{}),
+	}
+
+	tooMany := forceApi.NewCompositeRequest(false)
+	for i := 0; i <= compositeMaxSubrequests; i++ {
+		tooMany.Delete(fmt.Sprintf("delete%d", i), "001", &sobjects.Account{})
+	}
+	tests["more than 25 subrequests"] = tooMany
+
+	for want, req := range tests {
+		if _, err := req.Send(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q error, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package force
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const duplicateRuleHeader = "Sforce-Duplicate-Rule-Header"
+
+// DuplicateResult is reported with DUPLICATES_DETECTED errors when a
+// duplicate rule blocks saving a record.
+type DuplicateResult struct {
+	AllowSave               bool                    `json:"allowSave" force:"allowSave"`
+	DuplicateRule           string                  `json:"duplicateRule" force:"duplicateRule"`
+	DuplicateRuleEntityType string                  `json:"duplicateRuleEntityType" force:"duplicateRuleEntityType"`
+	ErrorMessage            string                  `json:"errorMessage" force:"errorMessage"`
+	MatchResults            []*DuplicateMatchResult `json:"matchResults" force:"matchResults"`
+}
+
+// DuplicateMatchResult lists the records found by one matching rule of a
+// duplicate rule.
+type DuplicateMatchResult struct {
+	EntityType   string                  `json:"entityType" force:"entityType"`
+	MatchEngine  string                  `json:"matchEngine" force:"matchEngine"`
+	Rule         string                  `json:"rule" force:"rule"`
+	Size         int                     `json:"size" force:"size"`
+	Success      bool                    `json:"success" force:"success"`
+	MatchRecords []*DuplicateMatchRecord `json:"matchRecords" force:"matchRecords"`
+}
+
+// DuplicateMatchRecord is an existing record matched by a matching rule.
+// Record holds its Id and, with IncludeRecordDetails, the fields compared.
+type DuplicateMatchRecord struct {
+	MatchConfidence float64                `json:"matchConfidence" force:"matchConfidence"`
+	FieldDiffs      []*DuplicateFieldDiff  `json:"fieldDiffs" force:"fieldDiffs"`
+	Record          map[string]interface{} `json:"record" force:"record"`
+}
+
+// DuplicateFieldDiff reports how a field of a matched record compares to the
+// record being saved.
+type DuplicateFieldDiff struct {
+	Name       string `json:"name" force:"name"`
+	Difference string `json:"difference" force:"difference"` // SAME, DIFFERENT or NULL.
+}
+
+// Id returns the Id of the matched record.
+func (match *DuplicateMatchRecord) Id() string {
+	id, _ := match.Record["Id"].(string)
+
+	return id
+}
+
+// DuplicateIds returns the Ids of the records matched by the duplicate rule
+// that reported the error, in the order Salesforce ranked them.
+func (e ApiError) DuplicateIds() []string {
+	if e.DuplicateResult == nil {
+		return nil
+	}
+
+	ids := []string{}
+	for _, result := range e.DuplicateResult.MatchResults {
+		for _, match := range result.MatchRecords {
+			if id := match.Id(); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// RequestOption configures a single insert, update or upsert.
+type RequestOption func(*requestConfig)
+
+type requestConfig struct {
+	header http.Header
+}
+
+// DuplicateRuleHeader controls how duplicate rules are applied to a save.
+type DuplicateRuleHeader struct {
+	// AllowSave saves records flagged as duplicates by rules whose action
+	// on create or edit is Allow.
+	AllowSave bool
+	// IncludeRecordDetails returns the compared fields of matched records
+	// in DuplicateMatchRecord.Record instead of just their Ids.
+	IncludeRecordDetails bool
+	// RunAsCurrentUser applies the sharing rules of the current user when
+	// looking for duplicates.
+	RunAsCurrentUser bool
+}
+
+// WithDuplicateRuleHeader sends the Sforce-Duplicate-Rule-Header with the
+// request.
+func WithDuplicateRuleHeader(header DuplicateRuleHeader) RequestOption {
+	return func(config *requestConfig) {
+		config.header.Set(duplicateRuleHeader, fmt.Sprintf("allowSave=%v, includeRecordDetails=%v, runAsCurrentUser=%v",
+			header.AllowSave, header.IncludeRecordDetails, header.RunAsCurrentUser))
+	}
+}
+
+type requestHeaderKey struct{}
+
+// withRequestOptions returns a context carrying the headers set by opts, which
+// newRequest adds to every request made with it.
+func withRequestOptions(ctx context.Context, opts []RequestOption) context.Context {
+	if len(opts) == 0 {
+		return ctx
+	}
+
+	config := &requestConfig{header: http.Header{}}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return context.WithValue(ctx, requestHeaderKey{}, config.header)
+}
+
+// setRequestHeaders adds the headers carried by ctx to req.
+func setRequestHeaders(ctx context.Context, req *http.Request) {
+	header, _ := ctx.Value(requestHeaderKey{}).(http.Header)
+	for key, values := range header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+}
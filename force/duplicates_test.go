@@ -0,0 +1,104 @@
+package force
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+const duplicatesDetectedResponse = `[{
+	"duplicateResult": {
+		"allowSave": false,
+		"duplicateRule": "Standard_Account_Duplicate_Rule",
+		"duplicateRuleEntityType": "Account",
+		"errorMessage": "You're creating a duplicate record.",
+		"matchResults": [{
+			"entityType": "Account",
+			"errors": [],
+			"matchEngine": "FuzzyMatchEngine",
+			"matchRecords": [
+				{"additionalInformation": [], "fieldDiffs": [{"difference": "SAME", "name": "Name"}], "matchConfidence": 98.5,
+					"record": {"attributes": {"type": "Account", "url": "/services/data/v36.0/sobjects/Account/001000000000001"}, "Id": "001000000000001", "Name": "Acme"}},
+				{"additionalInformation": [], "fieldDiffs": [], "matchConfidence": 80,
+					"record": {"attributes": {"type": "Account"}, "Id": "001000000000002"}}
+			],
+			"rule": "Standard_Account_Match_Rule_v1_0",
+			"size": 2,
+			"success": true
+		}]
+	},
+	"errorCode": "DUPLICATES_DETECTED",
+	"message": "Use one of these records?"
+}]`
+
+func createDuplicatesTestServer(t *testing.T, header *string) *ForceApi {
+	return createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*header = r.Header.Get(duplicateRuleHeader)
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/services/data/v36.0/sobjects/Account" && *header == "":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, duplicatesDetectedResponse)
+		case r.Method == "POST":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":"001000000000003","success":true,"errors":[]}`)
+		case r.Method == "PATCH":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		}
+	}))
+}
+
+func TestDuplicatesDetected(t *testing.T) {
+	var header string
+	forceApi := createDuplicatesTestServer(t, &header)
+
+	account := &sobjects.Account{}
+	account.Name = "Acme"
+	_, err := forceApi.InsertSObject(account, nil)
+	if !errors.Is(err, ErrDuplicatesDetected) {
+		t.Fatalf("Expected duplicates detected, got %v", err)
+	}
+
+	var apiErr *ApiError
+	errors.As(err, &apiErr)
+	result := apiErr.DuplicateResult
+	if result == nil || result.DuplicateRule != "Standard_Account_Duplicate_Rule" || len(result.MatchResults) != 1 {
+		t.Fatalf("Unexpected duplicate result: %+v", result)
+	}
+	match := result.MatchResults[0]
+	if match.Rule != "Standard_Account_Match_Rule_v1_0" || match.Size != 2 || match.MatchRecords[0].MatchConfidence != 98.5 ||
+		match.MatchRecords[0].Record["Name"] != "Acme" || match.MatchRecords[0].FieldDiffs[0].Difference != "SAME" {
+		t.Fatalf("Unexpected match result: %+v", match)
+	}
+	if ids := apiErr.DuplicateIds(); len(ids) != 2 || ids[0] != "001000000000001" || ids[1] != "001000000000002" {
+		t.Fatalf("Unexpected duplicate ids: %v", ids)
+	}
+}
+
+func TestDuplicateRuleHeader(t *testing.T) {
+	var header string
+	forceApi := createDuplicatesTestServer(t, &header)
+
+	account := &sobjects.Account{}
+	account.Name = "Acme"
+	resp, err := forceApi.InsertSObject(account, nil, WithDuplicateRuleHeader(DuplicateRuleHeader{AllowSave: true, RunAsCurrentUser: true}))
+	if err != nil || resp.Id != "001000000000003" {
+		t.Fatalf("Failed to insert: %v %v", resp, err)
+	}
+	if header != "allowSave=true, includeRecordDetails=false, runAsCurrentUser=true" {
+		t.Fatalf("Unexpected header %q", header)
+	}
+
+	err = forceApi.UpdateSObject("001000000000003", account, nil, WithDuplicateRuleHeader(DuplicateRuleHeader{AllowSave: true}))
+	if err != nil || header != "allowSave=true, includeRecordDetails=false, runAsCurrentUser=false" {
+		t.Fatalf("Unexpected update: %v %q", err, header)
+	}
+
+	if err := forceApi.UpdateSObject("001000000000003", account, nil); err != nil || header != "" {
+		t.Fatalf("Expected no header without the option, got %v %q", err, header)
+	}
+}
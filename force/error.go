@@ -2,20 +2,77 @@ package force
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
 )
 
+// Error codes reported by Salesforce in ApiError.ErrorCode.
+const (
+	CodeNotFound                       = "NOT_FOUND"
+	CodeDuplicateValue                 = "DUPLICATE_VALUE"
+	CodeDuplicatesDetected             = "DUPLICATES_DETECTED"
+	CodeEntityIsDeleted                = "ENTITY_IS_DELETED"
+	CodeRequestLimitExceeded           = "REQUEST_LIMIT_EXCEEDED"
+	CodeInvalidField                   = "INVALID_FIELD"
+	CodeInvalidFieldForInsertUpdate    = "INVALID_FIELD_FOR_INSERT_UPDATE"
+	CodeInvalidSessionId               = "INVALID_SESSION_ID"
+	CodeInvalidType                    = "INVALID_TYPE"
+	CodeMalformedQuery                 = "MALFORMED_QUERY"
+	CodeRequiredFieldMissing           = "REQUIRED_FIELD_MISSING"
+	CodeFieldCustomValidationException = "FIELD_CUSTOM_VALIDATION_EXCEPTION"
+	CodeUnableToLockRow                = "UNABLE_TO_LOCK_ROW"
+	CodeServerUnavailable              = "SERVER_UNAVAILABLE"
+	CodeInsufficientAccess             = "INSUFFICIENT_ACCESS_OR_READONLY"
+)
+
+// Sentinel errors matching an ApiError or ApiErrors reporting the same code,
+// for use with errors.Is:
+//
+//	if errors.Is(err, force.ErrNotFound) {
+//		...
+//	}
+var (
+	ErrNotFound                       error = ErrorCode(CodeNotFound)
+	ErrDuplicateValue                 error = ErrorCode(CodeDuplicateValue)
+	ErrDuplicatesDetected             error = ErrorCode(CodeDuplicatesDetected)
+	ErrEntityIsDeleted                error = ErrorCode(CodeEntityIsDeleted)
+	ErrRequestLimitExceeded           error = ErrorCode(CodeRequestLimitExceeded)
+	ErrInvalidField                   error = ErrorCode(CodeInvalidField)
+	ErrInvalidFieldForInsertUpdate    error = ErrorCode(CodeInvalidFieldForInsertUpdate)
+	ErrInvalidSessionId               error = ErrorCode(CodeInvalidSessionId)
+	ErrInvalidType                    error = ErrorCode(CodeInvalidType)
+	ErrMalformedQuery                 error = ErrorCode(CodeMalformedQuery)
+	ErrRequiredFieldMissing           error = ErrorCode(CodeRequiredFieldMissing)
+	ErrFieldCustomValidationException error = ErrorCode(CodeFieldCustomValidationException)
+	ErrUnableToLockRow                error = ErrorCode(CodeUnableToLockRow)
+	ErrServerUnavailable              error = ErrorCode(CodeServerUnavailable)
+	ErrInsufficientAccess             error = ErrorCode(CodeInsufficientAccess)
+)
+
+// ErrorCode is an error matching every ApiError that reports the code, so
+// errors.Is also works for codes without a predeclared sentinel:
+//
+//	errors.Is(err, force.ErrorCode("STRING_TOO_LONG"))
+type ErrorCode string
+
+func (code ErrorCode) Error() string {
+	return string(code)
+}
+
 // Custom Error to handle salesforce api responses.
 type ApiErrors []*ApiError
 
 type ApiError struct {
-	Fields           []string `json:"fields,omitempty" force:"fields,omitempty"`
-	Message          string   `json:"message,omitempty" force:"message,omitempty"`
-	ErrorCode        string   `json:"errorCode,omitempty" force:"errorCode,omitempty"`
-	ErrorName        string   `json:"error,omitempty" force:"error,omitempty"`
-	ErrorDescription string   `json:"error_description,omitempty" force:"error_description,omitempty"`
-	RequestURL       string   `json:"requestURL,omitempty"`
-	RequestBody      string   `json:"requestBody,omitempty"`
+	Fields           []string         `json:"fields,omitempty" force:"fields,omitempty"`
+	Message          string           `json:"message,omitempty" force:"message,omitempty"`
+	ErrorCode        string           `json:"errorCode,omitempty" force:"errorCode,omitempty"`
+	ErrorName        string           `json:"error,omitempty" force:"error,omitempty"`
+	ErrorDescription string           `json:"error_description,omitempty" force:"error_description,omitempty"`
+	StatusCode       string           `json:"statusCode,omitempty" force:"statusCode,omitempty"`           // Error code of per-record errors in collection responses.
+	HTTPStatusCode   int              `json:"-" force:"-"`                                                 // Status of the response that reported the error, if any.
+	DuplicateResult  *DuplicateResult `json:"duplicateResult,omitempty" force:"duplicateResult,omitempty"` // Set for DUPLICATES_DETECTED errors.
+	RequestURL       string           `json:"requestURL,omitempty"`
+	RequestBody      string           `json:"requestBody,omitempty"`
 }
 
 func (e ApiErrors) Error() string {
@@ -39,16 +96,120 @@ func (e ApiErrors) Validate() bool {
 	return false
 }
 
+// Is reports whether any of the errors matches target.
+func (e ApiErrors) Is(target error) bool {
+	for _, err := range e {
+		if err.Is(target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As sets target, which must be a **ApiError, to the first error.
+func (e ApiErrors) As(target interface{}) bool {
+	apiErr, ok := target.(**ApiError)
+	if !ok || len(e) == 0 {
+		return false
+	}
+	*apiErr = e[0]
+
+	return true
+}
+
+// HTTPStatusCode returns the status of the response that reported the errors,
+// or 0 if they were not reported by a response.
+func (e ApiErrors) HTTPStatusCode() int {
+	if len(e) == 0 {
+		return 0
+	}
+
+	return e[0].HTTPStatusCode
+}
+
+func (e ApiErrors) setHTTPStatusCode(status int) {
+	for _, err := range e {
+		err.HTTPStatusCode = status
+	}
+}
+
+// Retryable reports whether any of the errors is transient.
+func (e ApiErrors) Retryable() bool {
+	for _, err := range e {
+		if err.Retryable() {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (e ApiError) Error() string {
 	return e.String()
 }
 
+// String describes the error as "CODE: message", followed by the fields it
+// concerns.
 func (e ApiError) String() string {
-	return fmt.Sprintf("%#v", e)
+	message := e.Message
+	if message == "" {
+		message = e.ErrorDescription
+	}
+
+	s := message
+	if code := e.Code(); code != "" && message != "" {
+		s = code + ": " + message
+	} else if code != "" {
+		s = code
+	}
+	if len(e.Fields) != 0 {
+		s += fmt.Sprintf(" (fields: %v)", strings.Join(e.Fields, ", "))
+	}
+
+	return s
 }
 
 func (e ApiError) Validate() bool {
-	if len(e.Fields) != 0 || len(e.Message) != 0 || len(e.ErrorCode) != 0 || len(e.ErrorName) != 0 || len(e.ErrorDescription) != 0 {
+	if len(e.Fields) != 0 || len(e.Message) != 0 || len(e.ErrorCode) != 0 || len(e.ErrorName) != 0 || len(e.ErrorDescription) != 0 || len(e.StatusCode) != 0 {
+		return true
+	}
+
+	return false
+}
+
+// Code returns the code identifying the error: ErrorCode, StatusCode for
+// per-record errors or the OAuth error name.
+func (e ApiError) Code() string {
+	switch {
+	case e.ErrorCode != "":
+		return e.ErrorCode
+	case e.StatusCode != "":
+		return e.StatusCode
+	}
+
+	return e.ErrorName
+}
+
+// Is reports whether target is an ErrorCode matching the code of the error.
+func (e ApiError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+
+	return ok && string(code) == e.Code()
+}
+
+// Retryable reports whether the error is transient: a locked row, an
+// unavailable server or a 502, 503 or 504 response. These are the failures
+// retried by DefaultRetryPolicy, but the answer does not depend on the retry
+// policy in use.
+func (e ApiError) Retryable() bool {
+	switch e.Code() {
+	case CodeUnableToLockRow, CodeServerUnavailable:
+		return true
+	}
+
+	switch e.HTTPStatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
 		return true
 	}
 
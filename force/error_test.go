@@ -0,0 +1,91 @@
+package force
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+func TestApiErrorIs(t *testing.T) {
+	forceApi := createTest(t)
+
+	err := forceApi.GetSObject("001000000000000", nil, &sobjects.Account{})
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrEntityIsDeleted) {
+		t.Fatalf("Expected not found, got %v", err)
+	}
+
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != CodeNotFound || apiErr.HTTPStatusCode != http.StatusNotFound {
+		t.Fatalf("Expected api error, got %#v", err)
+	}
+	if err.(ApiErrors).HTTPStatusCode() != http.StatusNotFound || err.(ApiErrors).Retryable() {
+		t.Fatalf("Unexpected classification of %v", err)
+	}
+
+	err = forceApi.Query("SELECT Bogus__c FROM Account", &sobjects.BaseQuery{})
+	if !errors.Is(err, ErrInvalidField) || !errors.Is(err, ErrorCode("INVALID_FIELD")) {
+		t.Fatalf("Expected invalid field, got %v", err)
+	}
+
+	// Per-record errors report their code in statusCode.
+	recordErr := &ApiError{StatusCode: CodeDuplicateValue, Message: "duplicate value found"}
+	if !errors.Is(recordErr, ErrDuplicateValue) {
+		t.Fatalf("Expected duplicate value, got %v", recordErr)
+	}
+}
+
+func TestApiErrorRetryable(t *testing.T) {
+	for _, test := range []struct {
+		err  ApiError
+		want bool
+	}{
+		{ApiError{ErrorCode: CodeUnableToLockRow}, true},
+		{ApiError{StatusCode: CodeUnableToLockRow}, true},
+		{ApiError{ErrorCode: CodeServerUnavailable}, true},
+		{ApiError{Message: "Bad gateway", HTTPStatusCode: http.StatusBadGateway}, true},
+		{ApiError{ErrorCode: CodeNotFound, HTTPStatusCode: http.StatusNotFound}, false},
+		{ApiError{ErrorCode: CodeRequestLimitExceeded, HTTPStatusCode: http.StatusForbidden}, false},
+	} {
+		if got := test.err.Retryable(); got != test.want {
+			t.Errorf("%v: expected retryable %v, got %v", test.err, test.want, got)
+		}
+	}
+
+	errs := ApiErrors{{ErrorCode: CodeNotFound}, {ErrorCode: CodeUnableToLockRow}}
+	if !errs.Retryable() || !errors.Is(errs, ErrUnableToLockRow) {
+		t.Fatalf("Expected %v to be retryable", errs)
+	}
+
+	// The answer does not follow changes to DefaultRetryPolicy.
+	policy := DefaultRetryPolicy
+	defer func() { DefaultRetryPolicy = policy }()
+	DefaultRetryPolicy = RetryPolicy{ErrorCodes: []string{CodeNotFound}}
+	if (ApiError{ErrorCode: CodeNotFound}).Retryable() || !(ApiError{ErrorCode: CodeUnableToLockRow}).Retryable() {
+		t.Fatal("Expected Retryable to ignore DefaultRetryPolicy")
+	}
+}
+
+func TestApiErrorString(t *testing.T) {
+	for _, test := range []struct {
+		err  ApiError
+		want string
+	}{
+		{ApiError{ErrorCode: CodeNotFound, Message: "The requested resource does not exist"}, "NOT_FOUND: The requested resource does not exist"},
+		{ApiError{ErrorCode: CodeRequiredFieldMissing, Message: "Required fields are missing: [Name]", Fields: []string{"Name"}},
+			"REQUIRED_FIELD_MISSING: Required fields are missing: [Name] (fields: Name)"},
+		{ApiError{ErrorName: "invalid_grant", ErrorDescription: "authentication failure"}, "invalid_grant: authentication failure"},
+		{ApiError{ErrorCode: CodeServerUnavailable}, "SERVER_UNAVAILABLE"},
+		{ApiError{Message: "Bad gateway"}, "Bad gateway"},
+	} {
+		if got := test.err.Error(); got != test.want {
+			t.Errorf("Expected %q, got %q", test.want, got)
+		}
+	}
+
+	errs := ApiErrors{{ErrorCode: CodeInvalidField, Message: "No such column"}, {ErrorCode: CodeNotFound, Message: "Gone"}}
+	if got := errs.Error(); got != "INVALID_FIELD: No such column\nNOT_FOUND: Gone" {
+		t.Fatalf("Unexpected errors string %q", got)
+	}
+}
@@ -0,0 +1,122 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+// Status code Salesforce reports, with the EventUuid as message, for an event
+// that was queued for publishing.
+const operationEnqueued = "OPERATION_ENQUEUED"
+
+// PublishResult is the outcome of publishing a platform event.
+type PublishResult struct {
+	Id      string
+	Success bool
+	// EventUuid identifies the event in the event bus. It is received with
+	// the event by subscribers.
+	EventUuid string
+	Errors    ApiErrors
+}
+
+// PublishEvent publishes a platform event, such as a struct whose ApiName is
+// "Order_Shipped__e". The event is encoded with forcejson, so every field is
+// sent as tagged without consulting the object description. Publishing is
+// asynchronous: success means the event was queued.
+func (forceApi *ForceApi) PublishEvent(in SObject) (*PublishResult, error) {
+	return forceApi.PublishEventContext(context.Background(), in)
+}
+
+// PublishEventContext is like PublishEvent but carries ctx through the request.
+func (forceApi *ForceApi) PublishEventContext(ctx context.Context, in SObject) (*PublishResult, error) {
+	payload, err := eventPayload(in)
+	if err != nil {
+		return nil, err
+	}
+	delete(payload, "attributes")
+
+	resp := &SObjectResponse{}
+	if err := forceApi.PostContext(ctx, forceApi.eventUri(in), nil, payload, resp); err != nil {
+		return nil, err
+	}
+
+	return newPublishResult(resp), nil
+}
+
+// PublishEvents publishes up to 200 events per request using the sObject
+// Collections resource. The events may be of different types. Results are
+// returned in the order of in; a failed event does not prevent the others
+// from being published.
+func (forceApi *ForceApi) PublishEvents(in []SObject) ([]*PublishResult, error) {
+	return forceApi.PublishEventsContext(context.Background(), in)
+}
+
+// PublishEventsContext is like PublishEvents but carries ctx through the requests.
+func (forceApi *ForceApi) PublishEventsContext(ctx context.Context, in []SObject) ([]*PublishResult, error) {
+	responses, err := forceApi.sendCollection(ctx, "POST", forceApi.compositeUri()+"/sobjects", in, false, eventPayload)
+
+	results := make([]*PublishResult, len(responses))
+	for i, resp := range responses {
+		results[i] = newPublishResult(resp)
+	}
+
+	return results, err
+}
+
+// eventPayload encodes in with forcejson, naming its type as the sObject
+// Collections resource requires.
+func eventPayload(in SObject) (map[string]interface{}, error) {
+	encoded, err := forcejson.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling encoded payload: %v", err)
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, fmt.Errorf("Error marshaling encoded payload: %v", err)
+	}
+
+	payload := make(map[string]interface{}, len(fields)+1)
+	for name, value := range fields {
+		payload[name] = value
+	}
+	payload["attributes"] = map[string]string{"type": in.ApiName()}
+
+	return payload, nil
+}
+
+// newPublishResult moves the EventUuid reported as an error out of the
+// errors of resp.
+func newPublishResult(resp *SObjectResponse) *PublishResult {
+	result := &PublishResult{
+		Id:      resp.Id,
+		Success: resp.Success,
+		Errors:  ApiErrors{},
+	}
+
+	for _, apiErr := range resp.Errors {
+		code := apiErr.ErrorCode
+		if code == "" {
+			code = apiErr.StatusCode
+		}
+
+		if code == operationEnqueued {
+			result.EventUuid = apiErr.Message
+			continue
+		}
+		result.Errors = append(result.Errors, apiErr)
+	}
+
+	return result
+}
+
+func (forceApi *ForceApi) eventUri(in SObject) string {
+	if metaData, ok := forceApi.sObject(in.ApiName()); ok {
+		return metaData.URLs[sObjectKey]
+	}
+
+	return forceApi.apiResources[sObjectsKey] + "/" + in.ApiName()
+}
@@ -0,0 +1,103 @@
+package force
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type orderShipped struct {
+	OrderNumber string         `force:"Order_Number__c"`
+	Carrier     string         `force:"Carrier__c,omitempty"`
+	Weight      float64        `force:"Weight__c"`
+	ShippedAt   *sobjects.Time `force:"Shipped_At__c,omitempty"`
+}
+
+func (e *orderShipped) ApiName() string {
+	return "Order_Shipped__e"
+}
+
+func (e *orderShipped) ExternalIdApiName() string {
+	return ""
+}
+
+func TestPublishEvent(t *testing.T) {
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/services/data/v36.0/sobjects/Order_Shipped__e" {
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		}
+
+		body := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		want := map[string]interface{}{"Order_Number__c": "42", "Weight__c": 1.5}
+		if !reflect.DeepEqual(body, want) {
+			t.Errorf("Unexpected event: %v", body)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"e00xx0000000001AAA","success":true,"errors":[{"statusCode":"OPERATION_ENQUEUED","message":"08b7a5c6-4a1a-4cbc-9a52-8c4c1f5a0d2e","fields":[]}]}`)
+	}))
+
+	result, err := forceApi.PublishEvent(&orderShipped{OrderNumber: "42", Weight: 1.5})
+	if err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if !result.Success || result.Id != "e00xx0000000001AAA" || result.EventUuid != "08b7a5c6-4a1a-4cbc-9a52-8c4c1f5a0d2e" || len(result.Errors) != 0 {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+}
+
+func TestPublishEvents(t *testing.T) {
+	var batches []int
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/services/data/v36.0/composite/sobjects" {
+			t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		}
+
+		body := struct {
+			AllOrNone bool                     `json:"allOrNone"`
+			Records   []map[string]interface{} `json:"records"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		batches = append(batches, len(body.Records))
+
+		results := []string{}
+		for _, record := range body.Records {
+			if record["attributes"].(map[string]interface{})["type"] != "Order_Shipped__e" || body.AllOrNone {
+				t.Errorf("Unexpected record: %v", record)
+			}
+			if record["Order_Number__c"] == "" {
+				results = append(results, `{"success":false,"errors":[{"statusCode":"REQUIRED_FIELD_MISSING","message":"Required fields are missing: [Order_Number__c]","fields":["Order_Number__c"]}]}`)
+				continue
+			}
+			results = append(results, fmt.Sprintf(`{"id":"e00xx0000000001AAA","success":true,"errors":[{"statusCode":"OPERATION_ENQUEUED","message":"uuid-%v","fields":[]}]}`, record["Order_Number__c"]))
+		}
+		fmt.Fprint(w, "["+strings.Join(results, ",")+"]")
+	}))
+
+	events := make([]SObject, 201)
+	for i := range events {
+		events[i] = &orderShipped{OrderNumber: fmt.Sprint(i)}
+	}
+	events[1] = &orderShipped{}
+
+	results, err := forceApi.PublishEvents(events)
+	if err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	if fmt.Sprint(batches) != "[200 1]" || len(results) != 201 {
+		t.Fatalf("Unexpected batches %v with %d results", batches, len(results))
+	}
+
+	if !results[0].Success || results[0].EventUuid != "uuid-0" || results[200].EventUuid != "uuid-200" {
+		t.Fatalf("Unexpected results: %+v %+v", results[0], results[200])
+	}
+	if results[1].Success || results[1].EventUuid != "" || results[1].Errors[0].ErrorCode != "REQUIRED_FIELD_MISSING" {
+		t.Fatalf("Unexpected failed result: %+v", results[1])
+	}
+}
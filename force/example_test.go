@@ -0,0 +1,69 @@
+package force_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/nimajalali/go-force/force"
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+// Account and Opportunity name their fields in force tags, like the structs
+// generated by force-gen, so that inserts and updates send them.
+type Account struct {
+	sobjects.BaseSObject
+	Name string `force:"Name,omitempty"`
+}
+
+func (a *Account) ApiName() string {
+	return "Account"
+}
+
+type Opportunity struct {
+	sobjects.BaseSObject
+	Name      string `force:"Name,omitempty"`
+	StageName string `force:"StageName,omitempty"`
+	CloseDate string `force:"CloseDate,omitempty"`
+	AccountId string `force:"AccountId,omitempty"`
+}
+
+func (o *Opportunity) ApiName() string {
+	return "Opportunity"
+}
+
+func ExampleCompositeRequest() {
+	forceApi, err := force.Create(
+		"YOUR-API-VERSION",
+		"YOUR-CLIENT-ID",
+		"YOUR-CLIENT-SECRET",
+		"YOUR-USERNAME",
+		"YOUR-PASSWORD",
+		"YOUR-SECURITY-TOKEN",
+		"YOUR-ENVIRONMENT",
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The opportunity references the account inserted before it.
+	account := &Account{Name: "Acme"}
+	opportunity := &Opportunity{
+		Name:      "Acme",
+		StageName: "Prospecting",
+		CloseDate: "2026-12-31",
+		AccountId: force.CompositeRef("account", "id"),
+	}
+	resp, err := forceApi.NewCompositeRequest(true).
+		Insert("account", account).
+		Insert("opportunity", opportunity).
+		Send()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	inserted, err := resp.Get("opportunity").SObjectResponse()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(inserted.Id)
+}
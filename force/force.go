@@ -4,8 +4,8 @@
 package force
 
 import (
-	"fmt"
-	"os"
+	"context"
+	"strings"
 )
 
 const (
@@ -19,7 +19,7 @@ const (
 )
 
 func Create(version, clientId, clientSecret, userName, password, securityToken,
-	environment string) (*ForceApi, error) {
+	environment string, opts ...Option) (*ForceApi, error) {
 	oauth := &forceOauth{
 		clientId:      clientId,
 		clientSecret:  clientSecret,
@@ -29,26 +29,22 @@ func Create(version, clientId, clientSecret, userName, password, securityToken,
 		environment:   environment,
 	}
 
-	forceApi := &ForceApi{
-		apiResources:           make(map[string]string),
-		apiSObjects:            make(map[string]*SObjectMetaData),
-		apiSObjectDescriptions: make(map[string]*SObjectDescription),
-		apiVersion:             version,
-		oauth:                  oauth,
-	}
+	forceApi := newForceApi(version, oauth, opts)
+
+	ctx := context.Background()
 
 	// Init oauth
-	err := forceApi.oauth.Authenticate()
+	err := forceApi.oauth.Authenticate(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	// Init Api Resources
-	err = forceApi.getApiResources()
+	err = forceApi.getApiResources(ctx)
 	if err != nil {
 		return nil, err
 	}
-	err = forceApi.getApiSObjects()
+	err = forceApi.getApiSObjects(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -56,20 +52,16 @@ func Create(version, clientId, clientSecret, userName, password, securityToken,
 	return forceApi, nil
 }
 
-func CreateWithAccessToken(version, clientId, accessToken, instanceUrl string) (*ForceApi, error) {
+func CreateWithAccessToken(version, clientId, accessToken, instanceUrl string, opts ...Option) (*ForceApi, error) {
 	oauth := &forceOauth{
 		clientId:    clientId,
 		AccessToken: accessToken,
 		InstanceUrl: instanceUrl,
 	}
 
-	forceApi := &ForceApi{
-		apiResources:           make(map[string]string),
-		apiSObjects:            make(map[string]*SObjectMetaData),
-		apiSObjectDescriptions: make(map[string]*SObjectDescription),
-		apiVersion:             version,
-		oauth:                  oauth,
-	}
+	forceApi := newForceApi(version, oauth, opts)
+
+	ctx := context.Background()
 
 	// We need to check for oath correctness here, since we are not generating the token ourselves.
 	if err := forceApi.oauth.Validate(); err != nil {
@@ -77,11 +69,11 @@ func CreateWithAccessToken(version, clientId, accessToken, instanceUrl string) (
 	}
 
 	// Init Api Resources
-	err := forceApi.getApiResources()
+	err := forceApi.getApiResources(ctx)
 	if err != nil {
 		return nil, err
 	}
-	err = forceApi.getApiSObjects()
+	err = forceApi.getApiSObjects(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -89,37 +81,74 @@ func CreateWithAccessToken(version, clientId, accessToken, instanceUrl string) (
 	return forceApi, nil
 }
 
-func CreateWithRefreshToken(version, clientId, accessToken, instanceUrl string) (*ForceApi, error) {
+// CreateWithJWT authenticates using the OAuth 2.0 JWT bearer flow. The
+// assertion is signed with privateKey, a PEM encoded RSA key whose certificate
+// is uploaded to the connected app identified by clientId, on behalf of
+// userName. A new assertion is minted whenever the session expires.
+func CreateWithJWT(version, clientId, userName string, privateKey []byte, environment string, opts ...Option) (*ForceApi, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
 	oauth := &forceOauth{
 		clientId:    clientId,
-		AccessToken: accessToken,
-		InstanceUrl: instanceUrl,
+		userName:    userName,
+		environment: environment,
+		privateKey:  key,
 	}
 
-	forceApi := &ForceApi{
-		apiResources:           make(map[string]string),
-		apiSObjects:            make(map[string]*SObjectMetaData),
-		apiSObjectDescriptions: make(map[string]*SObjectDescription),
-		apiVersion:             version,
-		oauth:                  oauth,
+	forceApi := newForceApi(version, oauth, opts)
+
+	ctx := context.Background()
+
+	// Init oauth
+	err = forceApi.oauth.Authenticate(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// obtain access token
-	if err := forceApi.RefreshToken(); err != nil {
+	// Init Api Resources
+	err = forceApi.getApiResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = forceApi.getApiSObjects(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	// We need to check for oath correctness here, since we are not generating the token ourselves.
-	if err := forceApi.oauth.Validate(); err != nil {
+	return forceApi, nil
+}
+
+// CreateWithClientCredentials authenticates using the OAuth 2.0 client
+// credentials flow, on behalf of the integration user assigned to the
+// connected app identified by clientId. Salesforce only accepts the flow at
+// the My Domain of the org, such as https://example.my.salesforce.com, given
+// as loginUrl. A new session is requested whenever the session expires.
+func CreateWithClientCredentials(version, clientId, clientSecret, loginUrl string, opts ...Option) (*ForceApi, error) {
+	oauth := &forceOauth{
+		clientId:          clientId,
+		clientSecret:      clientSecret,
+		clientCredentials: true,
+	}
+
+	forceApi := newForceApi(version, oauth, append([]Option{WithLoginURL(loginUrl)}, opts...))
+
+	ctx := context.Background()
+
+	// Init oauth
+	err := forceApi.oauth.Authenticate(ctx)
+	if err != nil {
 		return nil, err
 	}
 
 	// Init Api Resources
-	err := forceApi.getApiResources()
+	err = forceApi.getApiResources(ctx)
 	if err != nil {
 		return nil, err
 	}
-	err = forceApi.getApiSObjects()
+	err = forceApi.getApiSObjects(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -127,14 +156,34 @@ func CreateWithRefreshToken(version, clientId, accessToken, instanceUrl string)
 	return forceApi, nil
 }
 
-// Used when running tests.
-func createTest() *ForceApi {
-	forceApi, err := Create(testVersion, testClientId, testClientSecret, testUserName, testPassword, testSecurityToken, testEnvironment)
-	if err != nil {
-		fmt.Printf("Unable to create ForceApi for test: %v", err)
-		os.Exit(1)
+// WithLoginURL authenticates at loginUrl instead of the login host of the
+// environment: a My Domain such as https://example.my.salesforce.com, an
+// Experience Cloud site such as https://example.my.site.com/customers, or a
+// Government Cloud login host.
+func WithLoginURL(loginUrl string) Option {
+	return func(forceApi *ForceApi) {
+		forceApi.oauth.loginUrl = strings.TrimSuffix(loginUrl, "/")
+	}
+}
+
+// newForceApi applies opts and shares the resulting http client with oauth, so
+// that authentication requests pass through the same middleware.
+func newForceApi(version string, oauth *forceOauth, opts []Option) *ForceApi {
+	forceApi := &ForceApi{
+		apiResources:           make(map[string]string),
+		apiSObjects:            make(map[string]*SObjectMetaData),
+		apiSObjectDescriptions: make(map[string]*SObjectDescription),
+		apiVersion:             version,
+		oauth:                  oauth,
 	}
 
+	for _, opt := range opts {
+		opt(forceApi)
+	}
+
+	forceApi.httpClient = forceApi.buildClient()
+	oauth.httpClient = forceApi.httpClient
+
 	return forceApi
 }
 
@@ -148,28 +197,24 @@ type ForceApiLogger interface {
 // logged strings, which can aid in filtering log lines.
 //
 // Use TraceOn if you want to spy on the ForceApi requests and responses.
+// TraceMiddleware provides the same logging as a Middleware.
 //
 // Note that the base log.Logger type satisfies ForceApiLogger, but adapters
 // can easily be written for other logging packages (e.g., the
 // golang-sanctioned glog framework).
 func (forceApi *ForceApi) TraceOn(prefix string, logger ForceApiLogger) {
+	forceApi.mu.Lock()
+	defer forceApi.mu.Unlock()
+
 	forceApi.logger = logger
-	if prefix == "" {
-		forceApi.logPrefix = prefix
-	} else {
-		forceApi.logPrefix = fmt.Sprintf("%s ", prefix)
-	}
+	forceApi.logPrefix = prefix
 }
 
 // TraceOff turns off tracing. It is idempotent.
 func (forceApi *ForceApi) TraceOff() {
+	forceApi.mu.Lock()
+	defer forceApi.mu.Unlock()
+
 	forceApi.logger = nil
 	forceApi.logPrefix = ""
 }
-
-func (forceApi *ForceApi) trace(name string, value interface{}, format string) {
-	if forceApi.logger != nil {
-		logMsg := "%s%s " + format + "\n"
-		forceApi.logger.Printf(logMsg, forceApi.logPrefix, name, value)
-	}
-}
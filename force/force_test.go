@@ -1,11 +1,17 @@
 package force
 
 import (
-	"github.com/nimajalali/go-force/sobjects"
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/nimajalali/go-force/forcetest"
+	"github.com/nimajalali/go-force/sobjects"
 )
 
 func TestCreateWithAccessToken(t *testing.T) {
+	server := newTestFake(t)
 
 	// Manually grab an OAuth token, so that we can pass it into CreateWithAccessToken
 	oauth := &forceOauth{
@@ -14,27 +20,19 @@ func TestCreateWithAccessToken(t *testing.T) {
 		userName:      testUserName,
 		password:      testPassword,
 		securityToken: testSecurityToken,
-		environment:   testEnvironment,
-	}
-
-	forceApi := &ForceApi{
-		apiResources:           make(map[string]string),
-		apiSObjects:            make(map[string]*SObjectMetaData),
-		apiSObjectDescriptions: make(map[string]*SObjectDescription),
-		apiVersion:             version,
-		oauth:                  oauth,
+		loginUrl:      server.URL,
 	}
 
-	err := forceApi.oauth.Authenticate()
+	err := oauth.Authenticate(context.Background())
 	if err != nil {
 		t.Fatalf("Unable to authenticate: %#v", err)
 	}
-	if err := forceApi.oauth.Validate(); err != nil {
+	if err := oauth.Validate(); err != nil {
 		t.Fatalf("Oauth object is invlaid: %#v", err)
 	}
 
 	// We shouldn't hit any errors creating a new force instance and manually passing in these oauth details now.
-	newForceApi, err := CreateWithAccessToken(testVersion, testClientId, forceApi.oauth.AccessToken, forceApi.oauth.InstanceUrl)
+	newForceApi, err := CreateWithAccessToken(testVersion, testClientId, oauth.AccessToken, oauth.InstanceUrl)
 	if err != nil {
 		t.Fatalf("Unable to create new force api instance using pre-defined oauth details: %#v", err)
 	}
@@ -48,3 +46,141 @@ func TestCreateWithAccessToken(t *testing.T) {
 		t.Fatalf("Failed to retrieve description of sobject: %v", err)
 	}
 }
+
+// testSObjects are the objects served by the fake server of createTest.
+var testSObjects = []forcetest.SObject{
+	{
+		Name:      "Account",
+		KeyPrefix: "001",
+		Fields: []forcetest.Field{
+			{Name: "Name"},
+			{Name: "BillingCity", Nillable: true},
+			{Name: "BillingCountry", Nillable: true},
+			{Name: "BillingPostalCode", Nillable: true},
+			{Name: "BillingState", Nillable: true},
+			{Name: "BillingStreet", Type: "textarea", Nillable: true},
+		},
+	},
+	{
+		Name:      "Contact",
+		KeyPrefix: "003",
+		Fields: []forcetest.Field{
+			{Name: "Name", ReadOnly: true},
+			{Name: "LastName"},
+			{Name: "AccountId", Type: "reference", ReferenceTo: []string{"Account"}, RelationshipName: "Account", Nillable: true},
+			{Name: "Birthdate", Type: "date", Nillable: true},
+			{Name: "DoNotCall", Type: "boolean"},
+			{Name: "External_Id__c", ExternalId: true, Nillable: true},
+			{Name: "Formula__c", Nillable: true, ReadOnly: true},
+			{Name: "Number_Of_Pets__c", Type: "double", Nillable: true},
+		},
+	},
+	{
+		Name:      "Opportunity",
+		KeyPrefix: "006",
+		Fields: []forcetest.Field{
+			{Name: "Name"},
+			{Name: "AccountId", Type: "reference", ReferenceTo: []string{"Account"}, RelationshipName: "Account", Nillable: true},
+			{Name: "CloseDate", Type: "date"},
+			{Name: "StageName", Type: "picklist", PicklistValues: []string{"Prospecting", "Closed Won"}},
+		},
+	},
+	{
+		Name:      "CustomObject__c",
+		KeyPrefix: "a00",
+		Custom:    true,
+		Fields: []forcetest.Field{
+			{Name: "Name"},
+			{Name: "Active__c", Type: "boolean"},
+			{Name: "Account__c", Type: "reference", ReferenceTo: []string{"Account"}, RelationshipName: "Account__r", Nillable: true},
+		},
+	},
+}
+
+// newTestFake returns a fake server holding the records the tests expect.
+func newTestFake(t *testing.T) *forcetest.Server {
+	server := forcetest.NewServer(testSObjects...)
+	t.Cleanup(server.Close)
+
+	server.Seed("Account", forcetest.Record{"Id": AccountId, "Name": "Test Account", "BillingCity": "San Francisco"})
+	server.Seed("CustomObject__c", forcetest.Record{"Id": CustomObjectId, "Name": "Test Object", "Active__c": true, "Account__c": AccountId})
+
+	return server
+}
+
+// createTest returns a ForceApi logged into a fake server with the test
+// credentials.
+func createTest(t *testing.T) *ForceApi {
+	return createFakeTest(t, newTestFake(t))
+}
+
+func createFakeTest(t *testing.T, server *forcetest.Server, opts ...Option) *ForceApi {
+	forceApi := newForceApi(testVersion, &forceOauth{
+		clientId:      testClientId,
+		clientSecret:  testClientSecret,
+		userName:      testUserName,
+		password:      testPassword,
+		securityToken: testSecurityToken,
+		loginUrl:      server.URL,
+	}, opts)
+
+	ctx := context.Background()
+	if err := forceApi.oauth.Authenticate(ctx); err != nil {
+		t.Fatalf("Unable to authenticate: %v", err)
+	}
+	if err := forceApi.getApiResources(ctx); err != nil {
+		t.Fatalf("Unable to get api resources: %v", err)
+	}
+	if err := forceApi.getApiSObjects(ctx); err != nil {
+		t.Fatalf("Unable to get api sobjects: %v", err)
+	}
+
+	return forceApi
+}
+
+// testSObject is the SObject named by its api name.
+type testSObject string
+
+func (name testSObject) ApiName() string {
+	return string(name)
+}
+
+func (name testSObject) ExternalIdApiName() string {
+	return ""
+}
+
+// describeTestSObjects caches the metadata and descriptions of testSObjects,
+// as served by the fake server, in forceApi.
+func describeTestSObjects(t *testing.T, forceApi *ForceApi) {
+	described := createTest(t)
+	for _, object := range testSObjects {
+		if _, err := described.DescribeSObject(testSObject(object.Name)); err != nil {
+			t.Fatalf("Unable to describe %v: %v", object.Name, err)
+		}
+	}
+
+	forceApi.apiSObjects = described.apiSObjects
+	forceApi.apiSObjectDescriptions = described.apiSObjectDescriptions
+}
+
+// createTestServer returns a ForceApi whose session points at a local server
+// running handler, with the standard api resources and testSObjects
+// registered.
+func createTestServer(t *testing.T, handler http.Handler, opts ...Option) *ForceApi {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	forceApi := newForceApi(testVersion, &forceOauth{
+		AccessToken: "token",
+		InstanceUrl: server.URL,
+	}, opts)
+	forceApi.apiResources = map[string]string{
+		queryKey:    "/services/data/" + testVersion + "/query",
+		queryAllKey: "/services/data/" + testVersion + "/queryAll",
+		limitsKey:   "/services/data/" + testVersion + "/limits",
+		sObjectsKey: "/services/data/" + testVersion + "/sobjects",
+	}
+	describeTestSObjects(t, forceApi)
+
+	return forceApi
+}
@@ -0,0 +1,231 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	revokePath     = "/services/oauth2/revoke"
+	introspectPath = "/services/oauth2/introspect"
+	userInfoPath   = "/services/oauth2/userinfo"
+
+	// Body of the 403 returned by the identity endpoints for expired sessions.
+	badOAuthToken = "Bad_OAuth_Token"
+)
+
+// TokenIntrospection describes a token, as returned by Introspect. Only Active
+// is set for tokens that are expired, revoked or were never issued.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Subject   string `json:"sub,omitempty"` // Identity url of the user.
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"` // Seconds since the epoch.
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+}
+
+// IdentityURLs are the endpoints available to the user. The api urls contain
+// a "{version}" placeholder.
+type IdentityURLs struct {
+	Enterprise   string `json:"enterprise,omitempty"`
+	Metadata     string `json:"metadata,omitempty"`
+	Partner      string `json:"partner,omitempty"`
+	Rest         string `json:"rest,omitempty"`
+	SObjects     string `json:"sobjects,omitempty"`
+	Search       string `json:"search,omitempty"`
+	Query        string `json:"query,omitempty"`
+	Recent       string `json:"recent,omitempty"`
+	Profile      string `json:"profile,omitempty"`
+	Feeds        string `json:"feeds,omitempty"`
+	Groups       string `json:"groups,omitempty"`
+	Users        string `json:"users,omitempty"`
+	FeedItems    string `json:"feed_items,omitempty"`
+	CustomDomain string `json:"custom_domain,omitempty"`
+}
+
+// UserInfo is the OpenID Connect description of the user a session belongs
+// to, as returned by UserInfo.
+type UserInfo struct {
+	Subject           string       `json:"sub"` // Identity url of the user.
+	UserId            string       `json:"user_id"`
+	OrganizationId    string       `json:"organization_id"`
+	PreferredUsername string       `json:"preferred_username"`
+	Nickname          string       `json:"nickname,omitempty"`
+	Name              string       `json:"name,omitempty"`
+	GivenName         string       `json:"given_name,omitempty"`
+	FamilyName        string       `json:"family_name,omitempty"`
+	Email             string       `json:"email,omitempty"`
+	EmailVerified     bool         `json:"email_verified,omitempty"`
+	ZoneInfo          string       `json:"zoneinfo,omitempty"` // Time zone, such as "America/Los_Angeles".
+	Locale            string       `json:"locale,omitempty"`
+	Language          string       `json:"language,omitempty"`
+	UTCOffset         int          `json:"utcOffset,omitempty"` // Offset of ZoneInfo from UTC in milliseconds.
+	UserType          string       `json:"user_type,omitempty"`
+	Active            bool         `json:"active,omitempty"`
+	URLs              IdentityURLs `json:"urls"`
+}
+
+// Identity describes the user a session belongs to, as returned by the
+// identity url of the session.
+type Identity struct {
+	Id               string       `json:"id"`
+	UserId           string       `json:"user_id"`
+	OrganizationId   string       `json:"organization_id"`
+	Username         string       `json:"username"`
+	NickName         string       `json:"nick_name,omitempty"`
+	DisplayName      string       `json:"display_name,omitempty"`
+	FirstName        string       `json:"first_name,omitempty"`
+	LastName         string       `json:"last_name,omitempty"`
+	Email            string       `json:"email,omitempty"`
+	EmailVerified    bool         `json:"email_verified,omitempty"`
+	Timezone         string       `json:"timezone,omitempty"` // Time zone, such as "America/Los_Angeles".
+	Locale           string       `json:"locale,omitempty"`
+	Language         string       `json:"language,omitempty"`
+	UTCOffset        int          `json:"utcOffset,omitempty"` // Offset of Timezone from UTC in milliseconds.
+	UserType         string       `json:"user_type,omitempty"`
+	Active           bool         `json:"active,omitempty"`
+	AssertedUser     bool         `json:"asserted_user,omitempty"`
+	LastModifiedDate string       `json:"last_modified_date,omitempty"`
+	URLs             IdentityURLs `json:"urls"`
+}
+
+// Revoke revokes an access token or refresh token, such as when a user
+// disconnects the application. Revoking a refresh token also revokes the
+// access tokens issued with it. The ForceApi cannot make further requests
+// once its own tokens are revoked, unless it can log in again with its
+// credentials.
+func (forceApi *ForceApi) Revoke(token string) error {
+	return forceApi.RevokeContext(context.Background(), token)
+}
+
+// RevokeContext is like Revoke but carries ctx through the request.
+func (forceApi *ForceApi) RevokeContext(ctx context.Context, token string) error {
+	instanceUrl, _ := forceApi.oauth.session()
+
+	return postForm(ctx, forceApi.oauth.client(), instanceUrl+revokePath, url.Values{"token": {token}}, nil)
+}
+
+// Introspect describes an access token or refresh token. The connected app
+// credentials of the ForceApi are sent with the request, so only ForceApis
+// created with a client secret can introspect tokens.
+func (forceApi *ForceApi) Introspect(token string) (*TokenIntrospection, error) {
+	return forceApi.IntrospectContext(context.Background(), token)
+}
+
+// IntrospectContext is like Introspect but carries ctx through the request.
+func (forceApi *ForceApi) IntrospectContext(ctx context.Context, token string) (*TokenIntrospection, error) {
+	instanceUrl, _ := forceApi.oauth.session()
+	payload := url.Values{
+		"token":         {token},
+		"client_id":     {forceApi.oauth.clientId},
+		"client_secret": {forceApi.oauth.clientSecret},
+	}
+
+	introspection := &TokenIntrospection{}
+	if err := postForm(ctx, forceApi.oauth.client(), instanceUrl+introspectPath, payload, introspection); err != nil {
+		return nil, err
+	}
+
+	return introspection, nil
+}
+
+// UserInfo returns the OpenID Connect user info of the user the session
+// belongs to.
+func (forceApi *ForceApi) UserInfo() (*UserInfo, error) {
+	return forceApi.UserInfoContext(context.Background())
+}
+
+// UserInfoContext is like UserInfo but carries ctx through the request.
+func (forceApi *ForceApi) UserInfoContext(ctx context.Context) (*UserInfo, error) {
+	userInfo := &UserInfo{}
+	if err := forceApi.getIdentity(ctx, userInfoPath, userInfo); err != nil {
+		return nil, err
+	}
+
+	return userInfo, nil
+}
+
+// Identity returns the user the session belongs to, from the identity url
+// issued with the session.
+func (forceApi *ForceApi) Identity() (*Identity, error) {
+	return forceApi.IdentityContext(context.Background())
+}
+
+// IdentityContext is like Identity but carries ctx through the request.
+func (forceApi *ForceApi) IdentityContext(ctx context.Context) (*Identity, error) {
+	forceApi.oauth.mu.RLock()
+	id := forceApi.oauth.Id
+	forceApi.oauth.mu.RUnlock()
+
+	if id == "" {
+		return nil, fmt.Errorf("Error getting identity: the session has no identity url")
+	}
+
+	// The identity url points at the login host, but the instance serves it
+	// too. Requesting it there keeps the access token on the instance.
+	u, err := url.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting identity: %v", err)
+	}
+
+	identity := &Identity{}
+	if err := forceApi.getIdentity(ctx, u.Path, identity); err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// getIdentity issues an authorized GET for path on the instance and puts the
+// unmarshalled (json) result in out. Unlike the api, the identity endpoints
+// reject expired sessions with a bare Bad_OAuth_Token, after which the request
+// is retried once with a new session.
+func (forceApi *ForceApi) getIdentity(ctx context.Context, path string, out interface{}) error {
+	for reauthenticated := false; ; reauthenticated = true {
+		if err := forceApi.oauth.Validate(); err != nil {
+			return fmt.Errorf("Error creating GET request: %v", err)
+		}
+
+		req, err := forceApi.newRequest(ctx, "GET", path, nil, contentType, responseType, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := forceApi.client().Do(req)
+		if err != nil {
+			return fmt.Errorf("Error sending GET request: %w", err)
+		}
+
+		respBytes, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("Error reading response bytes: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusForbidden && strings.TrimSpace(string(respBytes)) == badOAuthToken && !reauthenticated {
+			if err := forceApi.oauth.reauthenticate(ctx, requestToken(req)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if resp.StatusCode >= http.StatusMultipleChoices {
+			return fmt.Errorf("Error sending GET request: %v (response: %s)", resp.Status, string(respBytes))
+		}
+
+		if err := json.Unmarshal(respBytes, out); err != nil {
+			return fmt.Errorf("unable to unmarshal response to object: %v (response: %s)", err, string(respBytes))
+		}
+
+		return nil
+	}
+}
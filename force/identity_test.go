@@ -0,0 +1,95 @@
+package force
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRevokeAndIntrospect(t *testing.T) {
+	forceApi := createTest(t)
+	accessToken := forceApi.GetAccessToken()
+
+	introspection, err := forceApi.Introspect(accessToken)
+	if err != nil {
+		t.Fatalf("Failed to introspect: %v", err)
+	}
+	if !introspection.Active || introspection.ClientId != testClientId || introspection.Subject != forceApi.oauth.Id || introspection.ExpiresAt <= introspection.IssuedAt {
+		t.Fatalf("Unexpected introspection: %+v", introspection)
+	}
+
+	if err := forceApi.Revoke(accessToken); err != nil {
+		t.Fatalf("Failed to revoke: %v", err)
+	}
+	if introspection, err := forceApi.Introspect(accessToken); err != nil || *introspection != (TokenIntrospection{}) {
+		t.Fatalf("Expected revoked token to be inactive, got %+v %v", introspection, err)
+	}
+	if err := forceApi.Revoke(accessToken); !errors.Is(err, ErrorCode("invalid_token")) {
+		t.Fatalf("Expected revoked token to be rejected, got %v", err)
+	}
+
+	// The ForceApi logs in again with its credentials.
+	if _, err := forceApi.GetLimits(); err != nil || forceApi.GetAccessToken() == accessToken {
+		t.Fatalf("Failed to log in after revoking: %v", err)
+	}
+}
+
+func TestIntrospectWithoutClientSecret(t *testing.T) {
+	server := newTestFake(t)
+	forceApi, err := CreateWithAccessToken(testVersion, testClientId, server.AccessToken(), server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create: %v", err)
+	}
+
+	if _, err := forceApi.Introspect(server.AccessToken()); !errors.Is(err, ErrorCode("invalid_client")) {
+		t.Fatalf("Expected client to be rejected, got %v", err)
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	server := newTestFake(t)
+	forceApi := createFakeTest(t, server)
+
+	// Expired sessions are replaced, although the identity endpoints report
+	// them differently from the api.
+	server.ExpireSessions()
+	userInfo, err := forceApi.UserInfo()
+	if err != nil {
+		t.Fatalf("Failed to get user info: %v", err)
+	}
+	if userInfo.Subject != forceApi.oauth.Id || userInfo.UserId != "005000000000001" || userInfo.OrganizationId != "00D000000000001" {
+		t.Fatalf("Unexpected user: %+v", userInfo)
+	}
+	if userInfo.ZoneInfo != "America/Los_Angeles" || userInfo.Locale != "en_US" || userInfo.UTCOffset != -28800000 {
+		t.Fatalf("Unexpected locale: %+v", userInfo)
+	}
+	if userInfo.URLs.Rest != server.URL+"/services/data/v{version}/" || userInfo.URLs.CustomDomain != server.URL {
+		t.Fatalf("Unexpected urls: %+v", userInfo.URLs)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	server := newTestFake(t)
+	forceApi := createFakeTest(t, server)
+
+	server.ExpireSessions()
+	identity, err := forceApi.Identity()
+	if err != nil {
+		t.Fatalf("Failed to get identity: %v", err)
+	}
+	if identity.Id != forceApi.oauth.Id || identity.UserId != "005000000000001" || identity.OrganizationId != "00D000000000001" || identity.Username == "" {
+		t.Fatalf("Unexpected identity: %+v", identity)
+	}
+	if identity.Timezone != "America/Los_Angeles" || identity.Locale != "en_US" || !strings.HasSuffix(identity.URLs.SObjects, "/sobjects/") {
+		t.Fatalf("Unexpected identity: %+v", identity)
+	}
+
+	// Sessions created from an access token have no identity url.
+	forceApi, err = CreateWithAccessToken(testVersion, testClientId, server.AccessToken(), server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create: %v", err)
+	}
+	if _, err := forceApi.Identity(); err == nil {
+		t.Fatal("Expected identity without identity url to fail")
+	}
+}
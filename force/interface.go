@@ -0,0 +1,43 @@
+package force
+
+import (
+	"context"
+)
+
+// ForceClient is the subset of ForceApi covering queries, CRUD, describes and
+// limits. Depend on it instead of *ForceApi to substitute a fake in tests, such
+// as the one in the forcemock package.
+type ForceClient interface {
+	Query(query string, out interface{}) error
+	QueryContext(ctx context.Context, query string, out interface{}) error
+	QueryAll(query string, out interface{}) error
+	QueryAllContext(ctx context.Context, query string, out interface{}) error
+	QueryNext(uri string, out interface{}) error
+	QueryNextContext(ctx context.Context, uri string, out interface{}) error
+
+	GetSObject(id string, fields []string, out SObject) error
+	GetSObjectContext(ctx context.Context, id string, fields []string, out SObject) error
+	InsertSObject(in SObject, externalObj interface{}, opts ...RequestOption) (*SObjectResponse, error)
+	InsertSObjectContext(ctx context.Context, in SObject, externalObj interface{}, opts ...RequestOption) (*SObjectResponse, error)
+	UpdateSObject(id string, in SObject, externalObj interface{}, opts ...RequestOption) error
+	UpdateSObjectContext(ctx context.Context, id string, in SObject, externalObj interface{}, opts ...RequestOption) error
+	DeleteSObject(id string, in SObject) error
+	DeleteSObjectContext(ctx context.Context, id string, in SObject) error
+
+	GetSObjectByExternalId(id string, fields []string, out SObject) error
+	GetSObjectByExternalIdContext(ctx context.Context, id string, fields []string, out SObject) error
+	UpsertSObjectByExternalId(id string, in SObject, externalObj interface{}, opts ...RequestOption) (*SObjectResponse, error)
+	UpsertSObjectByExternalIdContext(ctx context.Context, id string, in SObject, externalObj interface{}, opts ...RequestOption) (*SObjectResponse, error)
+	DeleteSObjectByExternalId(id string, in SObject) error
+	DeleteSObjectByExternalIdContext(ctx context.Context, id string, in SObject) error
+
+	DescribeSObject(in SObject) (*SObjectDescription, error)
+	DescribeSObjectContext(ctx context.Context, in SObject) (*SObjectDescription, error)
+	DescribeSObjects() (map[string]*SObjectMetaData, error)
+	DescribeSObjectsContext(ctx context.Context) (map[string]*SObjectMetaData, error)
+
+	GetLimits() (*Limits, error)
+	GetLimitsContext(ctx context.Context) (*Limits, error)
+}
+
+var _ ForceClient = (*ForceApi)(nil)
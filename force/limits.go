@@ -1,5 +1,9 @@
 package force
 
+import (
+	"context"
+)
+
 type Limits map[string]Limit
 
 type Limit struct {
@@ -8,10 +12,15 @@ type Limit struct {
 }
 
 func (forceApi *ForceApi) GetLimits() (limits *Limits, err error) {
+	return forceApi.GetLimitsContext(context.Background())
+}
+
+// GetLimitsContext is like GetLimits but carries ctx through the request.
+func (forceApi *ForceApi) GetLimitsContext(ctx context.Context) (limits *Limits, err error) {
 	uri := forceApi.apiResources[limitsKey]
 
 	limits = &Limits{}
-	err = forceApi.Get(uri, nil, limits)
+	err = forceApi.GetContext(ctx, uri, nil, limits)
 
 	return
 }
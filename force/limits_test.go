@@ -5,12 +5,13 @@ import (
 )
 
 func TestLimits(t *testing.T) {
-	forceApi := createTest()
+	forceApi := createTest(t)
 	limits, err := forceApi.GetLimits()
 	if err != nil {
-		// Developer Accounts, which the testbed uses, do not have access to the limits API. So this will always fail.
-		// t.Fatalf("Failed to get Limits: %v", err)
-		t.Logf("Failed to get Limits, this is expected due to the developer account: %v", err)
+		t.Fatalf("Failed to get Limits: %v", err)
+	}
+	if (*limits)["DailyApiRequests"].Max == 0 {
+		t.Fatalf("Expected DailyApiRequests limit, got %v", limits)
 	}
 
 	t.Log(limits)
@@ -0,0 +1,196 @@
+package force
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Middleware wraps the transport used for every API and OAuth request made by
+// a ForceApi. Implementations must follow the http.RoundTripper contract and
+// clone a request before modifying it.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts an ordinary function to an http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Option configures a ForceApi when passed to one of the Create functions.
+type Option func(*ForceApi)
+
+// WithHTTPClient sets the client used for API and OAuth requests. Its
+// transport is wrapped by any middleware; the client itself is not modified.
+func WithHTTPClient(client *http.Client) Option {
+	return func(forceApi *ForceApi) {
+		forceApi.httpClient = client
+	}
+}
+
+// WithMiddleware appends middleware to the transport chain. The first
+// middleware is the outermost and sees each request first.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(forceApi *ForceApi) {
+		forceApi.middleware = append(forceApi.middleware, middleware...)
+	}
+}
+
+// TraceMiddleware sends all requests, responses, and raw JSON response bodies
+// to logger. If prefix is a non-empty string, it will be written to the front
+// of all logged strings. Access tokens and the bodies of form encoded and
+// OAuth requests, which carry credentials and tokens, are not logged, and
+// neither are bodies that are streamed, such as bulk CSV results.
+func TraceMiddleware(prefix string, logger ForceApiLogger) Middleware {
+	if prefix != "" {
+		prefix = fmt.Sprintf("%s ", prefix)
+	}
+
+	trace := func(name string, value interface{}, format string) {
+		logMsg := "%s%s " + format + "\n"
+		logger.Printf(logMsg, prefix, name, value)
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			trace("Request:", redactRequest(req), "%v")
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+
+			trace("Response:", resp, "%v")
+
+			if isOAuthRequest(req) || !isJSON(resp.Header) {
+				return resp, nil
+			}
+
+			respBytes, err := readAndRestore(&resp.Body)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading response bytes: %v", err)
+			}
+
+			trace("Response Body:", string(respBytes), "%s")
+
+			return resp, nil
+		})
+	}
+}
+
+// DebugMiddleware writes the url and JSON body of every request to w. Bodies of
+// form encoded and OAuth requests, which carry credentials, are not written,
+// and neither are bodies that are streamed, such as bulk CSV uploads.
+func DebugMiddleware(w io.Writer) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			fmt.Fprintf(w, "debug: salesforce request url: %s\n", req.URL.String())
+
+			if req.Body != nil && !isOAuthRequest(req) && isJSON(req.Header) {
+				req = req.Clone(req.Context())
+
+				reqBytes, err := readAndRestore(&req.Body)
+				if err != nil {
+					return nil, fmt.Errorf("Error reading request bytes: %v", err)
+				}
+
+				fmt.Fprintf(w, "debug: salesforce request body: %s\n", string(reqBytes))
+			}
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// redactRequest returns req, or a copy of it without its access token.
+func redactRequest(req *http.Request) *http.Request {
+	if req.Header.Get("Authorization") == "" {
+		return req
+	}
+
+	redacted := req.Clone(req.Context())
+	redacted.Header.Set("Authorization", "Bearer <redacted>")
+
+	return redacted
+}
+
+// isOAuthRequest reports whether req is form encoded or addressed to an OAuth
+// endpoint.
+func isOAuthRequest(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(req.URL.Path, "/services/oauth2/")
+}
+
+// isJSON reports whether header declares a JSON body.
+func isJSON(header http.Header) bool {
+	return strings.Contains(header.Get("Content-Type"), "json")
+}
+
+// readAndRestore drains body and replaces it with an in-memory copy.
+func readAndRestore(body *io.ReadCloser) ([]byte, error) {
+	b, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+
+	*body = ioutil.NopCloser(bytes.NewReader(b))
+
+	return b, nil
+}
+
+// buildClient returns a client whose transport runs the configured middleware
+// chain around the TraceOn and Debug toggles.
+func (forceApi *ForceApi) buildClient() *http.Client {
+	client := http.Client{}
+	if forceApi.httpClient != nil {
+		client = *forceApi.httpClient
+	}
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	transport = forceApi.toggledMiddleware(transport)
+	for i := len(forceApi.middleware) - 1; i >= 0; i-- {
+		transport = forceApi.middleware[i](transport)
+	}
+	client.Transport = transport
+
+	return &client
+}
+
+// toggledMiddleware applies the trace and debug middleware when they have been
+// switched on with TraceOn or Debug.
+func (forceApi *ForceApi) toggledMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		forceApi.mu.RLock()
+		logger, logPrefix, debugMode := forceApi.logger, forceApi.logPrefix, forceApi.debugMode
+		forceApi.mu.RUnlock()
+
+		transport := next
+		if logger != nil {
+			transport = TraceMiddleware(logPrefix, logger)(transport)
+		}
+		if debugMode {
+			transport = DebugMiddleware(os.Stdout)(transport)
+		}
+
+		return transport.RoundTrip(req)
+	})
+}
+
+// client returns the client for API requests.
+func (forceApi *ForceApi) client() *http.Client {
+	if forceApi.httpClient == nil {
+		return http.DefaultClient
+	}
+
+	return forceApi.httpClient
+}
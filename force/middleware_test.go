@@ -0,0 +1,154 @@
+package force
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" "+req.URL.Path)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	var forceApi *ForceApi
+	forceApi = createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenPath:
+			fmt.Fprintf(w, `{"access_token":"fresh","instance_url":%q}`, forceApi.oauth.InstanceUrl)
+		default:
+			if r.Header.Get("Authorization") != "Bearer fresh" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `[{"message":"Session expired or invalid","errorCode":"INVALID_SESSION_ID"}]`)
+				return
+			}
+			fmt.Fprint(w, `{"DailyApiRequests":{"Max":15000,"Remaining":14998}}`)
+		}
+	}), WithMiddleware(record("outer"), record("inner")))
+	forceApi.oauth.loginUrl = forceApi.oauth.InstanceUrl
+
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Failed to get limits: %v", err)
+	}
+
+	limitsPath := forceApi.apiResources[limitsKey]
+	expected := []string{
+		"outer " + limitsPath, "inner " + limitsPath,
+		"outer " + tokenPath, "inner " + tokenPath,
+		"outer " + limitsPath, "inner " + limitsPath,
+	}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Unexpected middleware calls:\n got: %v\nwant: %v", calls, expected)
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}), WithHTTPClient(&http.Client{Timeout: 20 * time.Millisecond}))
+
+	if _, err := forceApi.GetLimitsContext(context.Background()); err == nil {
+		t.Fatal("Expected client timeout to abort the request")
+	}
+}
+
+func TestTraceMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"DailyApiRequests":{"Max":15000,"Remaining":14998}}`)
+	}), WithMiddleware(TraceMiddleware("test", log.New(&out, "", 0))))
+
+	limits, err := forceApi.GetLimits()
+	if err != nil {
+		t.Fatalf("Failed to get limits: %v", err)
+	}
+	if (*limits)["DailyApiRequests"].Max != 15000 {
+		t.Fatalf("Traced response body was not restored: %v", limits)
+	}
+
+	for _, prefix := range []string{"test Request:", "test Response:", "test Response Body: {\"DailyApiRequests\""} {
+		if !strings.Contains(out.String(), prefix) {
+			t.Fatalf("Trace output missing %q:\n%s", prefix, out.String())
+		}
+	}
+}
+
+func TestTraceMiddlewareCredentials(t *testing.T) {
+	var out bytes.Buffer
+	server := newTestFake(t)
+	forceApi := createFakeTest(t, server, WithMiddleware(TraceMiddleware("test", log.New(&out, "", 0))))
+
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Failed to get limits: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "test Response Body:") {
+		t.Fatalf("Trace output missing api response body:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), server.AccessToken()) || strings.Contains(out.String(), "access_token") {
+		t.Fatalf("Trace output contains the session:\n%s", out.String())
+	}
+}
+
+func TestMiddlewareStreamedBodies(t *testing.T) {
+	var out bytes.Buffer
+	csv := strings.NewReader("Id,Name\n001000000000001,Acme\n")
+	transport := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/csv"}},
+			Body:       ioutil.NopCloser(csv),
+		}, nil
+	})
+
+	for name, middleware := range map[string]Middleware{
+		"trace": TraceMiddleware("test", log.New(&out, "", 0)),
+		"debug": DebugMiddleware(&out),
+	} {
+		size := csv.Len()
+		req, _ := http.NewRequest("PUT", "https://example.my.salesforce.com/services/data/v52.0/jobs/ingest/750/batches", csv)
+		req.Header.Set("Content-Type", "text/csv")
+
+		if _, err := middleware(transport).RoundTrip(req); err != nil {
+			t.Fatalf("%v: failed to round trip: %v", name, err)
+		}
+		if csv.Len() != size {
+			t.Fatalf("%v: streamed body was buffered", name)
+		}
+	}
+
+	if strings.Contains(out.String(), "Body:") || strings.Contains(out.String(), "body:") {
+		t.Fatalf("Output contains streamed body:\n%s", out.String())
+	}
+}
+
+func TestDebugMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), WithMiddleware(DebugMiddleware(&out)))
+
+	if err := forceApi.Patch("/services/data/v36.0/sobjects/Account/001", nil, map[string]string{"Name": "Test"}, nil); err != nil {
+		t.Fatalf("Failed to patch: %v", err)
+	}
+
+	if !strings.Contains(out.String(), `debug: salesforce request body: {"Name":"Test"}`) {
+		t.Fatalf("Debug output missing request body:\n%s", out.String())
+	}
+}
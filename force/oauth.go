@@ -1,20 +1,34 @@
 package force
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
-	grantType    = "password"
-	loginUri     = "https://login.salesforce.com/services/oauth2/token"
-	testLoginUri = "https://test.salesforce.com/services/oauth2/token"
+	grantType                  = "password"
+	jwtGrantType               = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	clientCredentialsGrantType = "client_credentials"
+	loginUrl                   = "https://login.salesforce.com"
+	testLoginUrl               = "https://test.salesforce.com"
+	tokenPath                  = "/services/oauth2/token"
 
-	invalidSessionErrorCode = "INVALID_SESSION_ID"
+	// Salesforce rejects assertions that expire more than three minutes out.
+	jwtLifetime = 3 * time.Minute
 )
 
 type forceOauth struct {
@@ -23,6 +37,7 @@ type forceOauth struct {
 	Id          string `json:"id"`
 	IssuedAt    string `json:"issued_at"`
 	Signature   string `json:"signature"`
+	Scope       string `json:"scope"`
 
 	clientId      string
 	clientSecret  string
@@ -31,19 +46,65 @@ type forceOauth struct {
 	password      string
 	securityToken string
 	environment   string
+	privateKey    *rsa.PrivateKey
+	loginUrl      string // Set by WithLoginURL, overriding environment.
+	httpClient    *http.Client
+
+	// clientCredentials selects the client credentials grant.
+	clientCredentials bool
+
+	tokenStore       TokenStore
+	onTokenRefreshed func(token Token)
+
+	mu     sync.RWMutex // Guards the exported fields and refreshToken, which change on every authentication.
+	authMu sync.Mutex   // Serializes reauthentication.
 }
 
 func (oauth *forceOauth) Validate() error {
-	if oauth == nil || len(oauth.InstanceUrl) == 0 || len(oauth.AccessToken) == 0 {
+	if oauth == nil {
 		return fmt.Errorf("Invalid Force Oauth Object: %#v", oauth)
 	}
 
+	instanceUrl, accessToken := oauth.session()
+	if len(instanceUrl) == 0 || len(accessToken) == 0 {
+		return fmt.Errorf("Invalid Force Oauth Object: instance url %q, access token set: %v", instanceUrl, len(accessToken) != 0)
+	}
+
 	return nil
 }
 
+// session returns the instance url and access token of the current session.
+func (oauth *forceOauth) session() (instanceUrl, accessToken string) {
+	oauth.mu.RLock()
+	defer oauth.mu.RUnlock()
+
+	return oauth.InstanceUrl, oauth.AccessToken
+}
+
+func (oauth *forceOauth) setAccessToken(accessToken string) {
+	oauth.mu.Lock()
+	defer oauth.mu.Unlock()
+
+	oauth.AccessToken = accessToken
+}
+
+// reauthenticate replaces an expired session. Callers pass the access token
+// that was rejected; when several of them race, only the first authenticates
+// and the others return once its new session is in place.
+func (oauth *forceOauth) reauthenticate(ctx context.Context, staleToken string) error {
+	oauth.authMu.Lock()
+	defer oauth.authMu.Unlock()
+
+	if _, accessToken := oauth.session(); accessToken != staleToken {
+		return nil
+	}
+
+	return oauth.Authenticate(ctx)
+}
+
 func (oauth *forceOauth) Expired(apiErrors ApiErrors) bool {
 	for _, err := range apiErrors {
-		if err.ErrorCode == invalidSessionErrorCode {
+		if err.ErrorCode == CodeInvalidSessionId {
 			return true
 		}
 	}
@@ -51,26 +112,72 @@ func (oauth *forceOauth) Expired(apiErrors ApiErrors) bool {
 	return false
 }
 
-func (oauth *forceOauth) Authenticate() error {
-	payload := url.Values{
-		"grant_type":    {grantType},
-		"client_id":     {oauth.clientId},
-		"client_secret": {oauth.clientSecret},
-		"username":      {oauth.userName},
-		"password":      {fmt.Sprintf("%v%v", oauth.password, oauth.securityToken)},
+func (oauth *forceOauth) Authenticate(ctx context.Context) error {
+	payload, err := oauth.grantPayload()
+	if err != nil {
+		return err
 	}
 
-	// Build Uri
-	uri := loginUri
-	if oauth.environment == "sandbox" {
-		uri = testLoginUri
+	// Fields missing from the response keep their current values, so a
+	// refresh that does not rotate the refresh token keeps the current one.
+	token, err := fetchToken(ctx, oauth.client(), oauth.loginUri(), payload, oauth.token())
+	if err != nil {
+		return err
+	}
+
+	oauth.setToken(token)
+
+	return oauth.tokenIssued(token)
+}
+
+// token returns the current session.
+func (oauth *forceOauth) token() Token {
+	oauth.mu.RLock()
+	defer oauth.mu.RUnlock()
+
+	return Token{
+		AccessToken:  oauth.AccessToken,
+		RefreshToken: oauth.refreshToken,
+		InstanceUrl:  oauth.InstanceUrl,
+		Id:           oauth.Id,
+		IssuedAt:     oauth.IssuedAt,
+		Signature:    oauth.Signature,
+		Scope:        oauth.Scope,
+	}
+}
+
+// setToken replaces the current session with token.
+func (oauth *forceOauth) setToken(token *Token) {
+	oauth.mu.Lock()
+	defer oauth.mu.Unlock()
+
+	oauth.AccessToken = token.AccessToken
+	oauth.refreshToken = token.RefreshToken
+	oauth.InstanceUrl = token.InstanceUrl
+	oauth.Id = token.Id
+	oauth.IssuedAt = token.IssuedAt
+	oauth.Signature = token.Signature
+	oauth.Scope = token.Scope
+}
+
+// fetchToken posts payload to the token endpoint at uri. Fields missing from
+// the response keep their values in token.
+func fetchToken(ctx context.Context, client *http.Client, uri string, payload url.Values, token Token) (*Token, error) {
+	if err := postForm(ctx, client, uri, payload, &token); err != nil {
+		return nil, err
 	}
 
+	return &token, nil
+}
+
+// postForm posts payload to the OAuth endpoint at uri and puts the
+// unmarshalled (json) result, if any, in out.
+func postForm(ctx context.Context, client *http.Client, uri string, payload url.Values, out interface{}) error {
 	// Build Body
 	body := strings.NewReader(payload.Encode())
 
 	// Build Request
-	req, err := http.NewRequest("POST", uri, body)
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, body)
 	if err != nil {
 		return fmt.Errorf("Error creating authentication request: %v", err)
 	}
@@ -80,9 +187,9 @@ func (oauth *forceOauth) Authenticate() error {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", responseType)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("Error sending authentication request: %v", err)
+		return fmt.Errorf("Error sending authentication request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -96,13 +203,191 @@ func (oauth *forceOauth) Authenticate() error {
 	if err := json.Unmarshal(respBytes, apiError); err == nil {
 		// Check if api error is valid
 		if apiError.Validate() {
+			apiError.HTTPStatusCode = resp.StatusCode
 			return apiError
 		}
 	}
 
-	if err := json.Unmarshal(respBytes, oauth); err != nil {
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("Error sending authentication request: %v (response: %s)", resp.Status, string(respBytes))
+	}
+
+	if out == nil || len(respBytes) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(respBytes, out); err != nil {
 		return fmt.Errorf("Unable to unmarshal authentication response: %v", err)
 	}
 
 	return nil
 }
+
+// tokenIssued saves token to the TokenStore and passes it to the
+// OnTokenRefreshed callback.
+func (oauth *forceOauth) tokenIssued(token *Token) error {
+	if oauth.tokenStore != nil {
+		if err := oauth.tokenStore.Save(token); err != nil {
+			return fmt.Errorf("Error saving token: %v", err)
+		}
+	}
+
+	if oauth.onTokenRefreshed != nil {
+		oauth.onTokenRefreshed(*token)
+	}
+
+	return nil
+}
+
+func (oauth *forceOauth) client() *http.Client {
+	if oauth.httpClient == nil {
+		return http.DefaultClient
+	}
+
+	return oauth.httpClient
+}
+
+// loginHost returns the url set with WithLoginURL, or the login host of the
+// configured environment.
+func (oauth *forceOauth) loginHost() string {
+	if oauth.loginUrl != "" {
+		return oauth.loginUrl
+	}
+	if oauth.environment == "sandbox" {
+		return testLoginUrl
+	}
+
+	return loginUrl
+}
+
+// loginUri returns the token endpoint.
+func (oauth *forceOauth) loginUri() string {
+	return oauth.loginHost() + tokenPath
+}
+
+// jwtAudience returns the audience of JWT bearer assertions, which is the
+// login host. Assertions sent to a My Domain are addressed to the
+// login.salesforce.com or test.salesforce.com authorization server instead.
+func (oauth *forceOauth) jwtAudience() string {
+	loginHost := oauth.loginHost()
+	if u, err := url.Parse(loginHost); err == nil {
+		switch {
+		case strings.HasSuffix(u.Host, ".sandbox.my.salesforce.com"):
+			return testLoginUrl
+		case strings.HasSuffix(u.Host, ".my.salesforce.com"):
+			return loginUrl
+		}
+	}
+
+	return loginHost
+}
+
+// grantPayload builds the token request form for the configured grant. A JWT
+// bearer assertion is signed on every call, so reauthenticating after an
+// expired session always presents a fresh assertion. A refresh token takes
+// precedence over a password.
+func (oauth *forceOauth) grantPayload() (url.Values, error) {
+	if oauth.privateKey != nil {
+		assertion, err := oauth.jwtAssertion(time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		return url.Values{
+			"grant_type": {jwtGrantType},
+			"assertion":  {assertion},
+		}, nil
+	}
+
+	oauth.mu.RLock()
+	refreshToken := oauth.refreshToken
+	oauth.mu.RUnlock()
+
+	if oauth.clientCredentials {
+		return url.Values{
+			"grant_type":    {clientCredentialsGrantType},
+			"client_id":     {oauth.clientId},
+			"client_secret": {oauth.clientSecret},
+		}, nil
+	}
+
+	if refreshToken != "" {
+		payload := url.Values{
+			"grant_type":    {refreshTokenGrantType},
+			"refresh_token": {refreshToken},
+			"client_id":     {oauth.clientId},
+		}
+		if oauth.clientSecret != "" {
+			payload.Set("client_secret", oauth.clientSecret)
+		}
+
+		return payload, nil
+	}
+
+	return url.Values{
+		"grant_type":    {grantType},
+		"client_id":     {oauth.clientId},
+		"client_secret": {oauth.clientSecret},
+		"username":      {oauth.userName},
+		"password":      {fmt.Sprintf("%v%v", oauth.password, oauth.securityToken)},
+	}, nil
+}
+
+type jwtClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// jwtAssertion returns an RS256 signed assertion for the JWT bearer flow.
+func (oauth *forceOauth) jwtAssertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("Error encoding assertion header: %v", err)
+	}
+
+	claims, err := json.Marshal(jwtClaims{
+		Issuer:    oauth.clientId,
+		Subject:   oauth.userName,
+		Audience:  oauth.jwtAudience(),
+		ExpiresAt: now.Add(jwtLifetime).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error encoding assertion claims: %v", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hashed := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, oauth.privateKey, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("Error signing assertion: %v", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parsePrivateKey decodes a PEM encoded PKCS#1 or PKCS#8 RSA private key.
+func parsePrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("Unable to decode private key: no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse private key: %v", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Unable to parse private key: expected RSA key, got %T", key)
+	}
+
+	return rsaKey, nil
+}
@@ -1,13 +1,264 @@
 package force
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
 )
 
 func TestOauth(t *testing.T) {
-	forceApi := createTest()
+	forceApi := createTest(t)
 	// Verify oauth object is valid
 	if err := forceApi.oauth.Validate(); err != nil {
 		t.Fatalf("Oauth object is invlaid: %#v", err)
 	}
 }
+
+func TestConcurrentReauthentication(t *testing.T) {
+	server := newTestFake(t)
+
+	var logins int32
+	countLogins := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.String() == server.TokenURL() {
+				atomic.AddInt32(&logins, 1)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+	forceApi := createFakeTest(t, server, WithMiddleware(countLogins))
+	atomic.StoreInt32(&logins, 0)
+	server.ExpireSessions()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 60)
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_, err := forceApi.GetLimits()
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := forceApi.DescribeSObject(&sobjects.Account{})
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- forceApi.GetSObject(AccountId, []string{"Name"}, &sobjects.Account{})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+	}
+	if logins != 1 {
+		t.Fatalf("Expected a single reauthentication, got %v", logins)
+	}
+	if forceApi.GetAccessToken() != server.AccessToken() {
+		t.Fatalf("Expected the new session to be used")
+	}
+}
+
+func TestCreateWithClientCredentials(t *testing.T) {
+	server := newTestFake(t)
+
+	var grants []url.Values
+	recordGrants := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.String() == server.TokenURL() {
+				body, _ := io.ReadAll(req.Body)
+				form, _ := url.ParseQuery(string(body))
+				grants = append(grants, form)
+				req.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			return next.RoundTrip(req)
+		})
+	}
+
+	forceApi, err := CreateWithClientCredentials(testVersion, testClientId, testClientSecret, server.URL+"/", WithMiddleware(recordGrants))
+	if err != nil {
+		t.Fatalf("Failed to create: %v", err)
+	}
+
+	server.ExpireSessions()
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Failed to reauthenticate: %v", err)
+	}
+
+	if len(grants) != 2 {
+		t.Fatalf("Expected a token request per session, got %v", grants)
+	}
+	want := url.Values{"grant_type": {"client_credentials"}, "client_id": {testClientId}, "client_secret": {testClientSecret}}
+	for _, grant := range grants {
+		if grant.Encode() != want.Encode() {
+			t.Fatalf("Unexpected token request: %v", grant)
+		}
+	}
+}
+
+func TestLoginHost(t *testing.T) {
+	for _, test := range []struct {
+		environment, loginUrl string
+		tokenUri, audience    string
+	}{
+		{"production", "", "https://login.salesforce.com/services/oauth2/token", "https://login.salesforce.com"},
+		{"sandbox", "", "https://test.salesforce.com/services/oauth2/token", "https://test.salesforce.com"},
+		{"production", "https://acme.my.salesforce.com/", "https://acme.my.salesforce.com/services/oauth2/token", "https://login.salesforce.com"},
+		{"production", "https://acme--dev.sandbox.my.salesforce.com", "https://acme--dev.sandbox.my.salesforce.com/services/oauth2/token", "https://test.salesforce.com"},
+		{"sandbox", "https://acme.my.site.com/customers", "https://acme.my.site.com/customers/services/oauth2/token", "https://acme.my.site.com/customers"},
+	} {
+		forceApi := newForceApi(testVersion, &forceOauth{environment: test.environment}, []Option{WithLoginURL(test.loginUrl)})
+		if got := forceApi.oauth.loginUri(); got != test.tokenUri {
+			t.Errorf("%v %v: expected token uri %v, got %v", test.environment, test.loginUrl, test.tokenUri, got)
+		}
+		if got := forceApi.oauth.jwtAudience(); got != test.audience {
+			t.Errorf("%v %v: expected audience %v, got %v", test.environment, test.loginUrl, test.audience, got)
+		}
+	}
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+
+	var tokenRequests, apiRequests int
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case tokenPath:
+			tokenRequests++
+			if grant := r.PostFormValue("grant_type"); grant != jwtGrantType {
+				t.Errorf("Unexpected grant type: %v", grant)
+			}
+			claims, err := verifyAssertion(&key.PublicKey, r.PostFormValue("assertion"))
+			if err != nil {
+				t.Errorf("Invalid assertion: %v", err)
+			} else if claims.Issuer != testClientId || claims.Subject != testUserName || claims.Audience != server.URL {
+				t.Errorf("Unexpected assertion claims: %+v", claims)
+			}
+			fmt.Fprintf(w, `{"access_token":"token-%d","instance_url":%q}`, tokenRequests, server.URL)
+		case "/services/data/v36.0":
+			apiRequests++
+			if apiRequests == 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `[{"message":"Session expired or invalid","errorCode":"INVALID_SESSION_ID"}]`)
+				return
+			}
+			if auth := r.Header.Get("Authorization"); auth != "Bearer token-2" {
+				t.Errorf("Retried request used stale token: %v", auth)
+			}
+			fmt.Fprint(w, `{"sobjects":"/services/data/v36.0/sobjects"}`)
+		default:
+			t.Errorf("Unexpected request: %v", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	forceApi := &ForceApi{
+		apiResources: make(map[string]string),
+		apiVersion:   testVersion,
+		oauth: &forceOauth{
+			clientId:   testClientId,
+			userName:   testUserName,
+			privateKey: key,
+			loginUrl:   server.URL,
+		},
+	}
+
+	if err := forceApi.oauth.Authenticate(context.Background()); err != nil {
+		t.Fatalf("Unable to authenticate: %v", err)
+	}
+	if err := forceApi.getApiResources(context.Background()); err != nil {
+		t.Fatalf("Unable to get api resources: %v", err)
+	}
+
+	if tokenRequests != 2 {
+		t.Fatalf("Expected expired session to mint a new assertion, got %d token requests", tokenRequests)
+	}
+	if forceApi.apiResources[sObjectsKey] == "" {
+		t.Fatalf("Api resources were not decoded: %v", forceApi.apiResources)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unable to marshal key: %v", err)
+	}
+
+	for name, block := range map[string]*pem.Block{
+		"pkcs1": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"pkcs8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		parsed, err := parsePrivateKey(pem.EncodeToMemory(block))
+		if err != nil {
+			t.Fatalf("Unable to parse %v key: %v", name, err)
+		}
+		if !parsed.Equal(key) {
+			t.Fatalf("Parsed %v key does not match", name)
+		}
+	}
+
+	if _, err := parsePrivateKey([]byte("not a key")); err == nil {
+		t.Fatal("Expected error parsing invalid key")
+	}
+}
+
+func verifyAssertion(key *rsa.PublicKey, assertion string) (*jwtClaims, error) {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed assertion: %v", assertion)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	claims := &jwtClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
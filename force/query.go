@@ -1,6 +1,7 @@
 package force
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -10,6 +11,8 @@ const (
 	BaseQueryString = "SELECT %v FROM %v"
 )
 
+// BuildQuery joins constraints with AND without escaping them. Use Select to
+// build queries containing untrusted values.
 func BuildQuery(fields, table string, constraints []string) string {
 	query := fmt.Sprintf(BaseQueryString, fields, table)
 	if len(constraints) > 0 {
@@ -22,28 +25,43 @@ func BuildQuery(fields, table string, constraints []string) string {
 // Use the Query resource to execute a SOQL query that returns all the results in a single response,
 // or if needed, returns part of the results and an identifier used to retrieve the remaining results.
 func (forceApi *ForceApi) Query(query string, out interface{}) (err error) {
+	return forceApi.QueryContext(context.Background(), query, out)
+}
+
+// QueryContext is like Query but carries ctx through the request.
+func (forceApi *ForceApi) QueryContext(ctx context.Context, query string, out interface{}) (err error) {
 	uri := forceApi.apiResources[queryKey]
 
 	params := url.Values{
 		"q": {query},
 	}
 
-	return forceApi.Get(uri, params, out)
+	return forceApi.GetContext(ctx, uri, params, out)
 }
 
 // Use the QueryAll resource to execute a SOQL query that includes information about records that have
 // been deleted because of a merge or delete. Use QueryAll rather than Query, because the Query resource
 // will automatically filter out items that have been deleted.
 func (forceApi *ForceApi) QueryAll(query string, out interface{}) (err error) {
+	return forceApi.QueryAllContext(context.Background(), query, out)
+}
+
+// QueryAllContext is like QueryAll but carries ctx through the request.
+func (forceApi *ForceApi) QueryAllContext(ctx context.Context, query string, out interface{}) (err error) {
 	uri := forceApi.apiResources[queryAllKey]
 
 	params := url.Values{
 		"q": {query},
 	}
 
-	return forceApi.Get(uri, params, out)
+	return forceApi.GetContext(ctx, uri, params, out)
 }
 
 func (forceApi *ForceApi) QueryNext(uri string, out interface{}) (err error) {
-	return forceApi.Get(uri, nil, out)
+	return forceApi.QueryNextContext(context.Background(), uri, out)
+}
+
+// QueryNextContext is like QueryNext but carries ctx through the request.
+func (forceApi *ForceApi) QueryNextContext(ctx context.Context, uri string, out interface{}) (err error) {
+	return forceApi.GetContext(ctx, uri, nil, out)
 }
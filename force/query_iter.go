@@ -0,0 +1,193 @@
+package force
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+// QueryIter iterates over the records of a SOQL query one at a time, lazily
+// following nextRecordsUrl whenever a batch is exhausted.
+//
+//	iter := force.NewQueryIter[sobjects.Account](ctx, forceApi, "SELECT Id, Name FROM Account")
+//	defer iter.Close()
+//	for iter.Next() {
+//		account := iter.Record()
+//		...
+//	}
+//	if err := iter.Err(); err != nil {
+//		...
+//	}
+type QueryIter[T any] struct {
+	forceApi  *ForceApi
+	ctx       context.Context
+	cancel    context.CancelFunc
+	query     string
+	queryKey  string
+	prefetch  bool
+	started   bool
+	closed    bool
+	records   []T
+	index     int
+	totalSize int
+	nextUri   string
+	pending   chan queryPageResult[T]
+	err       error
+}
+
+// QueryIterOption configures a QueryIter.
+type QueryIterOption func(*queryIterConfig)
+
+type queryIterConfig struct {
+	prefetch bool
+}
+
+// WithPrefetch fetches the next batch in the background while the caller
+// processes the current one.
+func WithPrefetch() QueryIterOption {
+	return func(config *queryIterConfig) {
+		config.prefetch = true
+	}
+}
+
+type queryPage[T any] struct {
+	sobjects.BaseQuery
+	Records []T `force:"records"`
+}
+
+type queryPageResult[T any] struct {
+	page *queryPage[T]
+	err  error
+}
+
+// NewQueryIter returns an iterator over the records matched by query using
+// the Query resource. No request is made until Next or TotalSize is called.
+func NewQueryIter[T any](ctx context.Context, forceApi *ForceApi, query string, opts ...QueryIterOption) *QueryIter[T] {
+	return newQueryIter[T](ctx, forceApi, query, queryKey, opts)
+}
+
+// NewQueryAllIter is like NewQueryIter but uses the QueryAll resource, so
+// deleted and archived records are included.
+func NewQueryAllIter[T any](ctx context.Context, forceApi *ForceApi, query string, opts ...QueryIterOption) *QueryIter[T] {
+	return newQueryIter[T](ctx, forceApi, query, queryAllKey, opts)
+}
+
+func newQueryIter[T any](ctx context.Context, forceApi *ForceApi, query, key string, opts []QueryIterOption) *QueryIter[T] {
+	config := &queryIterConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &QueryIter[T]{
+		forceApi: forceApi,
+		ctx:      ctx,
+		cancel:   cancel,
+		query:    query,
+		queryKey: key,
+		prefetch: config.prefetch,
+		index:    -1,
+	}
+}
+
+// Next advances to the next record, fetching the next batch if needed. It
+// returns false when the records are exhausted, an error occurs or the
+// iterator is closed.
+func (iter *QueryIter[T]) Next() bool {
+	if iter.closed || iter.err != nil {
+		return false
+	}
+
+	iter.index++
+	for iter.index >= len(iter.records) {
+		if !iter.fetch() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Record returns the current record.
+func (iter *QueryIter[T]) Record() T {
+	return iter.records[iter.index]
+}
+
+// TotalSize returns the number of records matched by the query, fetching the
+// first batch if it has not been fetched yet.
+func (iter *QueryIter[T]) TotalSize() int {
+	if !iter.started && !iter.closed {
+		iter.fetch()
+		iter.index = -1
+	}
+
+	return iter.totalSize
+}
+
+// Err returns the error, if any, that stopped the iteration.
+func (iter *QueryIter[T]) Err() error {
+	return iter.err
+}
+
+// Close stops the iteration and cancels any batch being prefetched. It is
+// safe to call Close more than once.
+func (iter *QueryIter[T]) Close() error {
+	iter.closed = true
+	iter.cancel()
+
+	return nil
+}
+
+// fetch replaces the current batch with the next one. It returns false when
+// there is no further batch.
+func (iter *QueryIter[T]) fetch() bool {
+	var result queryPageResult[T]
+	switch {
+	case !iter.started:
+		iter.started = true
+		params := url.Values{
+			"q": {iter.query},
+		}
+		result = iter.load(iter.forceApi.apiResources[iter.queryKey], params)
+	case iter.pending != nil:
+		result = <-iter.pending
+		iter.pending = nil
+	case iter.nextUri != "":
+		result = iter.load(iter.nextUri, nil)
+	default:
+		return false
+	}
+
+	if result.err != nil {
+		iter.err = result.err
+		return false
+	}
+
+	iter.records = result.page.Records
+	iter.index = 0
+	iter.totalSize = int(result.page.TotalSize)
+	iter.nextUri = ""
+	if !result.page.Done {
+		iter.nextUri = result.page.NextRecordsUri
+	}
+
+	if iter.prefetch && iter.nextUri != "" {
+		iter.pending = make(chan queryPageResult[T], 1)
+		go func(uri string, pending chan<- queryPageResult[T]) {
+			pending <- iter.load(uri, nil)
+		}(iter.nextUri, iter.pending)
+	}
+
+	return true
+}
+
+func (iter *QueryIter[T]) load(uri string, params url.Values) queryPageResult[T] {
+	page := &queryPage[T]{}
+	if err := iter.forceApi.GetContext(iter.ctx, uri, params, page); err != nil {
+		return queryPageResult[T]{err: err}
+	}
+
+	return queryPageResult[T]{page: page}
+}
@@ -0,0 +1,104 @@
+package force
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+// pagedQueryHandler serves three batches of two accounts each.
+func pagedQueryHandler(requests *int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		page := 1
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/query") || strings.HasSuffix(r.URL.Path, "/queryAll"):
+			if r.URL.Query().Get("q") != "SELECT Id FROM Account" {
+				http.Error(w, "unexpected query", http.StatusBadRequest)
+				return
+			}
+		default:
+			fmt.Sscanf(r.URL.Path[strings.LastIndex(r.URL.Path, "-")+1:], "%d", &page)
+		}
+
+		next := ""
+		if page < 3 {
+			next = fmt.Sprintf(`,"nextRecordsUrl":"/services/data/v36.0/query/01g-%d"`, page+1)
+		}
+		fmt.Fprintf(w, `{"done":%t,"totalSize":6%s,"records":[{"Id":"00%d-a"},{"Id":"00%d-b"}]}`, page == 3, next, page, page)
+	})
+}
+
+func TestQueryIter(t *testing.T) {
+	for name, opts := range map[string][]QueryIterOption{"sequential": nil, "prefetch": {WithPrefetch()}} {
+		t.Run(name, func(t *testing.T) {
+			var requests int32
+			forceApi := createTestServer(t, pagedQueryHandler(&requests))
+
+			iter := NewQueryIter[sobjects.Account](context.Background(), forceApi, "SELECT Id FROM Account", opts...)
+			defer iter.Close()
+
+			if requests != 0 {
+				t.Fatal("Iterator fetched before Next was called")
+			}
+			if size := iter.TotalSize(); size != 6 {
+				t.Fatalf("Unexpected total size: %d", size)
+			}
+
+			var ids []string
+			for iter.Next() {
+				ids = append(ids, iter.Record().Id)
+			}
+			if err := iter.Err(); err != nil {
+				t.Fatalf("Iteration failed: %v", err)
+			}
+
+			if strings.Join(ids, ",") != "001-a,001-b,002-a,002-b,003-a,003-b" {
+				t.Fatalf("Unexpected records: %v", ids)
+			}
+			if requests != 3 {
+				t.Fatalf("Expected 3 batch requests, got %d", requests)
+			}
+		})
+	}
+}
+
+func TestQueryIterEarlyStop(t *testing.T) {
+	var requests int32
+	forceApi := createTestServer(t, pagedQueryHandler(&requests))
+
+	iter := NewQueryAllIter[*sobjects.Account](context.Background(), forceApi, "SELECT Id FROM Account")
+	for iter.Next() {
+		if iter.Record().Id == "001-b" {
+			iter.Close()
+		}
+	}
+
+	if iter.Next() {
+		t.Fatal("Expected closed iterator to stop")
+	}
+	if requests != 1 {
+		t.Fatalf("Expected only the first batch to be requested, got %d requests", requests)
+	}
+}
+
+func TestQueryIterError(t *testing.T) {
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `[{"message":"unexpected token","errorCode":"MALFORMED_QUERY"}]`)
+	}))
+
+	iter := NewQueryIter[sobjects.Account](context.Background(), forceApi, "SELECT")
+	if iter.Next() {
+		t.Fatal("Expected iteration to fail")
+	}
+	if err := iter.Err(); err == nil || !strings.Contains(err.Error(), "MALFORMED_QUERY") {
+		t.Fatalf("Expected MALFORMED_QUERY error, got: %v", err)
+	}
+}
@@ -1,9 +1,15 @@
 package force
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/nimajalali/go-force/forcetest"
 	"github.com/nimajalali/go-force/sobjects"
 )
 
@@ -17,7 +23,7 @@ type AccountQueryResponse struct {
 }
 
 func TestQuery(t *testing.T) {
-	forceApi := createTest()
+	forceApi := createTest(t)
 	desc, err := forceApi.DescribeSObject(&sobjects.Account{})
 	if err != nil {
 		t.Fatalf("Failed to retrieve description of sobject: %v", err)
@@ -33,7 +39,7 @@ func TestQuery(t *testing.T) {
 }
 
 func TestQueryAll(t *testing.T) {
-	forceApi := createTest()
+	forceApi := createTest(t)
 	// First Insert and Delete an Account
 	newId := insertSObject(forceApi, t)
 	deleteSObject(forceApi, t, newId)
@@ -58,5 +64,73 @@ func TestQueryAll(t *testing.T) {
 }
 
 func TestQueryNext(t *testing.T) {
-	// TODO
+	server := newTestFake(t)
+	server.Seed("Account", forcetest.Record{"Name": "B"}, forcetest.Record{"Name": "C"})
+	server.SetQueryBatchSize(2)
+	forceApi := createFakeTest(t, server)
+
+	list := &AccountQueryResponse{}
+	if err := forceApi.Query("SELECT Id, Name FROM Account ORDER BY Name DESC", list); err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if list.Done || list.TotalSize != 3 || len(list.Records) != 2 {
+		t.Fatalf("Expected first page of 2 out of 3 records, got %+v", list)
+	}
+
+	names := []string{}
+	for {
+		for _, record := range list.Records {
+			names = append(names, record.Name)
+		}
+		if list.Done {
+			break
+		}
+
+		next := &AccountQueryResponse{}
+		if err := forceApi.QueryNext(list.NextRecordsUri, next); err != nil {
+			t.Fatalf("Failed to query next: %v", err)
+		}
+		list = next
+	}
+
+	if strings.Join(names, ",") != "Test Account,C,B" {
+		t.Fatalf("Unexpected records: %v", names)
+	}
+}
+
+func TestQueryContextCanceled(t *testing.T) {
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := forceApi.QueryContext(ctx, "SELECT Id FROM Account", &AccountQueryResponse{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got: %v", err)
+	}
+}
+
+func TestQueryContextCanceledDuringReauthentication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == tokenPath {
+			t.Error("Reauthentication was attempted with a canceled context")
+			return
+		}
+
+		// Expire the session and cancel the caller before the retry.
+		cancel()
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `[{"message":"Session expired or invalid","errorCode":"INVALID_SESSION_ID"}]`)
+	}))
+	forceApi.oauth.loginUrl = forceApi.oauth.InstanceUrl
+
+	err := forceApi.QueryContext(ctx, "SELECT Id FROM Account", &AccountQueryResponse{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected canceled reauthentication, got: %v", err)
+	}
 }
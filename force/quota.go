@@ -0,0 +1,143 @@
+package force
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const limitInfoHeader = "Sforce-Limit-Info"
+
+// ErrQuotaExceeded is returned by a QuotaGovernor that rejects calls once the
+// daily api request threshold has been reached.
+var ErrQuotaExceeded = errors.New("force: daily api request threshold exceeded")
+
+// ApiUsage is the org's DailyApiRequests consumption as reported by the
+// Sforce-Limit-Info header of the most recent response.
+type ApiUsage struct {
+	Used int64
+	Max  int64
+}
+
+// Percent returns the used share of the daily quota, from 0 to 100.
+func (usage ApiUsage) Percent() float64 {
+	if usage.Max <= 0 {
+		return 0
+	}
+
+	return float64(usage.Used) / float64(usage.Max) * 100
+}
+
+// QuotaGovernor slows down or rejects api calls once Threshold percent of the
+// daily api request quota is consumed, preventing one client from locking the
+// whole org out of the api.
+type QuotaGovernor struct {
+	// Threshold is the percentage of DailyApiRequests, from 0 to 100, at
+	// which the governor takes effect.
+	Threshold float64
+	// Reject makes calls over the threshold fail with ErrQuotaExceeded.
+	// Otherwise each call is delayed by Delay.
+	Reject bool
+	Delay  time.Duration
+	// OnThreshold, if set, is called when a response first reports usage at
+	// or above Threshold. It is called again only after usage has dropped
+	// below Threshold, which happens when the daily quota resets.
+	OnThreshold func(usage ApiUsage)
+}
+
+// WithQuotaGovernor enables client-side throttling based on api usage.
+func WithQuotaGovernor(governor QuotaGovernor) Option {
+	return func(forceApi *ForceApi) {
+		forceApi.quota.governor = &governor
+	}
+}
+
+type quotaTracker struct {
+	mu       sync.Mutex
+	usage    ApiUsage
+	governor *QuotaGovernor
+	alerted  bool
+}
+
+// ApiUsage returns the daily api usage last reported by Salesforce. It is the
+// zero value until a response carrying the Sforce-Limit-Info header is seen.
+func (forceApi *ForceApi) ApiUsage() ApiUsage {
+	forceApi.quota.mu.Lock()
+	defer forceApi.quota.mu.Unlock()
+
+	return forceApi.quota.usage
+}
+
+// recordApiUsage updates the tracked usage from a response header.
+func (forceApi *ForceApi) recordApiUsage(header http.Header) {
+	usage, ok := parseLimitInfo(header.Get(limitInfoHeader))
+	if !ok {
+		return
+	}
+
+	quota := &forceApi.quota
+	quota.mu.Lock()
+	quota.usage = usage
+
+	var onThreshold func(ApiUsage)
+	if quota.governor != nil {
+		over := usage.Percent() >= quota.governor.Threshold
+		if over && !quota.alerted {
+			onThreshold = quota.governor.OnThreshold
+		}
+		quota.alerted = over
+	}
+	quota.mu.Unlock()
+
+	if onThreshold != nil {
+		onThreshold(usage)
+	}
+}
+
+// governQuota throttles or rejects a call when usage is over the threshold.
+func (forceApi *ForceApi) governQuota(ctx context.Context) error {
+	quota := &forceApi.quota
+	quota.mu.Lock()
+	governor, usage := quota.governor, quota.usage
+	quota.mu.Unlock()
+
+	if governor == nil || usage.Max == 0 || usage.Percent() < governor.Threshold {
+		return nil
+	}
+
+	if governor.Reject {
+		return fmt.Errorf("%w: %d of %d used", ErrQuotaExceeded, usage.Used, usage.Max)
+	}
+
+	return sleepContext(ctx, governor.Delay)
+}
+
+// parseLimitInfo extracts the api-usage entry from a Sforce-Limit-Info header
+// such as "api-usage=18/5000,per-app-api-usage=17/250(appName=sample-app)".
+func parseLimitInfo(value string) (ApiUsage, bool) {
+	for _, entry := range strings.Split(value, ",") {
+		name, counts, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if name != "api-usage" {
+			continue
+		}
+
+		used, max, _ := strings.Cut(counts, "/")
+		usedCount, err := strconv.ParseInt(used, 10, 64)
+		if err != nil {
+			return ApiUsage{}, false
+		}
+		maxCount, err := strconv.ParseInt(max, 10, 64)
+		if err != nil {
+			return ApiUsage{}, false
+		}
+
+		return ApiUsage{Used: usedCount, Max: maxCount}, true
+	}
+
+	return ApiUsage{}, false
+}
@@ -0,0 +1,110 @@
+package force
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseLimitInfo(t *testing.T) {
+	tests := map[string]ApiUsage{
+		"api-usage=18/5000": {Used: 18, Max: 5000},
+		"per-app-api-usage=17/250(appName=sample-app), api-usage=25/100": {Used: 25, Max: 100},
+	}
+	for header, expected := range tests {
+		usage, ok := parseLimitInfo(header)
+		if !ok || usage != expected {
+			t.Fatalf("Unexpected usage for %q: %+v", header, usage)
+		}
+	}
+
+	for _, header := range []string{"", "api-usage=abc/100", "per-app-api-usage=17/250(appName=sample-app)"} {
+		if _, ok := parseLimitInfo(header); ok {
+			t.Fatalf("Expected %q not to parse", header)
+		}
+	}
+}
+
+// usageHandler reports used api requests out of a daily max of 100, counting
+// up from start with every request.
+func usageHandler(used *int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(limitInfoHeader, fmt.Sprintf("api-usage=%d/100", atomic.AddInt64(used, 1)))
+		fmt.Fprint(w, `{}`)
+	})
+}
+
+func TestApiUsage(t *testing.T) {
+	used := int64(41)
+	forceApi := createTestServer(t, usageHandler(&used))
+
+	if usage := forceApi.ApiUsage(); usage != (ApiUsage{}) {
+		t.Fatalf("Expected no usage before the first request, got %+v", usage)
+	}
+
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Failed to get limits: %v", err)
+	}
+
+	usage := forceApi.ApiUsage()
+	if usage.Used != 42 || usage.Max != 100 || usage.Percent() != 42 {
+		t.Fatalf("Unexpected usage: %+v", usage)
+	}
+}
+
+func TestQuotaGovernorReject(t *testing.T) {
+	used := int64(88)
+	var alerts []ApiUsage
+	forceApi := createTestServer(t, usageHandler(&used), WithQuotaGovernor(QuotaGovernor{
+		Threshold: 90,
+		Reject:    true,
+		OnThreshold: func(usage ApiUsage) {
+			alerts = append(alerts, usage)
+		},
+	}))
+
+	// 89% then 90%, both allowed because usage is only known after the call.
+	for i := 0; i < 2; i++ {
+		if _, err := forceApi.GetLimits(); err != nil {
+			t.Fatalf("Expected call %d under threshold to succeed: %v", i, err)
+		}
+	}
+
+	if _, err := forceApi.GetLimits(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected call over threshold to be rejected, got: %v", err)
+	}
+	if used != 90 {
+		t.Fatalf("Rejected call reached the server")
+	}
+
+	if len(alerts) != 1 || alerts[0].Used != 90 {
+		t.Fatalf("Expected a single alert at 90%%, got %+v", alerts)
+	}
+}
+
+func TestQuotaGovernorThrottle(t *testing.T) {
+	used := int64(95)
+	forceApi := createTestServer(t, usageHandler(&used), WithQuotaGovernor(QuotaGovernor{
+		Threshold: 90,
+		Delay:     30 * time.Millisecond,
+	}))
+
+	start := time.Now()
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Failed to get limits: %v", err)
+	}
+	if time.Since(start) >= 30*time.Millisecond {
+		t.Fatal("First call was throttled before usage was known")
+	}
+
+	start = time.Now()
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Expected throttled call to succeed: %v", err)
+	}
+	if time.Since(start) < 30*time.Millisecond {
+		t.Fatal("Expected call over threshold to be delayed")
+	}
+}
@@ -0,0 +1,162 @@
+package force
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ReplayStore persists the replay id of the last event processed on each
+// channel, so that a StreamingClient resumes after it instead of losing the
+// events published while it was down. Implementations must be safe for
+// concurrent use.
+type ReplayStore interface {
+	// Load returns the replay id saved for channel, if any.
+	Load(channel string) (replayId int64, ok bool, err error)
+	// Save records replayId as the last event processed on channel.
+	Save(channel string, replayId int64) error
+}
+
+// StreamingOption configures a StreamingClient.
+type StreamingOption func(*StreamingClient)
+
+// WithReplayStore makes the client resume subscriptions from the replay ids
+// in store and save the replay id of every event passed to Event.Commit.
+// Events are delivered at least once: an event that was received but not
+// committed when the client stopped is delivered again.
+func WithReplayStore(store ReplayStore) StreamingOption {
+	return func(client *StreamingClient) {
+		client.store = store
+	}
+}
+
+// Commit marks the event as processed, saving its replay id to the
+// ReplayStore of the client. Call it once the event has been handled, in the
+// order the events were received.
+func (event *Event) Commit() error {
+	if event.client == nil {
+		return nil
+	}
+
+	return event.client.commit(event)
+}
+
+// MemoryReplayStore keeps replay ids in memory, which lets a client resume
+// after reconnecting within the same process.
+type MemoryReplayStore struct {
+	mu        sync.Mutex
+	replayIds map[string]int64
+}
+
+// NewMemoryReplayStore returns an empty MemoryReplayStore.
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{replayIds: map[string]int64{}}
+}
+
+func (store *MemoryReplayStore) Load(channel string) (int64, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	replayId, ok := store.replayIds[channel]
+	return replayId, ok, nil
+}
+
+func (store *MemoryReplayStore) Save(channel string, replayId int64) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.replayIds[channel] = replayId
+	return nil
+}
+
+// FileReplayStore keeps replay ids in a JSON file mapping channels to replay
+// ids. The file is replaced atomically on every save, so a crash leaves
+// either the previous or the new contents.
+type FileReplayStore struct {
+	path string
+
+	mu        sync.Mutex
+	replayIds map[string]int64
+}
+
+// NewFileReplayStore opens the store at path, which is created on the first
+// save if it does not exist.
+func NewFileReplayStore(path string) (*FileReplayStore, error) {
+	store := &FileReplayStore{
+		path:      path,
+		replayIds: map[string]int64{},
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading replay store: %v", err)
+	}
+
+	if err := json.Unmarshal(contents, &store.replayIds); err != nil {
+		return nil, fmt.Errorf("Error reading replay store %v: %v", path, err)
+	}
+
+	return store, nil
+}
+
+func (store *FileReplayStore) Load(channel string) (int64, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	replayId, ok := store.replayIds[channel]
+	return replayId, ok, nil
+}
+
+func (store *FileReplayStore) Save(channel string, replayId int64) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	previous, existed := store.replayIds[channel]
+	store.replayIds[channel] = replayId
+
+	if err := store.write(); err != nil {
+		if existed {
+			store.replayIds[channel] = previous
+		} else {
+			delete(store.replayIds, channel)
+		}
+		return fmt.Errorf("Error writing replay store: %v", err)
+	}
+
+	return nil
+}
+
+// write replaces the file through a synced temporary file in the same
+// directory.
+func (store *FileReplayStore) write() error {
+	contents, err := json.Marshal(store.replayIds)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(store.path), filepath.Base(store.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), store.path)
+}
@@ -0,0 +1,97 @@
+package force
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileReplayStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "replay.json")
+
+	store, err := NewFileReplayStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if _, ok, err := store.Load("/data/ChangeEvents"); ok || err != nil {
+		t.Fatalf("Expected empty store, got %v %v", ok, err)
+	}
+
+	if err := store.Save("/data/ChangeEvents", 41); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	if err := store.Save("/data/ChangeEvents", 42); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	if err := store.Save("/event/Order__e", 7); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	reopened, err := NewFileReplayStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if replayId, ok, err := reopened.Load("/data/ChangeEvents"); replayId != 42 || !ok || err != nil {
+		t.Fatalf("Expected saved replay id, got %v %v %v", replayId, ok, err)
+	}
+	if replayId, _, _ := reopened.Load("/event/Order__e"); replayId != 7 {
+		t.Fatalf("Expected saved replay id, got %v", replayId)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("Expected temporary files to be removed, got %d files", len(files))
+	}
+
+	ioutil.WriteFile(path, []byte("{"), 0600)
+	if _, err := NewFileReplayStore(path); err == nil {
+		t.Fatal("Expected corrupt store to fail")
+	}
+}
+
+func TestStreamingClientReplayStore(t *testing.T) {
+	bayeux, forceApi := newFakeBayeux(t)
+
+	store := NewMemoryReplayStore()
+	store.Save("/data/ChangeEvents", 7)
+
+	client := forceApi.NewStreamingClient(WithReplayStore(store))
+	ctx := context.Background()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Subscribe(ctx, "/data/ChangeEvents", ReplayNewEvents); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	bayeux.publish("/data/ChangeEvents", 8, `{"event":{"replayId":REPLAY},"payload":{}}`)
+	if err := receiveEvent(t, client).Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	// Event 9 is received but not committed when the session expires.
+	bayeux.publish("/data/ChangeEvents", 9, `{"event":{"replayId":REPLAY},"payload":{}}`)
+	receiveEvent(t, client)
+	bayeux.expireSession()
+	bayeux.publish("/data/ChangeEvents", 9, `{"event":{"replayId":REPLAY},"payload":{}}`)
+	if event := receiveEvent(t, client); event.ReplayId != 9 {
+		t.Fatalf("Expected uncommitted event to be delivered again, got %+v", event)
+	}
+
+	bayeux.mu.Lock()
+	defer bayeux.mu.Unlock()
+	want := []string{
+		`/data/ChangeEvents {"replay":{"/data/ChangeEvents":7}}`,
+		`/data/ChangeEvents {"replay":{"/data/ChangeEvents":8}}`,
+	}
+	if len(bayeux.subscribes) != 2 || bayeux.subscribes[0] != want[0] || bayeux.subscribes[1] != want[1] {
+		t.Fatalf("Expected subscriptions to resume from the store, got %v", bayeux.subscribes)
+	}
+	if replayId, _, _ := store.Load("/data/ChangeEvents"); replayId != 8 {
+		t.Fatalf("Expected committed replay id 8, got %v", replayId)
+	}
+}
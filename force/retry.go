@@ -0,0 +1,158 @@
+package force
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how ForceApi retries requests that fail with a
+// transient error. Errors reported by Salesforce in the response body (see
+// ErrorCodes) mean the operation was rejected, so they are retried for every
+// method. Retryable status codes and network errors may arrive after the
+// operation was applied, so they are only retried for idempotent methods
+// unless RetryNonIdempotent is set.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff ceiling before the first retry. It doubles on
+	// every following retry up to MaxDelay, and a random delay below the
+	// ceiling is chosen.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// StatusCodes lists the HTTP status codes that are retried.
+	StatusCodes []int
+	// ErrorCodes lists the Salesforce error codes that are retried.
+	ErrorCodes []string
+	// RetryNonIdempotent allows POST requests to be replayed after a
+	// retryable status code or network error, which may duplicate inserts.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy retries the transient failures Salesforce documents for
+// the REST API.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	StatusCodes: []int{
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+	ErrorCodes: []string{
+		"UNABLE_TO_LOCK_ROW",
+		"SERVER_UNAVAILABLE",
+	},
+}
+
+// WithRetryPolicy enables retries of transient failures. Without it only an
+// expired session is retried, once, after reauthenticating.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(forceApi *ForceApi) {
+		forceApi.retryPolicy = &policy
+	}
+}
+
+// shouldRetry reports whether a failed attempt may be tried again. Exactly one
+// of err, for network failures, or statusCode is set.
+func (policy *RetryPolicy) shouldRetry(method string, attempt int, statusCode int, apiErrors ApiErrors, err error) bool {
+	if policy == nil || attempt >= policy.MaxAttempts {
+		return false
+	}
+
+	for _, apiErr := range apiErrors {
+		for _, code := range policy.ErrorCodes {
+			if apiErr.ErrorCode == code {
+				return true
+			}
+		}
+	}
+
+	if !policy.RetryNonIdempotent && !isIdempotent(method) {
+		// A connection that was never established cannot have applied the request.
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+
+	if err != nil {
+		return isTransientNetworkError(err)
+	}
+
+	for _, code := range policy.StatusCodes {
+		if statusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns the delay before the given retry, honouring a Retry-After
+// header sent with the failed response up to MaxDelay.
+func (policy *RetryPolicy) backoff(attempt int, retryAfter string) time.Duration {
+	ceiling := policy.BaseDelay
+	for i := 1; i < attempt && ceiling < policy.MaxDelay; i++ {
+		ceiling *= 2
+	}
+	if ceiling > policy.MaxDelay {
+		ceiling = policy.MaxDelay
+	}
+
+	var delay time.Duration
+	if ceiling > 0 {
+		delay = time.Duration(rand.Int63n(int64(ceiling)))
+	}
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		after := time.Duration(seconds) * time.Second
+		if after > policy.MaxDelay {
+			after = policy.MaxDelay
+		}
+		if after > delay {
+			delay = after
+		}
+	}
+
+	return delay
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "PATCH", "DELETE":
+		return true
+	}
+
+	return false
+}
+
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+package force
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    5 * time.Millisecond,
+	StatusCodes: DefaultRetryPolicy.StatusCodes,
+	ErrorCodes:  DefaultRetryPolicy.ErrorCodes,
+}
+
+// failingHandler fails the first failures requests with status and body, then
+// responds with an empty json object.
+func failingHandler(requests *int32, failures int32, status int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) <= failures {
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+			return
+		}
+		fmt.Fprint(w, `{"id":"001","success":true}`)
+	})
+}
+
+func TestRetryStatusCode(t *testing.T) {
+	var requests int32
+	forceApi := createTestServer(t, failingHandler(&requests, 2, http.StatusServiceUnavailable, "unavailable"), WithRetryPolicy(testRetryPolicy))
+
+	if err := forceApi.Get("/services/data/v36.0/sobjects/Account/001", nil, &SObjectResponse{}); err != nil {
+		t.Fatalf("Expected request to succeed after retries: %v", err)
+	}
+	if requests != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", requests)
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	var requests int32
+	forceApi := createTestServer(t, failingHandler(&requests, 5, http.StatusServiceUnavailable, `[{"message":"down","errorCode":"SERVER_UNAVAILABLE"}]`), WithRetryPolicy(testRetryPolicy))
+
+	err := forceApi.Get("/services/data/v36.0/sobjects/Account/001", nil, &SObjectResponse{})
+	var apiErrors ApiErrors
+	if !errors.As(err, &apiErrors) || apiErrors[0].ErrorCode != "SERVER_UNAVAILABLE" {
+		t.Fatalf("Expected SERVER_UNAVAILABLE after exhausting attempts, got: %v", err)
+	}
+	if requests != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", requests)
+	}
+}
+
+func TestRetryErrorCodeNonIdempotent(t *testing.T) {
+	var requests int32
+	forceApi := createTestServer(t, failingHandler(&requests, 1, http.StatusBadRequest, `[{"message":"locked","errorCode":"UNABLE_TO_LOCK_ROW"}]`), WithRetryPolicy(testRetryPolicy))
+
+	resp := &SObjectResponse{}
+	if err := forceApi.Post("/services/data/v36.0/sobjects/Account", nil, map[string]string{"Name": "Test"}, resp); err != nil {
+		t.Fatalf("Expected insert to be retried after lock error: %v", err)
+	}
+	if requests != 2 || resp.Id != "001" {
+		t.Fatalf("Unexpected retry result: %d attempts, %+v", requests, resp)
+	}
+}
+
+func TestRetrySkipsNonIdempotentStatus(t *testing.T) {
+	var requests int32
+	forceApi := createTestServer(t, failingHandler(&requests, 1, http.StatusServiceUnavailable, "unavailable"), WithRetryPolicy(testRetryPolicy))
+
+	err := forceApi.Post("/services/data/v36.0/sobjects/Account", nil, map[string]string{"Name": "Test"}, &SObjectResponse{})
+	if err == nil {
+		t.Fatal("Expected insert not to be replayed after 503")
+	}
+	if requests != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", requests)
+	}
+
+	policy := testRetryPolicy
+	policy.RetryNonIdempotent = true
+	forceApi.retryPolicy = &policy
+	if err := forceApi.Post("/services/data/v36.0/sobjects/Account", nil, map[string]string{"Name": "Test"}, &SObjectResponse{}); err != nil {
+		t.Fatalf("Expected insert to be replayed when allowed: %v", err)
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	var requests int32
+	forceApi := createTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Drop the connection without responding.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}), WithRetryPolicy(testRetryPolicy))
+
+	if err := forceApi.Delete("/services/data/v36.0/sobjects/Account/001", nil); err != nil {
+		t.Fatalf("Expected request to succeed after connection reset: %v", err)
+	}
+	if requests != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", requests)
+	}
+}
+
+func TestRetryDisabledByDefault(t *testing.T) {
+	var requests int32
+	forceApi := createTestServer(t, failingHandler(&requests, 1, http.StatusServiceUnavailable, "unavailable"))
+
+	if err := forceApi.Get("/services/data/v36.0/sobjects/Account/001", nil, &SObjectResponse{}); err == nil {
+		t.Fatal("Expected 503 to be returned without a retry policy")
+	}
+	if requests != 1 {
+		t.Fatalf("Expected 1 attempt, got %d", requests)
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	var requests int32
+	policy := testRetryPolicy
+	policy.BaseDelay, policy.MaxDelay = time.Minute, time.Minute
+	forceApi := createTestServer(t, failingHandler(&requests, 1, http.StatusServiceUnavailable, "unavailable"), WithRetryPolicy(policy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := forceApi.GetContext(ctx, "/services/data/v36.0/sobjects/Account/001", nil, &SObjectResponse{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected backoff to stop at the deadline, got: %v", err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for i := 0; i < 50; i++ {
+			if delay := policy.backoff(attempt, ""); delay < 0 || delay >= ceiling {
+				t.Fatalf("Backoff for attempt %d out of range: %v", attempt, delay)
+			}
+		}
+	}
+
+	if delay := policy.backoff(1, "120"); delay != time.Second {
+		t.Fatalf("Expected Retry-After to be capped at MaxDelay, got %v", delay)
+	}
+}
@@ -2,6 +2,7 @@ package force
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/url"
 	"reflect"
@@ -21,24 +22,45 @@ type SObject interface {
 // Response received from force.com API after insert of an sobject.
 type SObjectResponse struct {
 	Id      string    `force:"id,omitempty"`
-	Errors  ApiErrors `force:"error,omitempty"` //TODO: Not sure if ApiErrors is the right object
+	Errors  ApiErrors `force:"errors,omitempty"`
 	Success bool      `force:"success,omitempty"`
+	Created bool      `force:"created,omitempty"` // Set by upserts that inserted a record.
 }
 
 func (forceAPI *ForceApi) DescribeSObjects() (map[string]*SObjectMetaData, error) {
-	if err := forceAPI.getApiSObjects(); err != nil {
+	return forceAPI.DescribeSObjectsContext(context.Background())
+}
+
+// DescribeSObjectsContext is like DescribeSObjects but carries ctx through the request.
+func (forceAPI *ForceApi) DescribeSObjectsContext(ctx context.Context) (map[string]*SObjectMetaData, error) {
+	if err := forceAPI.getApiSObjects(ctx); err != nil {
 		return nil, err
 	}
 
-	return forceAPI.apiSObjects, nil
+	forceAPI.mu.RLock()
+	defer forceAPI.mu.RUnlock()
+
+	sObjects := make(map[string]*SObjectMetaData, len(forceAPI.apiSObjects))
+	for name, metaData := range forceAPI.apiSObjects {
+		sObjects[name] = metaData
+	}
+
+	return sObjects, nil
 }
 
 func (forceApi *ForceApi) DescribeSObject(in SObject) (resp *SObjectDescription, err error) {
+	return forceApi.DescribeSObjectContext(context.Background(), in)
+}
+
+// DescribeSObjectContext is like DescribeSObject but carries ctx through the request.
+func (forceApi *ForceApi) DescribeSObjectContext(ctx context.Context, in SObject) (resp *SObjectDescription, err error) {
 	// Check cache
+	forceApi.mu.RLock()
 	resp, ok := forceApi.apiSObjectDescriptions[in.ApiName()]
+	forceApi.mu.RUnlock()
 	if !ok {
 		// Attempt retrieval from api
-		sObjectMetaData, ok := forceApi.apiSObjects[in.ApiName()]
+		sObjectMetaData, ok := forceApi.sObject(in.ApiName())
 		if !ok {
 			return nil, fmt.Errorf("Unable to find metadata for object: %v", in.ApiName())
 		}
@@ -46,7 +68,7 @@ func (forceApi *ForceApi) DescribeSObject(in SObject) (resp *SObjectDescription,
 		uri := sObjectMetaData.URLs[sObjectDescribeKey]
 
 		resp = &SObjectDescription{}
-		err = forceApi.Get(uri, nil, resp)
+		err = forceApi.GetContext(ctx, uri, nil, resp)
 		if err != nil {
 			return nil, err
 		}
@@ -69,18 +91,30 @@ func (forceApi *ForceApi) DescribeSObject(in SObject) (resp *SObjectDescription,
 			resp.AllFields = allFields.String()
 		}
 
-		forceApi.apiSObjectDescriptions[in.ApiName()] = resp
+		// Keep the description cached by a concurrent describe, if any.
+		forceApi.mu.Lock()
+		if cached, ok := forceApi.apiSObjectDescriptions[in.ApiName()]; ok {
+			resp = cached
+		} else {
+			forceApi.apiSObjectDescriptions[in.ApiName()] = resp
+		}
+		forceApi.mu.Unlock()
 	}
 
 	return resp, nil
 }
 
 func (forceApi *ForceApi) GetSObject(id string, fields []string, out SObject) (err error) {
-	uri := strings.Replace(forceApi.apiSObjects[out.ApiName()].URLs[rowTemplateKey], idKey, id, 1)
+	return forceApi.GetSObjectContext(context.Background(), id, fields, out)
+}
+
+// GetSObjectContext is like GetSObject but carries ctx through the request.
+func (forceApi *ForceApi) GetSObjectContext(ctx context.Context, id string, fields []string, out SObject) (err error) {
+	uri := strings.Replace(forceApi.sObjectURL(out.ApiName(), rowTemplateKey), idKey, id, 1)
 
 	params := url.Values{}
 	if len(fields) > 0 {
-		attributes, err := forceApi.GetAttributes(out, nil, false, true)
+		attributes, err := forceApi.GetAttributesContext(ctx, out, nil, false, true)
 		if err != nil {
 			return err
 		}
@@ -98,18 +132,23 @@ func (forceApi *ForceApi) GetSObject(id string, fields []string, out SObject) (e
 		params.Add("fields", strings.Join(fields, ","))
 	}
 
-	return forceApi.Get(uri, params, out.(interface{}))
+	return forceApi.GetContext(ctx, uri, params, out.(interface{}))
 }
 
-func (forceApi *ForceApi) InsertSObject(in SObject, externalObj interface{}) (resp *SObjectResponse, err error) {
-	uri := forceApi.apiSObjects[in.ApiName()].URLs[sObjectKey]
+func (forceApi *ForceApi) InsertSObject(in SObject, externalObj interface{}, opts ...RequestOption) (resp *SObjectResponse, err error) {
+	return forceApi.InsertSObjectContext(context.Background(), in, externalObj, opts...)
+}
+
+// InsertSObjectContext is like InsertSObject but carries ctx through the request.
+func (forceApi *ForceApi) InsertSObjectContext(ctx context.Context, in SObject, externalObj interface{}, opts ...RequestOption) (resp *SObjectResponse, err error) {
+	uri := forceApi.sObjectURL(in.ApiName(), sObjectKey)
 	resp = &SObjectResponse{}
 
-	attributes, err := forceApi.GetAttributes(in, externalObj, true, false)
+	attributes, err := forceApi.GetAttributesContext(ctx, in, externalObj, true, false)
 	if err != nil {
 		return nil, err
 	}
-	err = forceApi.Post(uri, nil, attributes, resp)
+	err = forceApi.PostContext(withRequestOptions(ctx, opts), uri, nil, attributes, resp)
 	if err != nil {
 		return nil, err
 	}
@@ -117,18 +156,28 @@ func (forceApi *ForceApi) InsertSObject(in SObject, externalObj interface{}) (re
 	return resp, err
 }
 
-func (forceApi *ForceApi) UpdateSObject(id string, in SObject, externalObj interface{}) (err error) {
-	uri := strings.Replace(forceApi.apiSObjects[in.ApiName()].URLs[rowTemplateKey], idKey, id, 1)
+func (forceApi *ForceApi) UpdateSObject(id string, in SObject, externalObj interface{}, opts ...RequestOption) (err error) {
+	return forceApi.UpdateSObjectContext(context.Background(), id, in, externalObj, opts...)
+}
+
+// UpdateSObjectContext is like UpdateSObject but carries ctx through the request.
+func (forceApi *ForceApi) UpdateSObjectContext(ctx context.Context, id string, in SObject, externalObj interface{}, opts ...RequestOption) (err error) {
+	uri := strings.Replace(forceApi.sObjectURL(in.ApiName(), rowTemplateKey), idKey, id, 1)
 
-	attributes, err := forceApi.GetAttributes(in, externalObj, false, false)
+	attributes, err := forceApi.GetAttributesContext(ctx, in, externalObj, false, false)
 	if err != nil {
 		return err
 	}
 
-	return forceApi.Patch(uri, nil, attributes, nil)
+	return forceApi.PatchContext(withRequestOptions(ctx, opts), uri, nil, attributes, nil)
 }
 
+// Debug prints the url and body of every request to stdout while enabled.
+// DebugMiddleware provides the same output as a Middleware.
 func (forceApi *ForceApi) Debug(enable bool) {
+	forceApi.mu.Lock()
+	defer forceApi.mu.Unlock()
+
 	forceApi.debugMode = enable
 }
 
@@ -138,6 +187,12 @@ type attribute struct {
 }
 
 func (forceApi *ForceApi) GetAttributes(in SObject, externalObj interface{}, isInsert bool, isGet bool) (map[string]interface{}, error) {
+	return forceApi.GetAttributesContext(context.Background(), in, externalObj, isInsert, isGet)
+}
+
+// GetAttributesContext is like GetAttributes but carries ctx through the
+// describe request made when the sobject description is not yet cached.
+func (forceApi *ForceApi) GetAttributesContext(ctx context.Context, in SObject, externalObj interface{}, isInsert bool, isGet bool) (map[string]interface{}, error) {
 	fieldsByTag := map[string]attribute{}
 
 	ref := reflect.ValueOf(in)
@@ -189,7 +244,7 @@ func (forceApi *ForceApi) GetAttributes(in SObject, externalObj interface{}, isI
 		}
 	}
 
-	objectDescription, err := forceApi.DescribeSObject(in)
+	objectDescription, err := forceApi.DescribeSObjectContext(ctx, in)
 	if err != nil {
 		return nil, err
 	}
@@ -203,6 +258,13 @@ func (forceApi *ForceApi) GetAttributes(in SObject, externalObj interface{}, isI
 		}
 
 		attribute, ok := fieldsByTag[fieldName]
+		if isRelationship && (!ok || attribute.Value == nil) {
+			// Lookups can also be set through their id field, such as AccountId.
+			if idAttribute, idOk := fieldsByTag[field.Name]; idOk {
+				attribute, ok = idAttribute, true
+				fieldName, isRelationship = field.Name, false
+			}
+		}
 		if ok {
 			val := attribute.Value
 			if isGet {
@@ -340,13 +402,23 @@ func setValue(fieldValue reflect.Value, fieldValueExternal reflect.Value) {
 }
 
 func (forceApi *ForceApi) DeleteSObject(id string, in SObject) (err error) {
-	uri := strings.Replace(forceApi.apiSObjects[in.ApiName()].URLs[rowTemplateKey], idKey, id, 1)
+	return forceApi.DeleteSObjectContext(context.Background(), id, in)
+}
+
+// DeleteSObjectContext is like DeleteSObject but carries ctx through the request.
+func (forceApi *ForceApi) DeleteSObjectContext(ctx context.Context, id string, in SObject) (err error) {
+	uri := strings.Replace(forceApi.sObjectURL(in.ApiName(), rowTemplateKey), idKey, id, 1)
 
-	return forceApi.Delete(uri, nil)
+	return forceApi.DeleteContext(ctx, uri, nil)
 }
 
 func (forceApi *ForceApi) GetSObjectByExternalId(id string, fields []string, out SObject) (err error) {
-	uri := fmt.Sprintf("%v/%v/%v", forceApi.apiSObjects[out.ApiName()].URLs[sObjectKey],
+	return forceApi.GetSObjectByExternalIdContext(context.Background(), id, fields, out)
+}
+
+// GetSObjectByExternalIdContext is like GetSObjectByExternalId but carries ctx through the request.
+func (forceApi *ForceApi) GetSObjectByExternalIdContext(ctx context.Context, id string, fields []string, out SObject) (err error) {
+	uri := fmt.Sprintf("%v/%v/%v", forceApi.sObjectURL(out.ApiName(), sObjectKey),
 		out.ExternalIdApiName(), id)
 
 	params := url.Values{}
@@ -354,23 +426,28 @@ func (forceApi *ForceApi) GetSObjectByExternalId(id string, fields []string, out
 		params.Add("fields", strings.Join(fields, ","))
 	}
 
-	return forceApi.Get(uri, params, out.(interface{}))
+	return forceApi.GetContext(ctx, uri, params, out.(interface{}))
+}
+
+func (forceApi *ForceApi) UpsertSObjectByExternalId(id string, in SObject, externalObj interface{}, opts ...RequestOption) (resp *SObjectResponse, err error) {
+	return forceApi.UpsertSObjectByExternalIdContext(context.Background(), id, in, externalObj, opts...)
 }
 
-func (forceApi *ForceApi) UpsertSObjectByExternalId(id string, in SObject, externalObj interface{}) (resp *SObjectResponse, err error) {
-	uri := fmt.Sprintf("%v/%v/%v", forceApi.apiSObjects[in.ApiName()].URLs[sObjectKey],
+// UpsertSObjectByExternalIdContext is like UpsertSObjectByExternalId but carries ctx through the request.
+func (forceApi *ForceApi) UpsertSObjectByExternalIdContext(ctx context.Context, id string, in SObject, externalObj interface{}, opts ...RequestOption) (resp *SObjectResponse, err error) {
+	uri := fmt.Sprintf("%v/%v/%v", forceApi.sObjectURL(in.ApiName(), sObjectKey),
 		in.ExternalIdApiName(), id)
 
 	resp = &SObjectResponse{}
 
-	attributes, err := forceApi.GetAttributes(in, externalObj, false, false)
+	attributes, err := forceApi.GetAttributesContext(ctx, in, externalObj, false, false)
 	if err != nil {
 		return nil, err
 	}
 
 	delete(attributes, in.ExternalIdApiName())
 
-	err = forceApi.Patch(uri, nil, attributes, resp)
+	err = forceApi.PatchContext(withRequestOptions(ctx, opts), uri, nil, attributes, resp)
 	if err != nil {
 		return nil, err
 	}
@@ -379,8 +456,13 @@ func (forceApi *ForceApi) UpsertSObjectByExternalId(id string, in SObject, exter
 }
 
 func (forceApi *ForceApi) DeleteSObjectByExternalId(id string, in SObject) (err error) {
-	uri := fmt.Sprintf("%v/%v/%v", forceApi.apiSObjects[in.ApiName()].URLs[sObjectKey],
+	return forceApi.DeleteSObjectByExternalIdContext(context.Background(), id, in)
+}
+
+// DeleteSObjectByExternalIdContext is like DeleteSObjectByExternalId but carries ctx through the request.
+func (forceApi *ForceApi) DeleteSObjectByExternalIdContext(ctx context.Context, id string, in SObject) (err error) {
+	uri := fmt.Sprintf("%v/%v/%v", forceApi.sObjectURL(in.ApiName(), sObjectKey),
 		in.ExternalIdApiName(), id)
 
-	return forceApi.Delete(uri, nil)
+	return forceApi.DeleteContext(ctx, uri, nil)
 }
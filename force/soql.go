@@ -0,0 +1,630 @@
+package force
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+const soqlDateTimeFormat = "2006-01-02T15:04:05Z"
+
+// QueryBuilder builds a SOQL query. Values passed to conditions are escaped
+// and formatted as SOQL literals; field and object names are checked to be
+// plain identifiers. Select lists are written as given and must not contain
+// untrusted input.
+//
+//	query, err := force.Select("Id", "Name").
+//		From("Account").
+//		Where(force.Or(force.Eq("Name", name), force.Like("Website", "%"+force.EscapeLike(domain)))).
+//		OrderBy("CreatedDate", force.Desc).
+//		Limit(10).
+//		Build()
+type QueryBuilder struct {
+	fields     []string
+	subqueries []*QueryBuilder
+	from       string
+	where      Condition
+	groupBy    []string
+	having     Condition
+	orderBy    []string
+	limit      int
+	offset     int
+	err        error
+}
+
+// Order is the sort order of an ORDER BY field.
+type Order string
+
+const (
+	Asc            Order = "ASC"
+	Desc           Order = "DESC"
+	AscNullsFirst  Order = "ASC NULLS FIRST"
+	AscNullsLast   Order = "ASC NULLS LAST"
+	DescNullsFirst Order = "DESC NULLS FIRST"
+	DescNullsLast  Order = "DESC NULLS LAST"
+)
+
+// DateLiteral is written to the query unquoted, for SOQL date literals such
+// as TODAY or LAST_N_DAYS:30 and for date-only values.
+type DateLiteral string
+
+const (
+	Yesterday   DateLiteral = "YESTERDAY"
+	Today       DateLiteral = "TODAY"
+	Tomorrow    DateLiteral = "TOMORROW"
+	LastWeek    DateLiteral = "LAST_WEEK"
+	ThisWeek    DateLiteral = "THIS_WEEK"
+	NextWeek    DateLiteral = "NEXT_WEEK"
+	LastMonth   DateLiteral = "LAST_MONTH"
+	ThisMonth   DateLiteral = "THIS_MONTH"
+	NextMonth   DateLiteral = "NEXT_MONTH"
+	LastQuarter DateLiteral = "LAST_QUARTER"
+	ThisQuarter DateLiteral = "THIS_QUARTER"
+	NextQuarter DateLiteral = "NEXT_QUARTER"
+	LastYear    DateLiteral = "LAST_YEAR"
+	ThisYear    DateLiteral = "THIS_YEAR"
+	NextYear    DateLiteral = "NEXT_YEAR"
+	Last90Days  DateLiteral = "LAST_90_DAYS"
+	Next90Days  DateLiteral = "NEXT_90_DAYS"
+)
+
+// LastNDays returns the LAST_N_DAYS:n date literal.
+func LastNDays(n int) DateLiteral {
+	return DateLiteral(fmt.Sprintf("LAST_N_DAYS:%d", n))
+}
+
+// NextNDays returns the NEXT_N_DAYS:n date literal.
+func NextNDays(n int) DateLiteral {
+	return DateLiteral(fmt.Sprintf("NEXT_N_DAYS:%d", n))
+}
+
+// DateOf formats t as a SOQL date value, for comparisons with date fields.
+func DateOf(t time.Time) DateLiteral {
+	return DateLiteral(t.Format("2006-01-02"))
+}
+
+// Select starts a query selecting fields.
+func Select(fields ...string) *QueryBuilder {
+	return (&QueryBuilder{}).Select(fields...)
+}
+
+// SelectStruct starts a query selecting the fields of v, which must be a
+// struct or a pointer to one, using the same naming rules as the force struct
+// tags used to decode the results. Embedded structs are flattened, nested
+// structs other than sobjects.Time are selected as relationship fields and
+// slices, which hold child relationship results, are skipped. If v
+// implements SObject the query selects from its ApiName.
+func SelectStruct(v interface{}) *QueryBuilder {
+	query := &QueryBuilder{}
+
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		query.err = fmt.Errorf("SelectStruct requires a struct, got %T", v)
+		return query
+	}
+
+	query.fields = appendStructFields(query.fields, t, "", 0)
+	if in, ok := v.(SObject); ok {
+		query.from = in.ApiName()
+	}
+
+	return query
+}
+
+var sobjectsTimeType = reflect.TypeOf(sobjects.Time{})
+
+// maxRelationshipDepth is the number of relationship levels SOQL allows
+// between a child and its parents.
+const maxRelationshipDepth = 5
+
+func appendStructFields(fields []string, t reflect.Type, prefix string, depth int) []string {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, tagged := forceFieldName(field)
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if field.Anonymous && !tagged && fieldType.Kind() == reflect.Struct {
+			fields = appendStructFields(fields, fieldType, prefix, depth)
+			continue
+		}
+
+		switch {
+		case name == "attributes":
+			continue
+		case fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() != reflect.Uint8:
+			continue
+		case fieldType.Kind() == reflect.Struct && isQueryResult(fieldType):
+			continue
+		case fieldType.Kind() == reflect.Struct && fieldType != sobjectsTimeType:
+			if depth < maxRelationshipDepth {
+				fields = appendStructFields(fields, fieldType, prefix+name+".", depth+1)
+			}
+			continue
+		}
+
+		fields = appendField(fields, prefix+name)
+	}
+
+	return fields
+}
+
+// isQueryResult reports whether t holds child relationship query results.
+func isQueryResult(t reflect.Type) bool {
+	field, ok := t.FieldByName("Records")
+	return ok && field.Type.Kind() == reflect.Slice
+}
+
+// forceFieldName returns the name a field is encoded under by forcejson, and
+// whether the name came from its force tag.
+func forceFieldName(field reflect.StructField) (string, bool) {
+	name := strings.Split(field.Tag.Get("force"), ",")[0]
+	if name == "" {
+		return field.Name, false
+	}
+
+	return name, true
+}
+
+func appendField(fields []string, name string) []string {
+	for _, field := range fields {
+		if strings.EqualFold(field, name) {
+			return fields
+		}
+	}
+
+	return append(fields, name)
+}
+
+// Select adds fields to the SELECT list.
+func (query *QueryBuilder) Select(fields ...string) *QueryBuilder {
+	for _, field := range fields {
+		query.fields = appendField(query.fields, field)
+	}
+
+	return query
+}
+
+// SelectSubquery adds a child relationship subquery to the SELECT list. The
+// subquery selects from the relationship name, e.g. Select("Id").From("Contacts").
+func (query *QueryBuilder) SelectSubquery(subquery *QueryBuilder) *QueryBuilder {
+	query.subqueries = append(query.subqueries, subquery)
+
+	return query
+}
+
+// From sets the object, or for subqueries the relationship, to select from.
+func (query *QueryBuilder) From(object string) *QueryBuilder {
+	query.from = object
+
+	return query
+}
+
+// Where sets the WHERE clause. Multiple conditions are joined with AND.
+func (query *QueryBuilder) Where(conditions ...Condition) *QueryBuilder {
+	query.where = joinConditions(query.where, conditions)
+
+	return query
+}
+
+// GroupBy adds fields to the GROUP BY clause.
+func (query *QueryBuilder) GroupBy(fields ...string) *QueryBuilder {
+	query.groupBy = append(query.groupBy, fields...)
+
+	return query
+}
+
+// Having sets the HAVING clause. Multiple conditions are joined with AND.
+func (query *QueryBuilder) Having(conditions ...Condition) *QueryBuilder {
+	query.having = joinConditions(query.having, conditions)
+
+	return query
+}
+
+// OrderBy adds a field to the ORDER BY clause.
+func (query *QueryBuilder) OrderBy(field string, order Order) *QueryBuilder {
+	if !isIdentifier(field) {
+		query.setErr(fmt.Errorf("Invalid ORDER BY field: %q", field))
+	}
+	query.orderBy = append(query.orderBy, fmt.Sprintf("%v %v", field, order))
+
+	return query
+}
+
+// Limit sets the maximum number of rows returned.
+func (query *QueryBuilder) Limit(limit int) *QueryBuilder {
+	query.limit = limit
+
+	return query
+}
+
+// Offset sets the number of rows skipped.
+func (query *QueryBuilder) Offset(offset int) *QueryBuilder {
+	query.offset = offset
+
+	return query
+}
+
+// Build returns the SOQL query, or the first error encountered while building it.
+func (query *QueryBuilder) Build() (string, error) {
+	if query.err != nil {
+		return "", query.err
+	}
+	if len(query.fields) == 0 && len(query.subqueries) == 0 {
+		return "", fmt.Errorf("Query selects no fields")
+	}
+	if !isIdentifier(query.from) {
+		return "", fmt.Errorf("Invalid FROM object: %q", query.from)
+	}
+
+	selectList := append([]string{}, query.fields...)
+	for _, subquery := range query.subqueries {
+		soql, err := subquery.Build()
+		if err != nil {
+			return "", err
+		}
+		selectList = append(selectList, "("+soql+")")
+	}
+
+	var soql strings.Builder
+	fmt.Fprintf(&soql, BaseQueryString, strings.Join(selectList, ", "), query.from)
+
+	if query.where != nil {
+		where, err := query.where.soql()
+		if err != nil {
+			return "", err
+		}
+		soql.WriteString(" WHERE " + where)
+	}
+
+	if len(query.groupBy) > 0 {
+		for _, field := range query.groupBy {
+			if !isIdentifier(field) {
+				return "", fmt.Errorf("Invalid GROUP BY field: %q", field)
+			}
+		}
+		soql.WriteString(" GROUP BY " + strings.Join(query.groupBy, ", "))
+	}
+
+	if query.having != nil {
+		having, err := query.having.soql()
+		if err != nil {
+			return "", err
+		}
+		soql.WriteString(" HAVING " + having)
+	}
+
+	if len(query.orderBy) > 0 {
+		soql.WriteString(" ORDER BY " + strings.Join(query.orderBy, ", "))
+	}
+	if query.limit > 0 {
+		fmt.Fprintf(&soql, " LIMIT %d", query.limit)
+	}
+	if query.offset > 0 {
+		fmt.Fprintf(&soql, " OFFSET %d", query.offset)
+	}
+
+	return soql.String(), nil
+}
+
+func (query *QueryBuilder) setErr(err error) {
+	if query.err == nil {
+		query.err = err
+	}
+}
+
+// Condition is a boolean expression used in WHERE and HAVING clauses.
+type Condition interface {
+	soql() (string, error)
+}
+
+type comparison struct {
+	field    string
+	operator string
+	value    interface{}
+}
+
+func (c comparison) soql() (string, error) {
+	if !isIdentifier(c.field) {
+		return "", fmt.Errorf("Invalid field in condition: %q", c.field)
+	}
+
+	value, err := formatSOQLValue(c.value)
+	if err != nil {
+		return "", fmt.Errorf("Invalid value for %v: %v", c.field, err)
+	}
+
+	return fmt.Sprintf("%v %v %v", c.field, c.operator, value), nil
+}
+
+// Eq matches records where field equals value. A nil value matches null.
+func Eq(field string, value interface{}) Condition {
+	return comparison{field, "=", value}
+}
+
+// Ne matches records where field does not equal value.
+func Ne(field string, value interface{}) Condition {
+	return comparison{field, "!=", value}
+}
+
+// Lt matches records where field is less than value.
+func Lt(field string, value interface{}) Condition {
+	return comparison{field, "<", value}
+}
+
+// Lte matches records where field is less than or equal to value.
+func Lte(field string, value interface{}) Condition {
+	return comparison{field, "<=", value}
+}
+
+// Gt matches records where field is greater than value.
+func Gt(field string, value interface{}) Condition {
+	return comparison{field, ">", value}
+}
+
+// Gte matches records where field is greater than or equal to value.
+func Gte(field string, value interface{}) Condition {
+	return comparison{field, ">=", value}
+}
+
+// Like matches records where field matches pattern, in which % and _ are
+// wildcards. Use EscapeLike for the parts of pattern that must match literally.
+func Like(field, pattern string) Condition {
+	return comparison{field, "LIKE", likePattern(pattern)}
+}
+
+// In matches records where field equals one of values, which is either a
+// slice or a *QueryBuilder selecting a single Id field for a semi-join.
+func In(field string, values interface{}) Condition {
+	return comparison{field, "IN", soqlSet{values}}
+}
+
+// NotIn matches records where field equals none of values. See In.
+func NotIn(field string, values interface{}) Condition {
+	return comparison{field, "NOT IN", soqlSet{values}}
+}
+
+type soqlSet struct {
+	values interface{}
+}
+
+type likePattern string
+
+type junction struct {
+	operator   string
+	conditions []Condition
+}
+
+func (j junction) soql() (string, error) {
+	if len(j.conditions) == 0 {
+		return "", fmt.Errorf("%v requires at least one condition", j.operator)
+	}
+
+	parts := make([]string, len(j.conditions))
+	for i, condition := range j.conditions {
+		part, err := condition.soql()
+		if err != nil {
+			return "", err
+		}
+		switch nested := condition.(type) {
+		case junction:
+			if nested.operator != j.operator && len(j.conditions) > 1 {
+				part = "(" + part + ")"
+			}
+		case rawCondition:
+			// The expression may hold operators of its own.
+			if len(j.conditions) > 1 {
+				part = "(" + part + ")"
+			}
+		}
+		parts[i] = part
+	}
+
+	return strings.Join(parts, " "+j.operator+" "), nil
+}
+
+// And matches records matching all conditions.
+func And(conditions ...Condition) Condition {
+	return junction{"AND", conditions}
+}
+
+// Or matches records matching any of conditions.
+func Or(conditions ...Condition) Condition {
+	return junction{"OR", conditions}
+}
+
+type negation struct {
+	condition Condition
+}
+
+func (n negation) soql() (string, error) {
+	inner, err := n.condition.soql()
+	if err != nil {
+		return "", err
+	}
+
+	return "NOT (" + inner + ")", nil
+}
+
+// Not matches records not matching condition.
+func Not(condition Condition) Condition {
+	return negation{condition}
+}
+
+type rawCondition string
+
+func (r rawCondition) soql() (string, error) {
+	return string(r), nil
+}
+
+// Raw returns a condition written to the query unchanged, for expressions the
+// builder cannot represent. It is parenthesized when joined with other
+// conditions. It must not contain untrusted input.
+func Raw(expression string) Condition {
+	return rawCondition(expression)
+}
+
+func joinConditions(existing Condition, conditions []Condition) Condition {
+	if len(conditions) == 0 {
+		return existing
+	}
+	if existing != nil {
+		conditions = append([]Condition{existing}, conditions...)
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+
+	return And(conditions...)
+}
+
+var soqlEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+	"\b", `\b`,
+	"\f", `\f`,
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes the LIKE wildcards % and _, and backslashes, in s.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func quoteSOQLString(s string) string {
+	return "'" + soqlEscaper.Replace(s) + "'"
+}
+
+// quoteLikePattern quotes pattern as a SOQL string literal. The escape
+// sequences written by EscapeLike are kept as they are and every other
+// character is escaped, so a backslash that does not start one matches a
+// literal backslash.
+func quoteLikePattern(pattern string) string {
+	var b strings.Builder
+	b.WriteString("'")
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) && strings.IndexByte(`\%_`, pattern[i+1]) >= 0 {
+			b.WriteString(pattern[i : i+2])
+			i++
+			continue
+		}
+		b.WriteString(soqlEscaper.Replace(pattern[i : i+1]))
+	}
+	b.WriteString("'")
+
+	return b.String()
+}
+
+// formatSOQLValue formats v as a SOQL literal.
+func formatSOQLValue(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return quoteSOQLString(value), nil
+	case DateLiteral:
+		return string(value), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case time.Time:
+		return value.UTC().Format(soqlDateTimeFormat), nil
+	case sobjects.Time:
+		return value.Time().UTC().Format(soqlDateTimeFormat), nil
+	case *sobjects.Time:
+		if value == nil {
+			return "null", nil
+		}
+		return value.Time().UTC().Format(soqlDateTimeFormat), nil
+	case likePattern:
+		return quoteLikePattern(string(value)), nil
+	case soqlSet:
+		return formatSOQLSet(value.values)
+	case *QueryBuilder:
+		return "", fmt.Errorf("subqueries are only supported by In and NotIn")
+	}
+
+	ref := reflect.ValueOf(v)
+	switch ref.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(ref.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(ref.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(ref.Float(), 'f', -1, 64), nil
+	case reflect.String:
+		return quoteSOQLString(ref.String()), nil
+	case reflect.Pointer:
+		if ref.IsNil() {
+			return "null", nil
+		}
+		return formatSOQLValue(ref.Elem().Interface())
+	}
+
+	return "", fmt.Errorf("unsupported type %T", v)
+}
+
+func formatSOQLSet(values interface{}) (string, error) {
+	if subquery, ok := values.(*QueryBuilder); ok {
+		soql, err := subquery.Build()
+		if err != nil {
+			return "", err
+		}
+		return "(" + soql + ")", nil
+	}
+
+	ref := reflect.ValueOf(values)
+	if ref.Kind() != reflect.Slice && ref.Kind() != reflect.Array {
+		return "", fmt.Errorf("expected a slice or subquery, got %T", values)
+	}
+	if ref.Len() == 0 {
+		return "", fmt.Errorf("empty set")
+	}
+
+	formatted := make([]string, ref.Len())
+	for i := range formatted {
+		value, err := formatSOQLValue(ref.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		formatted[i] = value
+	}
+
+	return "(" + strings.Join(formatted, ", ") + ")", nil
+}
+
+// isIdentifier reports whether s is a field path or function call made of
+// letters, digits, underscores, dots and parentheses.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_' || r == '.' || r == '(' || r == ')':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
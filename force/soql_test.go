@@ -0,0 +1,164 @@
+package force
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type soqlContact struct {
+	sobjects.BaseSObject
+	Email   string            `force:",omitempty"`
+	Account *sobjects.Account `force:"Account,omitempty"`
+	Tags    []string          `force:"-"`
+	Cases   *struct {
+		sobjects.BaseQuery
+		Records []sobjects.BaseSObject `force:"records"`
+	} `force:"Cases,omitempty"`
+	Birthdate *sobjects.Time `force:",omitempty"`
+}
+
+func (c *soqlContact) ApiName() string {
+	return "Contact"
+}
+
+func TestQueryBuilder(t *testing.T) {
+	created := time.Date(2020, 3, 4, 5, 6, 7, 0, time.FixedZone("PST", -8*3600))
+
+	tests := []struct {
+		query    *QueryBuilder
+		expected string
+	}{
+		{
+			Select("Id", "Name").From("Account"),
+			"SELECT Id, Name FROM Account",
+		},
+		{
+			Select("Id").From("Account").
+				Where(Eq("Name", `O'Brien "Inc" \ Co`+"\n"), Ne("Industry", nil)).
+				Where(Or(Gt("AnnualRevenue", 1.5e6), Lte("NumberOfEmployees", 10))).
+				OrderBy("CreatedDate", DescNullsLast).
+				OrderBy("Name", Asc).
+				Limit(10).
+				Offset(20),
+			`SELECT Id FROM Account WHERE Name = 'O\'Brien \"Inc\" \\ Co\n' AND Industry != null AND (AnnualRevenue > 1500000 OR NumberOfEmployees <= 10) ORDER BY CreatedDate DESC NULLS LAST, Name ASC LIMIT 10 OFFSET 20`,
+		},
+		{
+			Select("Id").From("Contact").Where(
+				Gte("CreatedDate", created),
+				Lt("LastModifiedDate", sobjects.AsTime(created)),
+				Eq("Birthdate", DateOf(created)),
+				Eq("LastActivityDate", LastNDays(30)),
+				Eq("IsDeleted", false),
+			),
+			"SELECT Id FROM Contact WHERE CreatedDate >= 2020-03-04T13:06:07Z AND LastModifiedDate < 2020-03-04T13:06:07Z AND Birthdate = 2020-03-04 AND LastActivityDate = LAST_N_DAYS:30 AND IsDeleted = false",
+		},
+		{
+			Select("Id").From("Account").Where(
+				Like("Name", "%"+EscapeLike("50%_off")+"%"),
+				In("Type", []string{"Customer", "Partner's"}),
+				NotIn("Id", Select("AccountId").From("Opportunity").Where(Eq("IsWon", true))),
+				Not(Eq("BillingState", "CA")),
+			),
+			`SELECT Id FROM Account WHERE Name LIKE '%50\%\_off%' AND Type IN ('Customer', 'Partner\'s') AND Id NOT IN (SELECT AccountId FROM Opportunity WHERE IsWon = true) AND NOT (BillingState = 'CA')`,
+		},
+		{
+			Select("Id", "Name").From("Account").
+				SelectSubquery(Select("Id", "Email").From("Contacts").Where(Ne("Email", nil)).Limit(5)),
+			"SELECT Id, Name, (SELECT Id, Email FROM Contacts WHERE Email != null LIMIT 5) FROM Account",
+		},
+		{
+			Select("Industry", "COUNT(Id)").From("Account").
+				GroupBy("Industry").
+				Having(Gt("COUNT(Id)", 5)).
+				Where(Raw("CALENDAR_YEAR(CreatedDate) = 2020")),
+			"SELECT Industry, COUNT(Id) FROM Account WHERE CALENDAR_YEAR(CreatedDate) = 2020 GROUP BY Industry HAVING COUNT(Id) > 5",
+		},
+		{
+			Select("Id").From("Account").
+				Where(Or(Raw("Type = 'Customer' AND Rating = 'Hot'"), Eq("Industry", "Retail"))).
+				Where(Raw("AnnualRevenue > 0 OR NumberOfEmployees > 0")),
+			"SELECT Id FROM Account WHERE ((Type = 'Customer' AND Rating = 'Hot') OR Industry = 'Retail') AND (AnnualRevenue > 0 OR NumberOfEmployees > 0)",
+		},
+	}
+
+	for _, test := range tests {
+		soql, err := test.query.Build()
+		if err != nil {
+			t.Fatalf("Failed to build %q: %v", test.expected, err)
+		}
+		if soql != test.expected {
+			t.Fatalf("Unexpected query:\n got: %v\nwant: %v", soql, test.expected)
+		}
+	}
+}
+
+func TestLike(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected string
+	}{
+		{"%" + EscapeLike("50%_off") + "%", `'%50\%\_off%'`},
+		{EscapeLike(`x\`) + "%", `'x\\%'`},
+		{EscapeLike(`50\%`) + "%", `'50\\\%%'`},
+		{EscapeLike(`a\_b`), `'a\\\_b'`},
+		{`O'Brien%`, `'O\'Brien%'`},
+		{`C:\dir%`, `'C:\\dir%'`},
+		{`trailing\`, `'trailing\\'`},
+	}
+
+	for _, test := range tests {
+		soql, err := Select("Id").From("Account").Where(Like("Name", test.pattern)).Build()
+		if err != nil {
+			t.Fatalf("Failed to build %q: %v", test.pattern, err)
+		}
+		if expected := "SELECT Id FROM Account WHERE Name LIKE " + test.expected; soql != expected {
+			t.Fatalf("Unexpected query for %q:\n got: %v\nwant: %v", test.pattern, soql, expected)
+		}
+	}
+}
+
+func TestQueryBuilderErrors(t *testing.T) {
+	tests := map[string]*QueryBuilder{
+		"no fields":        Select().From("Account"),
+		"no object":        Select("Id"),
+		"injected object":  Select("Id").From("Account WHERE Name != null"),
+		"injected field":   Select("Id").From("Account").Where(Eq("Name = 'x' OR Name", "y")),
+		"injected order":   Select("Id").From("Account").OrderBy("Name; DROP", Asc),
+		"unsupported type": Select("Id").From("Account").Where(Eq("Name", struct{}{})),
+		"empty set":        Select("Id").From("Account").Where(In("Id", []string{})),
+		"bad subquery":     Select("Id").From("Account").Where(In("Id", Select("Id"))),
+		"not a struct":     SelectStruct("Account"),
+	}
+
+	for name, query := range tests {
+		if soql, err := query.Build(); err == nil {
+			t.Fatalf("Expected %v to fail, got: %v", name, soql)
+		}
+	}
+}
+
+func TestSelectStruct(t *testing.T) {
+	soql, err := SelectStruct(&soqlContact{}).Where(Eq("Email", "a@b.com")).Build()
+	if err != nil {
+		t.Fatalf("Failed to build query: %v", err)
+	}
+
+	fields := "Id, IsDeleted, Name, CreatedDate, CreatedById, LastModifiedDate, LastModifiedById, SystemModstamp, Email, " +
+		"Account.Id, Account.IsDeleted, Account.Name, Account.CreatedDate, Account.CreatedById, Account.LastModifiedDate, Account.LastModifiedById, Account.SystemModstamp, " +
+		"Account.BillingCity, Account.BillingCountry, Account.BillingPostalCode, Account.BillingState, Account.BillingStreet, Birthdate"
+	expected := "SELECT " + fields + " FROM Contact WHERE Email = 'a@b.com'"
+	if soql != expected {
+		t.Fatalf("Unexpected query:\n got: %v\nwant: %v", soql, expected)
+	}
+
+	soql, err = SelectStruct(sobjects.Opportunity{}).From("Opportunity").Build()
+	if err != nil {
+		t.Fatalf("Failed to build query: %v", err)
+	}
+	if strings.Count(soql, "Name") != 2 || !strings.Contains(soql, "StageName") {
+		t.Fatalf("Expected duplicate Name field to be selected once: %v", soql)
+	}
+}
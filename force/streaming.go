@@ -0,0 +1,589 @@
+package force
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimajalali/go-force/forcejson"
+)
+
+// Bayeux meta channels used by the Streaming API.
+const (
+	metaHandshake   = "/meta/handshake"
+	metaConnect     = "/meta/connect"
+	metaSubscribe   = "/meta/subscribe"
+	metaUnsubscribe = "/meta/unsubscribe"
+	metaDisconnect  = "/meta/disconnect"
+)
+
+// Replay ids that Subscribe accepts besides the id of a received event.
+const (
+	// ReplayNewEvents receives only events published after subscribing.
+	ReplayNewEvents int64 = -1
+	// ReplayAllEvents also receives the events retained by Salesforce,
+	// which keeps them for 24 to 72 hours depending on the event type.
+	ReplayAllEvents int64 = -2
+)
+
+// Delay bounds between reconnection attempts after a failed connect request.
+var (
+	streamingRetryDelay    = time.Second
+	streamingMaxRetryDelay = 30 * time.Second
+)
+
+const (
+	// Long poll timeout used until the server advises one.
+	streamingPollTimeout = 110 * time.Second
+	// Extra time allowed for a long poll beyond the timeout advised by the server.
+	streamingPollGrace = 30 * time.Second
+)
+
+var (
+	errStreamingUnauthorized = errors.New("force: streaming session unauthorized")
+	errStreamingHandshake    = errors.New("force: streaming client must handshake again")
+	errStreamingStopped      = errors.New("force: streaming server advised not to reconnect")
+)
+
+// StreamingClient receives PushTopic, generic, platform and change events
+// through the Streaming API, a CometD (Bayeux) long-polling protocol. It uses
+// the session of the ForceApi it was created from and reauthenticates when
+// the session expires.
+//
+//	client := forceApi.NewStreamingClient()
+//	if err := client.Connect(ctx); err != nil {
+//		...
+//	}
+//	defer client.Close()
+//	if err := client.Subscribe(ctx, "/data/ChangeEvents", force.ReplayNewEvents); err != nil {
+//		...
+//	}
+//	for event := range client.Events() {
+//		...
+//	}
+//	if err := client.Err(); err != nil {
+//		...
+//	}
+type StreamingClient struct {
+	forceApi   *ForceApi
+	httpClient *http.Client
+	events     chan *Event
+	done       chan struct{}
+
+	store ReplayStore
+
+	mu       sync.Mutex
+	clientId string
+	advice   bayeuxAdvice
+	replay   map[string]int64
+	cancel   context.CancelFunc
+	closed   bool
+	err      error
+}
+
+// Event is a message received on a subscribed channel.
+type Event struct {
+	Channel  string
+	ReplayId int64
+	// Type and CreatedDate are set for PushTopic events.
+	Type        string
+	CreatedDate string
+	// Schema is the id of the schema of platform and change events.
+	Schema string
+	// Data is the JSON encoded record of PushTopic events or the payload of
+	// platform and change events. Generic events carry a plain string.
+	Data forcejson.RawMessage
+
+	client *StreamingClient
+}
+
+// Decode unmarshals the data of the event into out.
+func (event *Event) Decode(out interface{}) error {
+	if err := forcejson.Unmarshal(event.Data, out); err != nil {
+		return fmt.Errorf("Unable to unmarshal %v event %d: %v", event.Channel, event.ReplayId, err)
+	}
+
+	return nil
+}
+
+type bayeuxMessage struct {
+	Id                       string                 `force:"id,omitempty"`
+	Channel                  string                 `force:"channel"`
+	ClientId                 string                 `force:"clientId,omitempty"`
+	Version                  string                 `force:"version,omitempty"`
+	MinimumVersion           string                 `force:"minimumVersion,omitempty"`
+	SupportedConnectionTypes []string               `force:"supportedConnectionTypes,omitempty"`
+	ConnectionType           string                 `force:"connectionType,omitempty"`
+	Subscription             string                 `force:"subscription,omitempty"`
+	Successful               bool                   `force:"successful,omitempty"`
+	Error                    string                 `force:"error,omitempty"`
+	Advice                   *bayeuxAdvice          `force:"advice,omitempty"`
+	Ext                      map[string]interface{} `force:"ext,omitempty"`
+	Data                     *bayeuxData            `force:"data,omitempty"`
+}
+
+type bayeuxAdvice struct {
+	Reconnect string `force:"reconnect,omitempty"`
+	Interval  int64  `force:"interval,omitempty"`
+	Timeout   int64  `force:"timeout,omitempty"`
+}
+
+type bayeuxData struct {
+	Event struct {
+		ReplayId    int64  `force:"replayId"`
+		Type        string `force:"type,omitempty"`
+		CreatedDate string `force:"createdDate,omitempty"`
+	} `force:"event"`
+	Schema  string               `force:"schema,omitempty"`
+	Payload forcejson.RawMessage `force:"payload,omitempty"`
+	SObject forcejson.RawMessage `force:"sobject,omitempty"`
+}
+
+// NewStreamingClient returns a client of the Streaming API. Requests go
+// through the http.Client and middleware of forceApi, with a cookie jar added
+// as the protocol requires.
+func (forceApi *ForceApi) NewStreamingClient(opts ...StreamingOption) *StreamingClient {
+	httpClient := *forceApi.client()
+	httpClient.Jar, _ = cookiejar.New(nil)
+	// Long polls are bounded by the timeout advised by the server instead.
+	httpClient.Timeout = 0
+
+	client := &StreamingClient{
+		forceApi:   forceApi,
+		httpClient: &httpClient,
+		events:     make(chan *Event),
+		done:       make(chan struct{}),
+		replay:     map[string]int64{},
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
+}
+
+// Connect performs the handshake and starts receiving events in the
+// background until ctx is done or Close is called.
+func (client *StreamingClient) Connect(ctx context.Context) error {
+	client.mu.Lock()
+	if client.cancel != nil {
+		client.mu.Unlock()
+		return fmt.Errorf("Error connecting streaming client: already connected")
+	}
+	ctx, client.cancel = context.WithCancel(ctx)
+	client.mu.Unlock()
+
+	if err := client.handshake(ctx); err != nil {
+		client.cancel()
+		close(client.events)
+		close(client.done)
+		return err
+	}
+
+	go client.run(ctx)
+
+	return nil
+}
+
+// Subscribe starts delivering the events of channel, such as
+// "/topic/AccountUpdates", "/event/Order__e" or "/data/ChangeEvents", to
+// Events. Delivery starts after the event with the given replay id, or as
+// given by ReplayNewEvents or ReplayAllEvents. If the client reconnects, it
+// resubscribes after the last event delivered.
+//
+// With a ReplayStore, a replay id saved for channel takes precedence over
+// replayId, and the client reconnects after the last event committed.
+func (client *StreamingClient) Subscribe(ctx context.Context, channel string, replayId int64) error {
+	resumeId := replayId
+	if client.store != nil {
+		saved, ok, err := client.store.Load(channel)
+		if err != nil {
+			return fmt.Errorf("Error subscribing to %v: %v", channel, err)
+		}
+		if ok {
+			resumeId = saved
+		}
+	}
+
+	msg := client.message(metaSubscribe)
+	msg.Subscription = channel
+	msg.Ext = replayExt(map[string]int64{channel: resumeId})
+
+	if err := client.call(ctx, msg); err != nil {
+		return fmt.Errorf("Error subscribing to %v: %w", channel, err)
+	}
+
+	client.mu.Lock()
+	client.replay[channel] = replayId
+	client.mu.Unlock()
+
+	return nil
+}
+
+// Unsubscribe stops delivering the events of channel.
+func (client *StreamingClient) Unsubscribe(ctx context.Context, channel string) error {
+	msg := client.message(metaUnsubscribe)
+	msg.Subscription = channel
+
+	client.mu.Lock()
+	delete(client.replay, channel)
+	client.mu.Unlock()
+
+	if err := client.call(ctx, msg); err != nil {
+		return fmt.Errorf("Error unsubscribing from %v: %w", channel, err)
+	}
+
+	return nil
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// client stops, after which Err reports why.
+func (client *StreamingClient) Events() <-chan *Event {
+	return client.events
+}
+
+// Err returns the error that stopped the client, if any.
+func (client *StreamingClient) Err() error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return client.err
+}
+
+// Close disconnects the client and waits for it to stop.
+func (client *StreamingClient) Close() error {
+	client.mu.Lock()
+	cancel, closed, clientId := client.cancel, client.closed, client.clientId
+	client.closed = true
+	client.mu.Unlock()
+
+	if cancel == nil || closed {
+		return nil
+	}
+	cancel()
+	<-client.done
+
+	if clientId != "" {
+		// The connection is closed anyway, so a failed disconnect is not reported.
+		ctx, cancelDisconnect := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelDisconnect()
+		client.send(ctx, client.message(metaDisconnect))
+	}
+
+	return nil
+}
+
+// run polls for events until ctx is done or the server tells the client to
+// stop.
+func (client *StreamingClient) run(ctx context.Context) {
+	defer close(client.done)
+	defer close(client.events)
+
+	retryDelay := streamingRetryDelay
+	for {
+		_, accessToken := client.forceApi.oauth.session()
+		err := client.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err == nil:
+			retryDelay = streamingRetryDelay
+			continue
+		case errors.Is(err, errStreamingUnauthorized):
+			err = client.rehandshake(ctx, accessToken)
+		case errors.Is(err, errStreamingHandshake):
+			err = client.rehandshake(ctx, "")
+		case errors.Is(err, errStreamingStopped):
+		default:
+			// Network failures and server errors are retried until ctx is done.
+			if sleepContext(ctx, retryDelay) != nil {
+				return
+			}
+			if retryDelay *= 2; retryDelay > streamingMaxRetryDelay {
+				retryDelay = streamingMaxRetryDelay
+			}
+			continue
+		}
+
+		if err != nil {
+			if ctx.Err() == nil {
+				client.mu.Lock()
+				client.err = err
+				client.mu.Unlock()
+			}
+			return
+		}
+	}
+}
+
+// connect sends a single connect request, delivering the events it returns.
+func (client *StreamingClient) connect(ctx context.Context) error {
+	client.mu.Lock()
+	advice := client.advice
+	client.mu.Unlock()
+
+	if advice.Interval > 0 {
+		if err := sleepContext(ctx, time.Duration(advice.Interval)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+
+	timeout := time.Duration(advice.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = streamingPollTimeout
+	}
+	pollCtx, cancel := context.WithTimeout(ctx, timeout+streamingPollGrace)
+	defer cancel()
+
+	msg := client.message(metaConnect)
+	msg.ConnectionType = "long-polling"
+	replies, err := client.send(pollCtx, msg)
+	if err != nil {
+		return err
+	}
+
+	var connectErr error
+	for _, reply := range replies {
+		if reply.Channel != metaConnect {
+			if reply.Data != nil {
+				if err := client.deliver(ctx, reply); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if reply.Advice != nil {
+			client.mu.Lock()
+			client.advice = *reply.Advice
+			client.mu.Unlock()
+		}
+
+		connectErr = replyError(reply)
+	}
+
+	return connectErr
+}
+
+// replyError returns the error that a reply to a meta request reports, taking
+// the advice of the server into account.
+func replyError(reply *bayeuxMessage) error {
+	reconnect := ""
+	if reply.Advice != nil {
+		reconnect = reply.Advice.Reconnect
+	}
+
+	switch {
+	case reconnect == "none":
+		return fmt.Errorf("%w: %v", errStreamingStopped, reply.Error)
+	case strings.HasPrefix(reply.Error, "401::"):
+		return fmt.Errorf("%w: %v", errStreamingUnauthorized, reply.Error)
+	case reconnect == "handshake" || strings.HasPrefix(reply.Error, "403::"):
+		return fmt.Errorf("%w: %v", errStreamingHandshake, reply.Error)
+	case !reply.Successful:
+		return fmt.Errorf("%v", reply.Error)
+	}
+
+	return nil
+}
+
+func (client *StreamingClient) deliver(ctx context.Context, msg *bayeuxMessage) error {
+	event := &Event{
+		Channel:     msg.Channel,
+		ReplayId:    msg.Data.Event.ReplayId,
+		Type:        msg.Data.Event.Type,
+		CreatedDate: msg.Data.Event.CreatedDate,
+		Schema:      msg.Data.Schema,
+		Data:        msg.Data.Payload,
+		client:      client,
+	}
+	if msg.Data.SObject != nil {
+		event.Data = msg.Data.SObject
+	}
+
+	select {
+	case client.events <- event:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if client.store == nil {
+		client.mu.Lock()
+		if _, ok := client.replay[event.Channel]; ok {
+			client.replay[event.Channel] = event.ReplayId
+		}
+		client.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (client *StreamingClient) commit(event *Event) error {
+	if client.store == nil {
+		return nil
+	}
+
+	if err := client.store.Save(event.Channel, event.ReplayId); err != nil {
+		return fmt.Errorf("Error committing %v event %d: %v", event.Channel, event.ReplayId, err)
+	}
+
+	return nil
+}
+
+// rehandshake starts a new session, after reauthenticating if staleToken, the
+// access token that was rejected, is set. It resubscribes to every channel
+// after the last event delivered, or committed when there is a ReplayStore.
+func (client *StreamingClient) rehandshake(ctx context.Context, staleToken string) error {
+	if staleToken != "" {
+		if err := client.forceApi.oauth.reauthenticate(ctx, staleToken); err != nil {
+			return err
+		}
+	}
+
+	if err := client.handshake(ctx); err != nil {
+		return err
+	}
+
+	client.mu.Lock()
+	replay := make(map[string]int64, len(client.replay))
+	for channel, replayId := range client.replay {
+		replay[channel] = replayId
+	}
+	client.mu.Unlock()
+
+	for channel, replayId := range replay {
+		if err := client.Subscribe(ctx, channel, replayId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (client *StreamingClient) handshake(ctx context.Context) error {
+	msg := &bayeuxMessage{
+		Channel:                  metaHandshake,
+		Version:                  "1.0",
+		MinimumVersion:           "1.0",
+		SupportedConnectionTypes: []string{"long-polling"},
+		Ext:                      map[string]interface{}{"replay": true},
+	}
+
+	_, accessToken := client.forceApi.oauth.session()
+	replies, err := client.send(ctx, msg)
+	if errors.Is(err, errStreamingUnauthorized) {
+		// The session expired before the handshake.
+		if err := client.forceApi.oauth.reauthenticate(ctx, accessToken); err != nil {
+			return err
+		}
+		replies, err = client.send(ctx, msg)
+	}
+	if err != nil {
+		return fmt.Errorf("Error sending streaming handshake: %w", err)
+	}
+
+	for _, reply := range replies {
+		if reply.Channel != metaHandshake {
+			continue
+		}
+		if !reply.Successful {
+			return fmt.Errorf("Error sending streaming handshake: %v", reply.Error)
+		}
+
+		client.mu.Lock()
+		client.clientId = reply.ClientId
+		client.advice = bayeuxAdvice{}
+		if reply.Advice != nil {
+			client.advice = *reply.Advice
+		}
+		client.mu.Unlock()
+
+		return nil
+	}
+
+	return fmt.Errorf("Error sending streaming handshake: no reply")
+}
+
+// call sends a meta message and returns the error its reply reports.
+func (client *StreamingClient) call(ctx context.Context, msg *bayeuxMessage) error {
+	replies, err := client.send(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	for _, reply := range replies {
+		if reply.Channel == msg.Channel {
+			return replyError(reply)
+		}
+	}
+
+	return fmt.Errorf("no reply on %v", msg.Channel)
+}
+
+func (client *StreamingClient) message(channel string) *bayeuxMessage {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return &bayeuxMessage{
+		Channel:  channel,
+		ClientId: client.clientId,
+	}
+}
+
+// send posts messages to the CometD endpoint and returns the replies.
+func (client *StreamingClient) send(ctx context.Context, msgs ...*bayeuxMessage) ([]*bayeuxMessage, error) {
+	payload, err := forcejson.Marshal(msgs)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling encoded payload: %v", err)
+	}
+
+	instanceUrl, accessToken := client.forceApi.oauth.session()
+	uri := fmt.Sprintf("%v/cometd/%v", instanceUrl, strings.TrimPrefix(client.forceApi.apiVersion, "v"))
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating streaming request: %v", err)
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", responseType)
+	req.Header.Set("Authorization", fmt.Sprintf("%v %v", "Bearer", accessToken))
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error sending streaming request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response bytes: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: %v", errStreamingUnauthorized, resp.Status)
+	case resp.StatusCode >= http.StatusMultipleChoices:
+		return nil, fmt.Errorf("Error sending streaming request: %v (response: %s)", resp.Status, string(respBytes))
+	}
+
+	replies := []*bayeuxMessage{}
+	if err := forcejson.Unmarshal(respBytes, &replies); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal response to object: %v (response: %s)", err, string(respBytes))
+	}
+
+	return replies, nil
+}
+
+func replayExt(replay map[string]int64) map[string]interface{} {
+	return map[string]interface{}{"replay": replay}
+}
@@ -0,0 +1,273 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBayeux is a minimal CometD server. Events queued with publish are
+// returned by the next connect request.
+type fakeBayeux struct {
+	t      *testing.T
+	mu     sync.Mutex
+	token  string
+	events chan string
+	// connectReply, if set, replaces the reply to the next connect request.
+	connectReply string
+	handshakes   int
+	logins       int
+	subscribes   []string
+	disconnected bool
+}
+
+func newFakeBayeux(t *testing.T) (*fakeBayeux, *ForceApi) {
+	bayeux := &fakeBayeux{t: t, token: "token", events: make(chan string, 10)}
+	forceApi := createTestServer(t, bayeux)
+	forceApi.oauth.loginUrl = forceApi.oauth.InstanceUrl
+
+	return bayeux, forceApi
+}
+
+func (bayeux *fakeBayeux) publish(channel string, replayId int64, data string) {
+	bayeux.events <- fmt.Sprintf(`{"channel":%q,"data":%v}`, channel, strings.Replace(data, "REPLAY", fmt.Sprint(replayId), 1))
+}
+
+// expireSession makes requests with the current token fail until the client
+// logs in again.
+func (bayeux *fakeBayeux) expireSession() {
+	bayeux.mu.Lock()
+	defer bayeux.mu.Unlock()
+
+	bayeux.token = "expired"
+}
+
+func (bayeux *fakeBayeux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bayeux.mu.Lock()
+	defer bayeux.mu.Unlock()
+
+	if r.URL.Path == "/services/oauth2/token" {
+		bayeux.logins++
+		bayeux.token = fmt.Sprintf("token-%d", bayeux.logins)
+		fmt.Fprintf(w, `{"access_token":%q,"instance_url":"http://%v"}`, bayeux.token, r.Host)
+		return
+	}
+
+	if r.URL.Path != "/cometd/36.0" {
+		bayeux.t.Errorf("Unexpected request: %v %v", r.Method, r.URL.Path)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+bayeux.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var msgs []map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil || len(msgs) != 1 {
+		bayeux.t.Errorf("Unexpected messages: %v %v", msgs, err)
+		return
+	}
+
+	msg := msgs[0]
+	clientId := fmt.Sprintf("client-%d", bayeux.handshakes)
+	if msg["channel"] != metaHandshake && msg["clientId"] != clientId {
+		fmt.Fprintf(w, `[{"channel":%q,"successful":false,"error":"403::Unknown client","advice":{"reconnect":"handshake"}}]`, msg["channel"])
+		return
+	}
+
+	switch msg["channel"] {
+	case metaHandshake:
+		bayeux.handshakes++
+		http.SetCookie(w, &http.Cookie{Name: "BAYEUX_BROWSER", Value: fmt.Sprint(bayeux.handshakes)})
+		fmt.Fprintf(w, `[{"channel":"/meta/handshake","clientId":"client-%d","successful":true,"version":"1.0","advice":{"reconnect":"retry","interval":0,"timeout":1000}}]`, bayeux.handshakes)
+	case metaSubscribe:
+		replay, _ := json.Marshal(msg["ext"])
+		bayeux.subscribes = append(bayeux.subscribes, fmt.Sprintf("%v %s", msg["subscription"], replay))
+		fmt.Fprintf(w, `[{"channel":"/meta/subscribe","clientId":%q,"subscription":%q,"successful":true}]`, clientId, msg["subscription"])
+	case metaConnect:
+		if cookie, err := r.Cookie("BAYEUX_BROWSER"); err != nil || cookie.Value != fmt.Sprint(bayeux.handshakes) {
+			bayeux.t.Errorf("Expected session cookie, got %v", cookie)
+		}
+
+		if bayeux.connectReply != "" {
+			fmt.Fprint(w, bayeux.connectReply)
+			bayeux.connectReply = ""
+			return
+		}
+
+		replies := []string{}
+		bayeux.mu.Unlock()
+		select {
+		case event := <-bayeux.events:
+			replies = append(replies, event)
+		case <-time.After(20 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		bayeux.mu.Lock()
+
+		replies = append(replies, `{"channel":"/meta/connect","successful":true,"advice":{"reconnect":"retry","interval":0,"timeout":1000}}`)
+		fmt.Fprint(w, "["+strings.Join(replies, ",")+"]")
+	case metaDisconnect:
+		bayeux.disconnected = true
+		fmt.Fprint(w, `[{"channel":"/meta/disconnect","successful":true}]`)
+	default:
+		bayeux.t.Errorf("Unexpected channel: %v", msg["channel"])
+	}
+}
+
+func receiveEvent(t *testing.T, client *StreamingClient) *Event {
+	select {
+	case event, ok := <-client.Events():
+		if !ok {
+			t.Fatalf("Events closed: %v", client.Err())
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+
+	return nil
+}
+
+func TestStreamingClient(t *testing.T) {
+	bayeux, forceApi := newFakeBayeux(t)
+
+	client := forceApi.NewStreamingClient()
+	ctx := context.Background()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Subscribe(ctx, "/topic/Accounts", ReplayNewEvents); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if err := client.Subscribe(ctx, "/event/Order__e", ReplayAllEvents); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	bayeux.publish("/topic/Accounts", 5, `{"event":{"replayId":REPLAY,"type":"updated","createdDate":"2026-01-01T00:00:00.000+0000"},"sobject":{"Id":"001000000000001","Name":"Acme"}}`)
+	bayeux.publish("/event/Order__e", 9, `{"schema":"abc","event":{"replayId":REPLAY,"EventUuid":"uuid"},"payload":{"Order_Number__c":"42"}}`)
+
+	account := receiveEvent(t, client)
+	if account.Channel != "/topic/Accounts" || account.ReplayId != 5 || account.Type != "updated" {
+		t.Fatalf("Unexpected event: %+v", account)
+	}
+	record := struct {
+		Id   string `force:"Id"`
+		Name string `force:"Name"`
+	}{}
+	if err := account.Decode(&record); err != nil || record.Name != "Acme" {
+		t.Fatalf("Failed to decode event: %+v %v", record, err)
+	}
+
+	order := receiveEvent(t, client)
+	payload := struct {
+		OrderNumber string `force:"Order_Number__c"`
+	}{}
+	if order.ReplayId != 9 || order.Schema != "abc" || order.Decode(&payload) != nil || payload.OrderNumber != "42" {
+		t.Fatalf("Unexpected event: %+v %+v", order, payload)
+	}
+
+	client.Close()
+	if _, ok := <-client.Events(); ok {
+		t.Fatal("Expected events to be closed")
+	}
+	if client.Err() != nil {
+		t.Fatalf("Unexpected error: %v", client.Err())
+	}
+
+	bayeux.mu.Lock()
+	defer bayeux.mu.Unlock()
+	want := []string{`/topic/Accounts {"replay":{"/topic/Accounts":-1}}`, `/event/Order__e {"replay":{"/event/Order__e":-2}}`}
+	if strings.Join(bayeux.subscribes, "\n") != strings.Join(want, "\n") || !bayeux.disconnected {
+		t.Fatalf("Unexpected subscriptions: %v", bayeux.subscribes)
+	}
+}
+
+func TestStreamingClientReauthenticates(t *testing.T) {
+	bayeux, forceApi := newFakeBayeux(t)
+
+	client := forceApi.NewStreamingClient()
+	ctx := context.Background()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Subscribe(ctx, "/data/ChangeEvents", ReplayNewEvents); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	bayeux.publish("/data/ChangeEvents", 3, `{"schema":"abc","event":{"replayId":REPLAY},"payload":{"ChangeEventHeader":{"changeType":"CREATE"}}}`)
+	if event := receiveEvent(t, client); event.ReplayId != 3 {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+
+	bayeux.expireSession()
+	bayeux.publish("/data/ChangeEvents", 4, `{"schema":"abc","event":{"replayId":REPLAY},"payload":{}}`)
+	if event := receiveEvent(t, client); event.ReplayId != 4 {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+
+	bayeux.mu.Lock()
+	defer bayeux.mu.Unlock()
+	if bayeux.logins != 1 || bayeux.handshakes != 2 || forceApi.oauth.AccessToken != "token-1" {
+		t.Fatalf("Expected one login and a new handshake, got %d logins and %d handshakes", bayeux.logins, bayeux.handshakes)
+	}
+	want := `/data/ChangeEvents {"replay":{"/data/ChangeEvents":3}}`
+	if len(bayeux.subscribes) != 2 || bayeux.subscribes[1] != want {
+		t.Fatalf("Expected resubscription after the last event, got %v", bayeux.subscribes)
+	}
+}
+
+func TestStreamingClientRehandshakes(t *testing.T) {
+	bayeux, forceApi := newFakeBayeux(t)
+
+	client := forceApi.NewStreamingClient()
+	ctx := context.Background()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	bayeux.mu.Lock()
+	bayeux.connectReply = `[{"channel":"/meta/connect","successful":false,"error":"403::Unknown client","advice":{"reconnect":"handshake"}}]`
+	bayeux.mu.Unlock()
+
+	if err := client.Subscribe(ctx, "/topic/Accounts", ReplayNewEvents); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	bayeux.publish("/topic/Accounts", 1, `{"event":{"replayId":REPLAY},"sobject":{}}`)
+	receiveEvent(t, client)
+
+	bayeux.mu.Lock()
+	defer bayeux.mu.Unlock()
+	if bayeux.handshakes != 2 || bayeux.logins != 0 {
+		t.Fatalf("Expected a new handshake without login, got %d handshakes and %d logins", bayeux.handshakes, bayeux.logins)
+	}
+}
+
+func TestStreamingClientStops(t *testing.T) {
+	bayeux, forceApi := newFakeBayeux(t)
+	bayeux.connectReply = `[{"channel":"/meta/connect","successful":false,"error":"400::Session terminated","advice":{"reconnect":"none"}}]`
+
+	client := forceApi.NewStreamingClient()
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if _, ok := <-client.Events(); ok {
+		t.Fatal("Expected events to be closed")
+	}
+	if client.Err() == nil || !strings.Contains(client.Err().Error(), "Session terminated") {
+		t.Fatalf("Expected error, got %v", client.Err())
+	}
+}
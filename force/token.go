@@ -0,0 +1,128 @@
+package force
+
+import (
+	"context"
+	"fmt"
+)
+
+const refreshTokenGrantType = "refresh_token"
+
+// Token is a session issued by the OAuth token endpoint. RefreshToken is only
+// set by grants that issue one; Salesforce may rotate it on every refresh,
+// after which the previous refresh token stops working.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	InstanceUrl  string `json:"instance_url"`
+	Id           string `json:"id,omitempty"`
+	IssuedAt     string `json:"issued_at,omitempty"`
+	Signature    string `json:"signature,omitempty"` // See Verify.
+	Scope        string `json:"scope,omitempty"`     // Space separated scopes granted.
+}
+
+// TokenStore persists the tokens issued to a ForceApi, so that a rotated
+// refresh token survives a restart. Implementations must be safe for
+// concurrent use.
+type TokenStore interface {
+	// Load returns the saved token, if any.
+	Load() (token *Token, ok bool, err error)
+	// Save records a newly issued token.
+	Save(token *Token) error
+}
+
+// WithTokenStore saves every token issued to the ForceApi to store.
+// CreateWithRefreshTokenFlow resumes from the saved token, if any, instead of
+// the refresh token it is given.
+func WithTokenStore(store TokenStore) Option {
+	return func(forceApi *ForceApi) {
+		forceApi.oauth.tokenStore = store
+	}
+}
+
+// WithOnTokenRefreshed calls fn with every token issued to the ForceApi,
+// including the first, after it has been saved to the TokenStore, if any.
+// fn is called by the goroutine whose request found the session expired and
+// must not make requests with the ForceApi.
+func WithOnTokenRefreshed(fn func(token Token)) Option {
+	return func(forceApi *ForceApi) {
+		forceApi.oauth.onTokenRefreshed = fn
+	}
+}
+
+// CreateWithRefreshToken creates a ForceApi for an existing session, like
+// CreateWithAccessToken.
+//
+// Deprecated: CreateWithRefreshToken is not given a refresh token, so it
+// cannot refresh the session. Use CreateWithRefreshTokenFlow.
+func CreateWithRefreshToken(version, clientId, accessToken, instanceUrl string) (*ForceApi, error) {
+	return CreateWithAccessToken(version, clientId, accessToken, instanceUrl)
+}
+
+// CreateWithRefreshTokenFlow authenticates using the OAuth 2.0 refresh token
+// flow of the connected app identified by clientId. clientSecret may be empty
+// for connected apps that do not require it for refreshes. The session is
+// refreshed again whenever it expires, and a rotated refresh token replaces
+// the previous one.
+func CreateWithRefreshTokenFlow(version, clientId, clientSecret, refreshToken, environment string, opts ...Option) (*ForceApi, error) {
+	oauth := &forceOauth{
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		refreshToken: refreshToken,
+		environment:  environment,
+	}
+
+	forceApi := newForceApi(version, oauth, opts)
+
+	ctx := context.Background()
+
+	var token *Token
+	if oauth.tokenStore != nil {
+		stored, ok, err := oauth.tokenStore.Load()
+		if err != nil {
+			return nil, fmt.Errorf("Error loading token: %v", err)
+		}
+		if ok {
+			token = stored
+		}
+	}
+
+	if token != nil && token.RefreshToken != "" {
+		oauth.refreshToken = token.RefreshToken
+	}
+
+	if token != nil && token.AccessToken != "" && token.InstanceUrl != "" {
+		// Resume the saved session; it is refreshed on the first request if it
+		// has expired since.
+		resumed := *token
+		resumed.RefreshToken = oauth.refreshToken
+		oauth.setToken(&resumed)
+	} else if err := forceApi.oauth.Authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	// Init Api Resources
+	err := forceApi.getApiResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = forceApi.getApiSObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return forceApi, nil
+}
+
+// RefreshToken replaces the current session with a new one, using the refresh
+// token when the ForceApi has one and its credentials otherwise. Sessions are
+// also replaced automatically when a request finds them expired.
+func (forceApi *ForceApi) RefreshToken() error {
+	return forceApi.RefreshTokenContext(context.Background())
+}
+
+// RefreshTokenContext is like RefreshToken but carries ctx through the request.
+func (forceApi *ForceApi) RefreshTokenContext(ctx context.Context) error {
+	_, accessToken := forceApi.oauth.session()
+
+	return forceApi.oauth.reauthenticate(ctx, accessToken)
+}
@@ -0,0 +1,155 @@
+package force
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/nimajalali/go-force/forcetest"
+)
+
+type testTokenStore struct {
+	mu    sync.Mutex
+	token *Token
+	saves int
+	err   error
+}
+
+func (store *testTokenStore) Load() (*Token, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.token, store.token != nil, nil
+}
+
+func (store *testTokenStore) Save(token *Token) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.err != nil {
+		return store.err
+	}
+	store.token = token
+	store.saves++
+
+	return nil
+}
+
+// createRefreshTokenServer returns a fake whose token endpoint only accepts
+// the current refresh token and rotates it on every refresh.
+func createRefreshTokenServer(t *testing.T) (*forcetest.Server, string, *int) {
+	fake := newTestFake(t)
+	refreshToken, refreshes := "refresh-0", 0
+
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != "POST" || r.URL.Path != tokenPath || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("Unexpected token request: %v %v %v", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if r.PostFormValue("grant_type") != "refresh_token" || r.PostFormValue("refresh_token") != refreshToken ||
+			r.PostFormValue("client_id") != testClientId || r.PostFormValue("client_secret") != testClientSecret {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"expired access/refresh token"}`)
+			return
+		}
+
+		refreshes++
+		refreshToken = fmt.Sprintf("refresh-%d", refreshes)
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token":  fake.AccessToken(),
+			"refresh_token": refreshToken,
+			"instance_url":  fake.URL,
+			"id":            fake.URL + "/id/00D/005",
+			"issued_at":     "1700000000000",
+			"signature":     "c2lnbmF0dXJl",
+			"scope":         "api refresh_token",
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return fake, server.URL, &refreshes
+}
+
+func createRefreshTokenTest(t *testing.T, loginUrl string, opts ...Option) (*ForceApi, error) {
+	opts = append([]Option{WithLoginURL(loginUrl)}, opts...)
+
+	return CreateWithRefreshTokenFlow(testVersion, testClientId, testClientSecret, "refresh-0", testEnvironment, opts...)
+}
+
+func TestCreateWithRefreshTokenFlow(t *testing.T) {
+	fake, loginUrl, refreshes := createRefreshTokenServer(t)
+	store := &testTokenStore{}
+
+	var refreshed []Token
+	forceApi, err := createRefreshTokenTest(t, loginUrl, WithTokenStore(store), WithOnTokenRefreshed(func(token Token) {
+		refreshed = append(refreshed, token)
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create: %v", err)
+	}
+	if *refreshes != 1 || store.token.RefreshToken != "refresh-1" || store.token.AccessToken != forceApi.GetAccessToken() {
+		t.Fatalf("Expected initial token to be saved, got %+v", store.token)
+	}
+
+	// An expired session is refreshed with the rotated refresh token.
+	fake.ExpireSessions()
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Failed to get limits after expiry: %v", err)
+	}
+	if *refreshes != 2 || store.token.RefreshToken != "refresh-2" || store.saves != 2 {
+		t.Fatalf("Expected rotated token to be saved, got %+v", store.token)
+	}
+	if len(refreshed) != 2 || refreshed[1].AccessToken != fake.AccessToken() || refreshed[1].InstanceUrl != fake.URL {
+		t.Fatalf("Unexpected refreshed tokens: %+v", refreshed)
+	}
+
+	if err := forceApi.RefreshToken(); err != nil || *refreshes != 3 || store.token.RefreshToken != "refresh-3" {
+		t.Fatalf("Failed to refresh: %v %+v", err, store.token)
+	}
+
+	// A new client resumes the saved session without refreshing, then
+	// refreshes with the saved refresh token once it expires.
+	resumed, err := createRefreshTokenTest(t, loginUrl, WithTokenStore(store))
+	if err != nil || *refreshes != 3 || resumed.oauth.token() != *store.token {
+		t.Fatalf("Expected saved session to be resumed: %v", err)
+	}
+	fake.ExpireSessions()
+	if _, err := resumed.GetLimits(); err != nil || *refreshes != 4 {
+		t.Fatalf("Failed to refresh resumed session: %v", err)
+	}
+
+	// The original refresh token has been rotated away.
+	if _, err := createRefreshTokenTest(t, loginUrl); !errors.Is(err, ErrorCode("invalid_grant")) {
+		t.Fatalf("Expected rotated refresh token to be rejected, got %v", err)
+	}
+}
+
+func TestTokenStoreSaveError(t *testing.T) {
+	_, loginUrl, _ := createRefreshTokenServer(t)
+	store := &testTokenStore{err: errors.New("store unavailable")}
+
+	if _, err := createRefreshTokenTest(t, loginUrl, WithTokenStore(store)); err == nil || err.Error() != "Error saving token: store unavailable" {
+		t.Fatalf("Expected save error, got %v", err)
+	}
+}
+
+func TestCreateWithRefreshToken(t *testing.T) {
+	server := newTestFake(t)
+
+	// The deprecated constructor uses the given session.
+	forceApi, err := CreateWithRefreshToken(testVersion, testClientId, server.AccessToken(), server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create: %v", err)
+	}
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Failed to get limits: %v", err)
+	}
+}
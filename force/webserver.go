@@ -0,0 +1,187 @@
+package force
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	authorizePath              = "/services/oauth2/authorize"
+	authorizationCodeGrantType = "authorization_code"
+)
+
+// WebServerFlow implements the OAuth 2.0 web server flow, which lets users
+// connect their own Salesforce accounts to an application:
+//
+//	flow := &force.WebServerFlow{ClientId: clientId, RedirectURL: "https://example.com/callback"}
+//	verifier, err := force.NewCodeVerifier()
+//	// Keep state and verifier in the user's session, then redirect.
+//	http.Redirect(w, r, flow.AuthorizeURL(state, verifier), http.StatusFound)
+//
+//	// In the handler of RedirectURL, after checking the state parameter:
+//	token, err := flow.Exchange(ctx, r.FormValue("code"), verifier)
+//	forceApi, err := flow.Create(version, token)
+type WebServerFlow struct {
+	ClientId string
+	// ClientSecret may be empty for connected apps that do not require it
+	// with PKCE. When set, the signature of issued tokens is verified.
+	ClientSecret string
+	RedirectURL  string
+	// Scopes requested, such as "api" and "refresh_token". The scopes of
+	// the connected app are requested when empty.
+	Scopes []string
+	// Prompt is a space separated list of "login", "consent" and
+	// "select_account".
+	Prompt string
+	// Environment is "sandbox" to log in at test.salesforce.com. LoginURL,
+	// such as a My Domain URL, takes precedence over it.
+	Environment string
+	LoginURL    string
+	// HTTPClient is used for Exchange. http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+}
+
+// NewCodeVerifier returns a random PKCE code verifier. Keep it with the state
+// of the authorization request and pass it to both AuthorizeURL and Exchange.
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("Error generating code verifier: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthorizeURL returns the url to redirect users to, which sends them back to
+// RedirectURL with the given state and an authorization code. The code
+// challenge of codeVerifier is sent unless it is empty.
+func (flow *WebServerFlow) AuthorizeURL(state, codeVerifier string) string {
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {flow.ClientId},
+		"redirect_uri":  {flow.RedirectURL},
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+	if len(flow.Scopes) != 0 {
+		params.Set("scope", strings.Join(flow.Scopes, " "))
+	}
+	if flow.Prompt != "" {
+		params.Set("prompt", flow.Prompt)
+	}
+	if codeVerifier != "" {
+		challenge := sha256.Sum256([]byte(codeVerifier))
+		params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+		params.Set("code_challenge_method", "S256")
+	}
+
+	return flow.loginURL() + authorizePath + "?" + params.Encode()
+}
+
+// Exchange trades an authorization code for a token. codeVerifier must be the
+// one passed to AuthorizeURL.
+func (flow *WebServerFlow) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	payload := url.Values{
+		"grant_type":   {authorizationCodeGrantType},
+		"code":         {code},
+		"client_id":    {flow.ClientId},
+		"redirect_uri": {flow.RedirectURL},
+	}
+	if flow.ClientSecret != "" {
+		payload.Set("client_secret", flow.ClientSecret)
+	}
+	if codeVerifier != "" {
+		payload.Set("code_verifier", codeVerifier)
+	}
+
+	client := flow.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	token, err := fetchToken(ctx, client, flow.loginURL()+tokenPath, payload, Token{})
+	if err != nil {
+		return nil, err
+	}
+
+	if flow.ClientSecret != "" {
+		if err := token.Verify(flow.ClientSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+// Create returns a ForceApi using token, as returned by Exchange, which is
+// passed to the TokenStore and OnTokenRefreshed callback of opts. The session
+// is refreshed with the refresh token of token whenever it expires, when the
+// refresh_token scope was granted.
+func (flow *WebServerFlow) Create(version string, token *Token, opts ...Option) (*ForceApi, error) {
+	oauth := &forceOauth{
+		clientId:     flow.ClientId,
+		clientSecret: flow.ClientSecret,
+		loginUrl:     flow.loginURL(),
+	}
+	oauth.setToken(token)
+
+	forceApi := newForceApi(version, oauth, opts)
+
+	ctx := context.Background()
+
+	if err := forceApi.oauth.Validate(); err != nil {
+		return nil, err
+	}
+	if err := forceApi.oauth.tokenIssued(token); err != nil {
+		return nil, err
+	}
+
+	// Init Api Resources
+	err := forceApi.getApiResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = forceApi.getApiSObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return forceApi, nil
+}
+
+func (flow *WebServerFlow) loginURL() string {
+	if flow.LoginURL != "" {
+		return strings.TrimSuffix(flow.LoginURL, "/")
+	}
+	if flow.Environment == "sandbox" {
+		return testLoginUrl
+	}
+
+	return loginUrl
+}
+
+// Verify checks the signature of the token, which Salesforce computes as the
+// HMAC-SHA256 of the id and issued_at fields keyed with the client secret of
+// the connected app.
+func (token *Token) Verify(clientSecret string) error {
+	signature, err := base64.StdEncoding.DecodeString(token.Signature)
+	if err != nil {
+		return fmt.Errorf("Invalid token signature: %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(clientSecret))
+	mac.Write([]byte(token.Id + token.IssuedAt))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return fmt.Errorf("Invalid token signature")
+	}
+
+	return nil
+}
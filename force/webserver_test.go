@@ -0,0 +1,159 @@
+package force
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAuthorizeURL(t *testing.T) {
+	flow := &WebServerFlow{
+		ClientId:    testClientId,
+		RedirectURL: "https://example.com/callback",
+		Scopes:      []string{"api", "refresh_token"},
+		Prompt:      "login consent",
+		Environment: "sandbox",
+	}
+
+	verifier, err := NewCodeVerifier()
+	if err != nil || len(verifier) != 43 {
+		t.Fatalf("Unexpected code verifier %q: %v", verifier, err)
+	}
+	if other, _ := NewCodeVerifier(); other == verifier {
+		t.Fatal("Expected random code verifiers")
+	}
+
+	authorizeURL, err := url.Parse(flow.AuthorizeURL("state-1", verifier))
+	if err != nil {
+		t.Fatalf("Invalid authorize url: %v", err)
+	}
+	if authorizeURL.Host != "test.salesforce.com" || authorizeURL.Path != "/services/oauth2/authorize" {
+		t.Fatalf("Unexpected authorize url: %v", authorizeURL)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := authorizeURL.Query()
+	for name, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientId,
+		"redirect_uri":          "https://example.com/callback",
+		"state":                 "state-1",
+		"scope":                 "api refresh_token",
+		"prompt":                "login consent",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(challenge[:]),
+		"code_challenge_method": "S256",
+	} {
+		if got := params.Get(name); got != want {
+			t.Errorf("Expected %v %q, got %q", name, want, got)
+		}
+	}
+
+	flow.LoginURL = "https://example.my.salesforce.com/"
+	if got := flow.AuthorizeURL("", ""); got != "https://example.my.salesforce.com/services/oauth2/authorize?client_id="+
+		url.QueryEscape(testClientId)+"&prompt=login+consent&redirect_uri=https%3A%2F%2Fexample.com%2Fcallback&response_type=code&scope=api+refresh_token" {
+		t.Fatalf("Unexpected authorize url: %v", got)
+	}
+}
+
+func TestWebServerFlow(t *testing.T) {
+	fake := newTestFake(t)
+	flow := &WebServerFlow{
+		ClientId:     testClientId,
+		ClientSecret: testClientSecret,
+		RedirectURL:  "https://example.com/callback",
+		LoginURL:     fake.URL,
+	}
+
+	token, err := flow.Exchange(context.Background(), "code-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+	if token.AccessToken != fake.AccessToken() || token.RefreshToken == "" || token.InstanceUrl != fake.URL || token.Id == "" {
+		t.Fatalf("Unexpected token: %+v", token)
+	}
+
+	var refreshed []Token
+	forceApi, err := flow.Create(testVersion, token, WithOnTokenRefreshed(func(token Token) {
+		refreshed = append(refreshed, token)
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create: %v", err)
+	}
+
+	// The refresh token issued by the exchange renews the session.
+	fake.ExpireSessions()
+	if _, err := forceApi.GetLimits(); err != nil {
+		t.Fatalf("Failed to refresh session: %v", err)
+	}
+	if len(refreshed) != 2 || refreshed[0].AccessToken != token.AccessToken || refreshed[1].AccessToken != fake.AccessToken() {
+		t.Fatalf("Unexpected refreshed tokens: %+v", refreshed)
+	}
+}
+
+func TestWebServerFlowExchangeRequest(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		switch form.Get("code") {
+		case "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"invalid authorization code"}`)
+		default:
+			fmt.Fprint(w, `{"access_token":"token","instance_url":"https://example.my.salesforce.com","id":"https://login.salesforce.com/id/00D/005","issued_at":"1700000000000","signature":"tampered"}`)
+		}
+	}))
+	defer server.Close()
+
+	flow := &WebServerFlow{ClientId: "client", RedirectURL: "https://example.com/callback", LoginURL: server.URL}
+	token, err := flow.Exchange(context.Background(), "code-1", "verifier-1")
+	if err != nil || token.AccessToken != "token" {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+	want := "client_id=client&code=code-1&code_verifier=verifier-1&grant_type=authorization_code&redirect_uri=https%3A%2F%2Fexample.com%2Fcallback"
+	if form.Encode() != want {
+		t.Fatalf("Unexpected token request %v", form.Encode())
+	}
+
+	// With a client secret the signature is verified.
+	flow.ClientSecret = "secret"
+	if _, err := flow.Exchange(context.Background(), "code-1", ""); err == nil || !strings.Contains(err.Error(), "Invalid token signature") {
+		t.Fatalf("Expected tampered signature to be rejected, got %v", err)
+	}
+	if form.Get("client_secret") != "secret" || form.Has("code_verifier") {
+		t.Fatalf("Unexpected token request %v", form.Encode())
+	}
+
+	var apiErr *ApiError
+	if _, err := flow.Exchange(context.Background(), "bad", ""); !errors.As(err, &apiErr) || apiErr.ErrorName != "invalid_grant" || apiErr.HTTPStatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected invalid grant, got %v", err)
+	}
+}
+
+func TestTokenVerify(t *testing.T) {
+	// The signature is base64(HMAC-SHA256("secret", Id+IssuedAt)).
+	token := &Token{
+		Id:        "https://login.salesforce.com/id/00D000000000001/005000000000001",
+		IssuedAt:  "1700000000000",
+		Signature: "LK8PM0asZXwYxEzR/Nc04FAbln/6P7WEKPrHYIozVk0=",
+	}
+
+	if err := token.Verify("secret"); err != nil {
+		t.Fatalf("Expected signature to verify: %v", err)
+	}
+	if err := token.Verify("other"); err == nil {
+		t.Fatal("Expected wrong secret to fail")
+	}
+
+	token.IssuedAt = "1700000000001"
+	if err := token.Verify("secret"); err == nil {
+		t.Fatal("Expected modified token to fail")
+	}
+}
@@ -0,0 +1,286 @@
+// Package forcemock provides a hand-written mock of force.ForceClient for unit
+// tests of code that talks to Salesforce. Set the func field of each method
+// the code under test calls; calls to methods without one fail with
+// ErrUnexpectedCall. Every call is recorded whether or not it succeeds.
+//
+//	client := &forcemock.Client{
+//		QueryFunc: func(ctx context.Context, query string, out interface{}) error {
+//			return forcemock.Fill(out, &AccountQueryResponse{Records: accounts})
+//		},
+//	}
+//	err := service.Sync(client)
+//	calls := client.Calls("Query")
+//
+// Query and QueryContext are both handled by QueryFunc and recorded as
+// "Query", and likewise for the other methods.
+package forcemock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/nimajalali/go-force/force"
+)
+
+// ErrUnexpectedCall is returned by methods whose func field is not set.
+var ErrUnexpectedCall = errors.New("forcemock: unexpected call")
+
+// Call is a single recorded method call. Args holds the arguments after ctx,
+// in order.
+type Call struct {
+	Method string
+	Ctx    context.Context
+	Args   []interface{}
+}
+
+// Client implements force.ForceClient by delegating to its func fields.
+type Client struct {
+	QueryFunc     func(ctx context.Context, query string, out interface{}) error
+	QueryAllFunc  func(ctx context.Context, query string, out interface{}) error
+	QueryNextFunc func(ctx context.Context, uri string, out interface{}) error
+
+	GetSObjectFunc    func(ctx context.Context, id string, fields []string, out force.SObject) error
+	InsertSObjectFunc func(ctx context.Context, in force.SObject, externalObj interface{}, opts ...force.RequestOption) (*force.SObjectResponse, error)
+	UpdateSObjectFunc func(ctx context.Context, id string, in force.SObject, externalObj interface{}, opts ...force.RequestOption) error
+	DeleteSObjectFunc func(ctx context.Context, id string, in force.SObject) error
+
+	GetSObjectByExternalIdFunc    func(ctx context.Context, id string, fields []string, out force.SObject) error
+	UpsertSObjectByExternalIdFunc func(ctx context.Context, id string, in force.SObject, externalObj interface{}, opts ...force.RequestOption) (*force.SObjectResponse, error)
+	DeleteSObjectByExternalIdFunc func(ctx context.Context, id string, in force.SObject) error
+
+	DescribeSObjectFunc  func(ctx context.Context, in force.SObject) (*force.SObjectDescription, error)
+	DescribeSObjectsFunc func(ctx context.Context) (map[string]*force.SObjectMetaData, error)
+
+	GetLimitsFunc func(ctx context.Context) (*force.Limits, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ force.ForceClient = (*Client)(nil)
+
+// Calls returns the recorded calls to the named methods in the order they were
+// made, or every recorded call if no method is named.
+func (c *Client) Calls(methods ...string) []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls := []Call{}
+	for _, call := range c.calls {
+		if len(methods) == 0 || contains(methods, call.Method) {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Reset forgets the recorded calls.
+func (c *Client) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = nil
+}
+
+// record records a call and reports whether fn is set.
+func (c *Client) record(method string, fn interface{}, ctx context.Context, args ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, Call{Method: method, Ctx: ctx, Args: args})
+	if reflect.ValueOf(fn).IsNil() {
+		return fmt.Errorf("%w to %v", ErrUnexpectedCall, method)
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Fill stores a canned value in out, which must be a non-nil pointer. value may
+// be of the type out points to or a pointer to it.
+func Fill(out, value interface{}) error {
+	dst := reflect.ValueOf(out)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return fmt.Errorf("forcemock: cannot fill non-pointer %T", out)
+	}
+
+	src := reflect.ValueOf(value)
+	if src.Kind() == reflect.Ptr && src.Type() == dst.Type() {
+		src = src.Elem()
+	}
+	if !src.IsValid() || !src.Type().AssignableTo(dst.Elem().Type()) {
+		return fmt.Errorf("forcemock: cannot fill %T with %T", out, value)
+	}
+	dst.Elem().Set(src)
+
+	return nil
+}
+
+func (c *Client) Query(query string, out interface{}) error {
+	return c.QueryContext(context.Background(), query, out)
+}
+
+func (c *Client) QueryContext(ctx context.Context, query string, out interface{}) error {
+	if err := c.record("Query", c.QueryFunc, ctx, query, out); err != nil {
+		return err
+	}
+
+	return c.QueryFunc(ctx, query, out)
+}
+
+func (c *Client) QueryAll(query string, out interface{}) error {
+	return c.QueryAllContext(context.Background(), query, out)
+}
+
+func (c *Client) QueryAllContext(ctx context.Context, query string, out interface{}) error {
+	if err := c.record("QueryAll", c.QueryAllFunc, ctx, query, out); err != nil {
+		return err
+	}
+
+	return c.QueryAllFunc(ctx, query, out)
+}
+
+func (c *Client) QueryNext(uri string, out interface{}) error {
+	return c.QueryNextContext(context.Background(), uri, out)
+}
+
+func (c *Client) QueryNextContext(ctx context.Context, uri string, out interface{}) error {
+	if err := c.record("QueryNext", c.QueryNextFunc, ctx, uri, out); err != nil {
+		return err
+	}
+
+	return c.QueryNextFunc(ctx, uri, out)
+}
+
+func (c *Client) GetSObject(id string, fields []string, out force.SObject) error {
+	return c.GetSObjectContext(context.Background(), id, fields, out)
+}
+
+func (c *Client) GetSObjectContext(ctx context.Context, id string, fields []string, out force.SObject) error {
+	if err := c.record("GetSObject", c.GetSObjectFunc, ctx, id, fields, out); err != nil {
+		return err
+	}
+
+	return c.GetSObjectFunc(ctx, id, fields, out)
+}
+
+func (c *Client) InsertSObject(in force.SObject, externalObj interface{}, opts ...force.RequestOption) (*force.SObjectResponse, error) {
+	return c.InsertSObjectContext(context.Background(), in, externalObj, opts...)
+}
+
+func (c *Client) InsertSObjectContext(ctx context.Context, in force.SObject, externalObj interface{}, opts ...force.RequestOption) (*force.SObjectResponse, error) {
+	if err := c.record("InsertSObject", c.InsertSObjectFunc, ctx, in, externalObj, opts); err != nil {
+		return nil, err
+	}
+
+	return c.InsertSObjectFunc(ctx, in, externalObj, opts...)
+}
+
+func (c *Client) UpdateSObject(id string, in force.SObject, externalObj interface{}, opts ...force.RequestOption) error {
+	return c.UpdateSObjectContext(context.Background(), id, in, externalObj, opts...)
+}
+
+func (c *Client) UpdateSObjectContext(ctx context.Context, id string, in force.SObject, externalObj interface{}, opts ...force.RequestOption) error {
+	if err := c.record("UpdateSObject", c.UpdateSObjectFunc, ctx, id, in, externalObj, opts); err != nil {
+		return err
+	}
+
+	return c.UpdateSObjectFunc(ctx, id, in, externalObj, opts...)
+}
+
+func (c *Client) DeleteSObject(id string, in force.SObject) error {
+	return c.DeleteSObjectContext(context.Background(), id, in)
+}
+
+func (c *Client) DeleteSObjectContext(ctx context.Context, id string, in force.SObject) error {
+	if err := c.record("DeleteSObject", c.DeleteSObjectFunc, ctx, id, in); err != nil {
+		return err
+	}
+
+	return c.DeleteSObjectFunc(ctx, id, in)
+}
+
+func (c *Client) GetSObjectByExternalId(id string, fields []string, out force.SObject) error {
+	return c.GetSObjectByExternalIdContext(context.Background(), id, fields, out)
+}
+
+func (c *Client) GetSObjectByExternalIdContext(ctx context.Context, id string, fields []string, out force.SObject) error {
+	if err := c.record("GetSObjectByExternalId", c.GetSObjectByExternalIdFunc, ctx, id, fields, out); err != nil {
+		return err
+	}
+
+	return c.GetSObjectByExternalIdFunc(ctx, id, fields, out)
+}
+
+func (c *Client) UpsertSObjectByExternalId(id string, in force.SObject, externalObj interface{}, opts ...force.RequestOption) (*force.SObjectResponse, error) {
+	return c.UpsertSObjectByExternalIdContext(context.Background(), id, in, externalObj, opts...)
+}
+
+func (c *Client) UpsertSObjectByExternalIdContext(ctx context.Context, id string, in force.SObject, externalObj interface{}, opts ...force.RequestOption) (*force.SObjectResponse, error) {
+	if err := c.record("UpsertSObjectByExternalId", c.UpsertSObjectByExternalIdFunc, ctx, id, in, externalObj, opts); err != nil {
+		return nil, err
+	}
+
+	return c.UpsertSObjectByExternalIdFunc(ctx, id, in, externalObj, opts...)
+}
+
+func (c *Client) DeleteSObjectByExternalId(id string, in force.SObject) error {
+	return c.DeleteSObjectByExternalIdContext(context.Background(), id, in)
+}
+
+func (c *Client) DeleteSObjectByExternalIdContext(ctx context.Context, id string, in force.SObject) error {
+	if err := c.record("DeleteSObjectByExternalId", c.DeleteSObjectByExternalIdFunc, ctx, id, in); err != nil {
+		return err
+	}
+
+	return c.DeleteSObjectByExternalIdFunc(ctx, id, in)
+}
+
+func (c *Client) DescribeSObject(in force.SObject) (*force.SObjectDescription, error) {
+	return c.DescribeSObjectContext(context.Background(), in)
+}
+
+func (c *Client) DescribeSObjectContext(ctx context.Context, in force.SObject) (*force.SObjectDescription, error) {
+	if err := c.record("DescribeSObject", c.DescribeSObjectFunc, ctx, in); err != nil {
+		return nil, err
+	}
+
+	return c.DescribeSObjectFunc(ctx, in)
+}
+
+func (c *Client) DescribeSObjects() (map[string]*force.SObjectMetaData, error) {
+	return c.DescribeSObjectsContext(context.Background())
+}
+
+func (c *Client) DescribeSObjectsContext(ctx context.Context) (map[string]*force.SObjectMetaData, error) {
+	if err := c.record("DescribeSObjects", c.DescribeSObjectsFunc, ctx); err != nil {
+		return nil, err
+	}
+
+	return c.DescribeSObjectsFunc(ctx)
+}
+
+func (c *Client) GetLimits() (*force.Limits, error) {
+	return c.GetLimitsContext(context.Background())
+}
+
+func (c *Client) GetLimitsContext(ctx context.Context) (*force.Limits, error) {
+	if err := c.record("GetLimits", c.GetLimitsFunc, ctx); err != nil {
+		return nil, err
+	}
+
+	return c.GetLimitsFunc(ctx)
+}
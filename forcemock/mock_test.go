@@ -0,0 +1,93 @@
+package forcemock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nimajalali/go-force/force"
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+type accountQueryResponse struct {
+	sobjects.BaseQuery
+	Records []sobjects.Account `force:"records"`
+}
+
+// countAccounts stands in for consumer code that depends on the interface.
+func countAccounts(client force.ForceClient) (int, error) {
+	list := &accountQueryResponse{}
+	if err := client.Query("SELECT Id FROM Account", list); err != nil {
+		return 0, err
+	}
+
+	return len(list.Records), nil
+}
+
+func TestClient(t *testing.T) {
+	client := &Client{
+		QueryFunc: func(ctx context.Context, query string, out interface{}) error {
+			return Fill(out, &accountQueryResponse{Records: make([]sobjects.Account, 2)})
+		},
+		InsertSObjectFunc: func(ctx context.Context, in force.SObject, externalObj interface{}, opts ...force.RequestOption) (*force.SObjectResponse, error) {
+			return &force.SObjectResponse{Id: "001000000000001", Success: true}, nil
+		},
+	}
+
+	if count, err := countAccounts(client); err != nil || count != 2 {
+		t.Fatalf("Expected 2 accounts, got %v %v", count, err)
+	}
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	account := &sobjects.Account{}
+	if resp, err := client.InsertSObjectContext(ctx, account, nil); err != nil || resp.Id != "001000000000001" {
+		t.Fatalf("Unexpected insert response: %v %v", resp, err)
+	}
+
+	if _, err := client.GetLimits(); !errors.Is(err, ErrUnexpectedCall) {
+		t.Fatalf("Expected unexpected call, got %v", err)
+	}
+
+	calls := client.Calls()
+	if len(calls) != 3 || calls[0].Method != "Query" || calls[1].Method != "InsertSObject" || calls[2].Method != "GetLimits" {
+		t.Fatalf("Unexpected calls: %+v", calls)
+	}
+	if calls[0].Args[0] != "SELECT Id FROM Account" {
+		t.Fatalf("Unexpected query args: %v", calls[0].Args)
+	}
+
+	inserts := client.Calls("InsertSObject")
+	if len(inserts) != 1 || inserts[0].Ctx != ctx || inserts[0].Args[0] != account {
+		t.Fatalf("Unexpected insert calls: %+v", inserts)
+	}
+
+	client.Reset()
+	if calls := client.Calls(); len(calls) != 0 {
+		t.Fatalf("Expected calls to be reset, got %+v", calls)
+	}
+}
+
+func TestFill(t *testing.T) {
+	limits := force.Limits{"DailyApiRequests": {Remaining: 10, Max: 15}}
+
+	out := force.Limits{}
+	if err := Fill(&out, limits); err != nil || out["DailyApiRequests"].Max != 15 {
+		t.Fatalf("Failed to fill value: %v %v", out, err)
+	}
+
+	out = force.Limits{}
+	if err := Fill(&out, &limits); err != nil || out["DailyApiRequests"].Max != 15 {
+		t.Fatalf("Failed to fill pointer: %v %v", out, err)
+	}
+
+	if err := Fill(out, limits); err == nil {
+		t.Fatal("Expected non-pointer to fail")
+	}
+	if err := Fill(&out, "limits"); err == nil {
+		t.Fatal("Expected mismatched type to fail")
+	}
+	if err := Fill(&out, nil); err == nil {
+		t.Fatal("Expected nil to fail")
+	}
+}
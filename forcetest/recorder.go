@@ -0,0 +1,543 @@
+package forcetest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// RecordEnv is the environment variable that makes ModeFromEnv select
+// ModeRecord when set to a non-empty value.
+const RecordEnv = "FORCETEST_RECORD"
+
+const (
+	// Origin that replaces the instance url and every other host in
+	// recordings. Replayed token responses send clients there, which is
+	// harmless as the Recorder answers for every host.
+	recordedOrigin = "https://forcetest.invalid"
+	redacted       = "REDACTED"
+)
+
+// Form, query and JSON keys whose values are redacted from recordings. When
+// replaying, they match any value.
+var secretKeys = map[string]bool{
+	"access_token":     true,
+	"refresh_token":    true,
+	"id_token":         true,
+	"signature":        true,
+	"client_secret":    true,
+	"client_assertion": true,
+	"password":         true,
+	"assertion":        true,
+	"code":             true,
+	"code_verifier":    true,
+	"token":            true,
+	"sessionId":        true,
+	"oauth_token":      true,
+}
+
+// Secrets shorter than this are only redacted by key, as replacing them
+// everywhere would mangle unrelated values.
+const minSecretLength = 8
+
+// Headers left out of recordings. The length of a body changes when it is
+// scrubbed and is set again on replay.
+var skippedHeaders = map[string]bool{
+	"Authorization":  true,
+	"Cookie":         true,
+	"Set-Cookie":     true,
+	"Date":           true,
+	"Content-Length": true,
+}
+
+// Mode selects whether a Recorder records or replays interactions.
+type Mode int
+
+const (
+	// ModeReplay answers requests from a recording and fails those that are
+	// not in it.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests on and records them with their responses.
+	ModeRecord
+)
+
+// ModeFromEnv returns ModeRecord if the RecordEnv environment variable is
+// set and ModeReplay otherwise.
+func ModeFromEnv() Mode {
+	if os.Getenv(RecordEnv) != "" {
+		return ModeRecord
+	}
+
+	return ModeReplay
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the part of a request used to match it. The host is not
+// recorded.
+type RecordedRequest struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Query       string `json:"query,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded response. Bodies that are not valid UTF-8
+// are base64 encoded.
+type RecordedResponse struct {
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+type recording struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records interactions with Salesforce
+// to a golden file, or replays them from it. Use it with a ForceApi as a
+// middleware:
+//
+//	recorder, err := forcetest.NewRecorder("testdata/accounts.json", forcetest.ModeFromEnv())
+//	...
+//	defer recorder.Close()
+//	forceApi, err := force.CreateWithAccessToken(version, clientId, token, instanceUrl,
+//		force.WithMiddleware(recorder.Middleware))
+//
+// When replaying, the credentials and instance url may be anything, as
+// requests are matched by method, path, query and body, ignoring the host,
+// and never leave the process. Authorization headers, cookies, tokens,
+// passwords and the hosts requests were sent to are scrubbed from
+// recordings; Redact removes other secrets.
+type Recorder struct {
+	path string
+	mode Mode
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+	secrets      map[string]string
+	origins      map[string]bool
+}
+
+// NewRecorder returns a Recorder for the golden file at path. In ModeReplay
+// the file is read immediately; in ModeRecord it is written by Save or Close.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	recorder := &Recorder{
+		path:    path,
+		mode:    mode,
+		secrets: map[string]string{},
+		origins: map[string]bool{},
+	}
+
+	if mode == ModeReplay {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading recording: %v", err)
+		}
+
+		loaded := &recording{}
+		if err := json.Unmarshal(contents, loaded); err != nil {
+			return nil, fmt.Errorf("Error reading recording %v: %v", path, err)
+		}
+
+		recorder.interactions = loaded.Interactions
+		recorder.used = make([]bool, len(loaded.Interactions))
+	}
+
+	return recorder, nil
+}
+
+// Mode returns the mode of the recorder.
+func (recorder *Recorder) Mode() Mode {
+	return recorder.mode
+}
+
+// Redact replaces secret with replacement wherever it appears in the
+// recording.
+func (recorder *Recorder) Redact(secret, replacement string) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if secret != "" {
+		recorder.secrets[secret] = replacement
+	}
+}
+
+// Middleware returns a transport that records the requests sent through next
+// or replays them. It has the signature of force.Middleware.
+func (recorder *Recorder) Middleware(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return recorder.roundTrip(req, next)
+	})
+}
+
+// RoundTrip records requests sent through http.DefaultTransport, or replays
+// them.
+func (recorder *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	return recorder.roundTrip(req, http.DefaultTransport)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (recorder *Recorder) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if recorder.mode == ModeRecord {
+		return recorder.record(req, next)
+	}
+
+	return recorder.replay(req)
+}
+
+func (recorder *Recorder) record(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	recorder.origins[req.URL.Scheme+"://"+req.URL.Host] = true
+	if token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "); len(token) >= minSecretLength {
+		recorder.secrets[token] = redacted
+	}
+	recorder.collectSecrets(req.URL.RawQuery, req.Header.Get("Content-Type"), string(body))
+	recorder.collectSecrets("", resp.Header.Get("Content-Type"), string(respBody))
+
+	header := http.Header{}
+	for name, values := range resp.Header {
+		if !skippedHeaders[http.CanonicalHeaderKey(name)] {
+			header[name] = values
+		}
+	}
+
+	interaction := &Interaction{
+		Request: RecordedRequest{
+			Method:      req.Method,
+			Path:        req.URL.Path,
+			Query:       req.URL.RawQuery,
+			ContentType: req.Header.Get("Content-Type"),
+			Body:        string(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     header,
+			Body:       string(respBody),
+		},
+	}
+	if !utf8.Valid(respBody) {
+		interaction.Response.Body = base64.StdEncoding.EncodeToString(respBody)
+		interaction.Response.BodyEncoding = "base64"
+	}
+	recorder.interactions = append(recorder.interactions, interaction)
+
+	return resp, nil
+}
+
+func (recorder *Recorder) replay(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	method := req.Method
+	path := req.URL.Path
+	query := normalizeQuery(req.URL.RawQuery)
+	normalizedBody := normalizeBody(req.Header.Get("Content-Type"), string(body))
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	for i, interaction := range recorder.interactions {
+		recorded := interaction.Request
+		if recorder.used[i] || recorded.Method != method || recorded.Path != path ||
+			normalizeQuery(recorded.Query) != query || normalizeBody(recorded.ContentType, recorded.Body) != normalizedBody {
+			continue
+		}
+		recorder.used[i] = true
+
+		respBody := []byte(interaction.Response.Body)
+		if interaction.Response.BodyEncoding == "base64" {
+			if respBody, err = base64.StdEncoding.DecodeString(interaction.Response.Body); err != nil {
+				return nil, fmt.Errorf("Error decoding recorded response: %v", err)
+			}
+		}
+
+		header := interaction.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %v", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(respBody)),
+			ContentLength: int64(len(respBody)),
+			Request:       req,
+		}, nil
+	}
+
+	target := path
+	if query != "" {
+		target += "?" + query
+	}
+	return nil, fmt.Errorf("forcetest: unexpected request %v %v not in recording %v", method, target, recorder.path)
+}
+
+// Save writes the scrubbed recording.
+func (recorder *Recorder) Save() error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	replacements := []string{}
+	for secret, replacement := range recorder.secrets {
+		replacements = append(replacements, secret, replacement)
+	}
+	for origin := range recorder.origins {
+		replacements = append(replacements, origin, recordedOrigin)
+	}
+	sortReplacements(replacements)
+	replacer := strings.NewReplacer(replacements...)
+
+	scrubbed := &recording{Interactions: make([]*Interaction, len(recorder.interactions))}
+	for i, interaction := range recorder.interactions {
+		request := interaction.Request
+		request.Query = replacer.Replace(normalizeQuery(request.Query))
+		request.Body = replacer.Replace(normalizeBody(request.ContentType, request.Body))
+
+		response := interaction.Response
+		response.Header = http.Header{}
+		for name, values := range interaction.Response.Header {
+			for _, value := range values {
+				response.Header.Add(name, replacer.Replace(value))
+			}
+		}
+		if response.BodyEncoding == "" {
+			response.Body = replacer.Replace(normalizeBody(interaction.Response.Header.Get("Content-Type"), response.Body))
+		}
+
+		scrubbed.Interactions[i] = &Interaction{Request: request, Response: response}
+	}
+
+	contents, err := json.MarshalIndent(scrubbed, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding recording: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(recorder.path), 0755); err != nil {
+		return fmt.Errorf("Error writing recording: %v", err)
+	}
+	if err := ioutil.WriteFile(recorder.path, append(contents, '\n'), 0644); err != nil {
+		return fmt.Errorf("Error writing recording: %v", err)
+	}
+
+	return nil
+}
+
+// Close saves the recording in ModeRecord. In ModeReplay it reports the
+// recorded interactions that were not replayed.
+func (recorder *Recorder) Close() error {
+	if recorder.mode == ModeRecord {
+		return recorder.Save()
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	unused := []string{}
+	for i, interaction := range recorder.interactions {
+		if !recorder.used[i] {
+			unused = append(unused, interaction.Request.Method+" "+interaction.Request.Path)
+		}
+	}
+	if len(unused) > 0 {
+		return fmt.Errorf("forcetest: %d recorded requests were not replayed: %v", len(unused), strings.Join(unused, ", "))
+	}
+
+	return nil
+}
+
+// collectSecrets remembers the values of secret keys in a query and body so
+// that they are scrubbed wherever else they appear, such as an access token
+// that was issued by one response and sent in the headers of the next. The
+// instance urls of token responses are scrubbed like the hosts requests were
+// sent to.
+func (recorder *Recorder) collectSecrets(query, contentType, body string) {
+	collect := func(key, value string) {
+		if secretKeys[key] && len(value) >= minSecretLength {
+			recorder.secrets[value] = redacted
+		}
+		if key == "instance_url" && value != "" {
+			recorder.origins[strings.TrimSuffix(value, "/")] = true
+		}
+	}
+
+	if values, err := url.ParseQuery(query); err == nil {
+		for key := range values {
+			collect(key, values.Get(key))
+		}
+	}
+
+	switch mediaType(contentType) {
+	case "application/x-www-form-urlencoded":
+		if values, err := url.ParseQuery(body); err == nil {
+			for key := range values {
+				collect(key, values.Get(key))
+			}
+		}
+	case "application/json":
+		var decoded interface{}
+		if json.Unmarshal([]byte(body), &decoded) == nil {
+			walkJSON(decoded, func(key string, value interface{}) interface{} {
+				if s, ok := value.(string); ok {
+					collect(key, s)
+				}
+				return value
+			})
+		}
+	}
+}
+
+// normalizeQuery sorts the parameters of a query and redacts secrets.
+func normalizeQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return query
+	}
+	for key := range values {
+		if secretKeys[key] {
+			values[key] = []string{redacted}
+		}
+	}
+
+	return values.Encode()
+}
+
+// normalizeBody returns a body with secrets redacted and, for JSON and form
+// bodies, keys sorted, so that equivalent bodies compare equal.
+func normalizeBody(contentType, body string) string {
+	switch mediaType(contentType) {
+	case "application/x-www-form-urlencoded":
+		return normalizeQuery(body)
+	case "application/json":
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+			return body
+		}
+		decoded = walkJSON(decoded, func(key string, value interface{}) interface{} {
+			if _, ok := value.(string); ok && secretKeys[key] {
+				return redacted
+			}
+			return value
+		})
+
+		encoded, err := json.Marshal(decoded)
+		if err != nil {
+			return body
+		}
+		return string(encoded)
+	}
+
+	return body
+}
+
+// walkJSON calls visit with every key and value of the objects in a decoded
+// JSON value, replacing the values with those returned.
+func walkJSON(value interface{}, visit func(key string, value interface{}) interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			v[key] = visit(key, walkJSON(inner, visit))
+		}
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = walkJSON(inner, visit)
+		}
+	}
+
+	return value
+}
+
+func mediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
+}
+
+// sortReplacements orders old, new pairs by decreasing length of old, so
+// that a secret containing another is replaced whole.
+func sortReplacements(pairs []string) {
+	type pair struct{ old, new string }
+	sorted := make([]pair, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		sorted = append(sorted, pair{pairs[i], pairs[i+1]})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].old) != len(sorted[j].old) {
+			return len(sorted[i].old) > len(sorted[j].old)
+		}
+		return sorted[i].old < sorted[j].old
+	})
+	for i, p := range sorted {
+		pairs[2*i], pairs[2*i+1] = p.old, p.new
+	}
+}
+
+// readBody reads and replaces body so that it can be read again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+
+	contents, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading body: %v", err)
+	}
+	*body = ioutil.NopCloser(bytes.NewReader(contents))
+
+	return contents, nil
+}
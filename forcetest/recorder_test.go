@@ -0,0 +1,174 @@
+package forcetest_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nimajalali/go-force/force"
+	"github.com/nimajalali/go-force/forcetest"
+	"github.com/nimajalali/go-force/sobjects"
+)
+
+// account names its fields in force tags, as the fields sent on insert must be.
+type account struct {
+	Name string `force:"Name,omitempty"`
+}
+
+func (a *account) ApiName() string {
+	return "Account"
+}
+
+func (a *account) ExternalIdApiName() string {
+	return ""
+}
+
+type accountQueryResponse struct {
+	sobjects.BaseQuery
+	Records []sobjects.Account `force:"records"`
+}
+
+// session logs in through recorder and creates and queries an account,
+// returning the names found.
+func session(t *testing.T, recorder *forcetest.Recorder, tokenUrl, password string) ([]string, *force.ForceApi) {
+	client := &http.Client{Transport: recorder}
+	resp, err := client.PostForm(tokenUrl, url.Values{
+		"grant_type":    {"password"},
+		"client_id":     {"client"},
+		"client_secret": {"client-secret-value"},
+		"username":      {"user@example.com"},
+		"password":      {password},
+	})
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	defer resp.Body.Close()
+
+	token := map[string]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		t.Fatalf("Failed to decode token: %v", err)
+	}
+
+	forceApi, err := force.CreateWithAccessToken("v36.0", "client", token["access_token"], token["instance_url"],
+		force.WithMiddleware(recorder.Middleware))
+	if err != nil {
+		t.Fatalf("Failed to create api: %v", err)
+	}
+
+	if _, err := forceApi.InsertSObject(&account{Name: "Acme"}, nil); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	list := &accountQueryResponse{}
+	if err := forceApi.Query("SELECT Id, Name FROM Account ORDER BY Name", list); err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+
+	names := []string{}
+	for _, record := range list.Records {
+		names = append(names, record.Name)
+	}
+
+	return names, forceApi
+}
+
+func TestRecorder(t *testing.T) {
+	server := forcetest.NewServer(forcetest.SObject{
+		Name:      "Account",
+		KeyPrefix: "001",
+		Fields:    []forcetest.Field{{Name: "Name"}, {Name: "BillingCity", Nillable: true}},
+	})
+	server.Seed("Account", forcetest.Record{"Name": "Globex"})
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	recorder, err := forcetest.NewRecorder(path, forcetest.ModeRecord)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	recorder.Redact("user@example.com", "user@forcetest.invalid")
+
+	recorded, forceApi := session(t, recorder, server.TokenURL(), "password-value")
+	if strings.Join(recorded, ",") != "Acme,Globex" {
+		t.Fatalf("Unexpected records: %v", recorded)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to save recording: %v", err)
+	}
+	server.Close()
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read recording: %v", err)
+	}
+	for _, secret := range []string{forceApi.GetAccessToken(), server.URL, strings.TrimPrefix(server.URL, "http://"),
+		"password-value", "client-secret-value", "user@example.com", "Authorization"} {
+		if strings.Contains(string(contents), secret) {
+			t.Errorf("Recording contains %q:\n%s", secret, contents)
+		}
+	}
+	if !strings.Contains(string(contents), "https://forcetest.invalid") {
+		t.Errorf("Expected instance url to be replaced:\n%s", contents)
+	}
+
+	// Replay with other credentials; the server is closed, so every request
+	// must be answered from the recording.
+	replayer, err := forcetest.NewRecorder(path, forcetest.ModeReplay)
+	if err != nil {
+		t.Fatalf("Failed to load recording: %v", err)
+	}
+
+	replayed, forceApi := session(t, replayer, "https://login.salesforce.com/services/oauth2/token", "other-password")
+	if strings.Join(replayed, ",") != strings.Join(recorded, ",") {
+		t.Fatalf("Expected replayed records %v, got %v", recorded, replayed)
+	}
+	if err := replayer.Close(); err != nil {
+		t.Fatalf("Expected every interaction to be replayed: %v", err)
+	}
+
+	err = forceApi.Query("SELECT Id FROM Account", &accountQueryResponse{})
+	if err == nil || !strings.Contains(err.Error(), "unexpected request GET /services/data/v36.0/query?q=SELECT+Id+FROM+Account") {
+		t.Fatalf("Expected unexpected request to fail, got %v", err)
+	}
+
+	// Identical requests replay in order, each at most once.
+	err = forceApi.Query("SELECT Id, Name FROM Account ORDER BY Name", &accountQueryResponse{})
+	if err == nil || !strings.Contains(err.Error(), "unexpected request") {
+		t.Fatalf("Expected replayed request not to be replayed twice, got %v", err)
+	}
+}
+
+func TestRecorderReportsUnusedInteractions(t *testing.T) {
+	server := forcetest.NewServer()
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	recorder, err := forcetest.NewRecorder(path, forcetest.ModeRecord)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	client := &http.Client{Transport: recorder}
+	resp, err := client.Get(server.URL + "/services/data")
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to save recording: %v", err)
+	}
+
+	replayer, err := forcetest.NewRecorder(path, forcetest.ModeReplay)
+	if err != nil {
+		t.Fatalf("Failed to load recording: %v", err)
+	}
+	if err := replayer.Close(); err == nil || !strings.Contains(err.Error(), "GET /services/data") {
+		t.Fatalf("Expected unused interaction to be reported, got %v", err)
+	}
+
+	if _, err := forcetest.NewRecorder(filepath.Join(t.TempDir(), "missing.json"), forcetest.ModeReplay); err == nil {
+		t.Fatal("Expected missing recording to fail")
+	}
+}
@@ -0,0 +1,1045 @@
+// Package forcetest provides an in-memory fake of the Salesforce REST API for
+// tests that must run without network access. A Server implements the OAuth
+// token endpoint, resource discovery, sobject describes, CRUD by Id and by
+// external Id and basic SOQL over the records it holds.
+//
+//	server := forcetest.NewServer(forcetest.SObject{
+//		Name:      "Account",
+//		KeyPrefix: "001",
+//		Fields:    []forcetest.Field{{Name: "Name"}, {Name: "Industry", Type: "picklist"}},
+//	})
+//	defer server.Close()
+//	server.Seed("Account", forcetest.Record{"Name": "Acme"})
+//
+//	forceApi, err := force.CreateWithAccessToken("v36.0", "client", server.AccessToken(), server.URL)
+//
+// Any credentials are accepted by the token endpoint, which signs tokens with
+// the client_secret it is sent. Access tokens can also be revoked and
+// introspected, and the user they belong to is served by the userinfo
+// endpoint and the identity url. Records hold the values as decoded from JSON:
+// strings, float64 numbers, booleans and nil.
+//
+// Tests that need the behavior of a real org can use a Recorder instead,
+// which records interactions with a sandbox once and replays them after.
+package forcetest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	tokenPath      = "/services/oauth2/token"
+	revokePath     = "/services/oauth2/revoke"
+	introspectPath = "/services/oauth2/introspect"
+	userInfoPath   = "/services/oauth2/userinfo"
+	dataPath       = "/services/data"
+
+	// Format of datetime values, as Salesforce returns them.
+	timeFormat = "2006-01-02T15:04:05.000-0700"
+
+	// The org and user every session belongs to.
+	userId   = "005000000000001"
+	orgId    = "00D000000000001"
+	username = "user@forcetest.example"
+
+	// Identity url of the user, relative to the server.
+	identityPath = "/id/" + orgId + "/" + userId
+
+	defaultQueryBatchSize = 2000
+	dailyApiRequests      = 15000
+)
+
+var versionPattern = regexp.MustCompile(`^v\d+\.\d+$`)
+
+// Fields every sobject has. They are added to the definition of each SObject
+// unless it declares them itself.
+var systemFields = []Field{
+	{Name: "Id", Type: "id", ReadOnly: true},
+	{Name: "IsDeleted", Type: "boolean", ReadOnly: true},
+	{Name: "CreatedDate", Type: "datetime", ReadOnly: true},
+	{Name: "CreatedById", Type: "reference", ReferenceTo: []string{"User"}, ReadOnly: true},
+	{Name: "LastModifiedDate", Type: "datetime", ReadOnly: true},
+	{Name: "LastModifiedById", Type: "reference", ReferenceTo: []string{"User"}, ReadOnly: true},
+	{Name: "SystemModstamp", Type: "datetime", ReadOnly: true},
+}
+
+// SObject defines an object type served by a Server.
+type SObject struct {
+	Name string
+	// Label defaults to Name.
+	Label string
+	// KeyPrefix is the prefix of generated record ids, such as "001" for
+	// accounts. A prefix is assigned when it is empty.
+	KeyPrefix string
+	Custom    bool
+	Fields    []Field
+}
+
+// Field defines a field of an SObject.
+type Field struct {
+	Name string
+	// Type is a describe field type such as "string", "boolean", "double",
+	// "datetime", "picklist" or "reference". It defaults to "string".
+	Type     string
+	Label    string
+	Nillable bool
+	// ExternalId allows upserting, getting and deleting records by the value
+	// of the field.
+	ExternalId bool
+	// ReferenceTo and RelationshipName describe a reference field. Parent
+	// fields can be selected through the relationship name in SOQL.
+	ReferenceTo      []string
+	RelationshipName string
+	// PicklistValues are the active values of a picklist field.
+	PicklistValues []string
+	// ReadOnly fields can only be set through Seed.
+	ReadOnly bool
+}
+
+// Record is a record of an SObject, mapping field names to values.
+type Record map[string]interface{}
+
+type sobject struct {
+	SObject
+	fields  map[string]*Field
+	records map[string]Record
+	ids     []string
+}
+
+// field returns the field named name, which is case-insensitive as in the
+// real API.
+func (object *sobject) field(name string) *Field {
+	return object.fields[strings.ToLower(name)]
+}
+
+// relationship returns the reference field whose relationship is named name.
+func (object *sobject) relationship(name string) *Field {
+	for i := range object.Fields {
+		field := &object.Fields[i]
+		if field.RelationshipName != "" && strings.EqualFold(field.RelationshipName, name) {
+			return field
+		}
+	}
+
+	return nil
+}
+
+// Server is a fake Salesforce instance listening on a local address.
+type Server struct {
+	// URL is the instance url, such as http://127.0.0.1:1234.
+	URL string
+
+	server *httptest.Server
+
+	mu             sync.Mutex
+	objects        map[string]*sobject
+	tokens         map[string]bool
+	accessToken    string
+	issued         int
+	ids            int
+	cursors        map[string]*cursor
+	queryBatchSize int
+	apiRequests    int
+}
+
+// NewServer starts a Server serving objects. The caller should call Close
+// when finished.
+func NewServer(objects ...SObject) *Server {
+	s := &Server{
+		objects:        map[string]*sobject{},
+		tokens:         map[string]bool{},
+		cursors:        map[string]*cursor{},
+		queryBatchSize: defaultQueryBatchSize,
+	}
+	for _, object := range objects {
+		s.AddSObject(object)
+	}
+	s.issueToken()
+
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// TokenURL returns the url of the OAuth token endpoint.
+func (s *Server) TokenURL() string {
+	return s.URL + tokenPath
+}
+
+// AccessToken returns a valid access token.
+func (s *Server) AccessToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.tokens[s.accessToken] {
+		s.issueToken()
+	}
+
+	return s.accessToken
+}
+
+// ExpireSessions invalidates every access token issued so far. Requests made
+// with them fail with INVALID_SESSION_ID until the client logs in again.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = map[string]bool{}
+}
+
+// SetQueryBatchSize sets the number of records returned per query page when
+// the request does not ask for a batch size.
+func (s *Server) SetQueryBatchSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queryBatchSize = size
+}
+
+// AddSObject adds or replaces the definition of an object type, dropping its
+// records.
+func (s *Server) AddSObject(object SObject) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if object.Label == "" {
+		object.Label = object.Name
+	}
+	if object.KeyPrefix == "" {
+		object.KeyPrefix = fmt.Sprintf("a%02d", len(s.objects))
+	}
+
+	fields := append([]Field{}, object.Fields...)
+	declared := map[string]bool{}
+	for _, field := range fields {
+		declared[strings.ToLower(field.Name)] = true
+	}
+	for _, field := range systemFields {
+		if !declared[strings.ToLower(field.Name)] {
+			fields = append(fields, field)
+		}
+	}
+
+	object.Fields = fields
+	compiled := &sobject{
+		SObject: object,
+		fields:  map[string]*Field{},
+		records: map[string]Record{},
+	}
+	for i := range object.Fields {
+		field := &object.Fields[i]
+		if field.Type == "" {
+			field.Type = "string"
+		}
+		if field.Label == "" {
+			field.Label = field.Name
+		}
+		compiled.fields[strings.ToLower(field.Name)] = field
+	}
+
+	s.objects[strings.ToLower(object.Name)] = compiled
+}
+
+// Seed inserts records into the object type name, bypassing the checks made
+// on api requests so that read-only fields such as Id and CreatedDate can be
+// set. It returns the ids of the records, generating those that are missing,
+// and panics if the object type or a field is not defined.
+func (s *Server) Seed(name string, records ...Record) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object := s.objects[strings.ToLower(name)]
+	if object == nil {
+		panic(fmt.Sprintf("forcetest: unknown sobject %v", name))
+	}
+
+	ids := make([]string, len(records))
+	for i, record := range records {
+		values := Record{}
+		for key, value := range record {
+			field := object.field(key)
+			if field == nil {
+				panic(fmt.Sprintf("forcetest: unknown field %v.%v", name, key))
+			}
+			values[field.Name] = value
+		}
+
+		ids[i] = s.insert(object, values)
+	}
+
+	return ids
+}
+
+// Records returns copies of the records of the object type name, including
+// deleted ones, in insertion order.
+func (s *Server) Records(name string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object := s.objects[strings.ToLower(name)]
+	if object == nil {
+		return nil
+	}
+
+	records := make([]Record, 0, len(object.ids))
+	for _, id := range object.ids {
+		records = append(records, copyRecord(object.records[id]))
+	}
+
+	return records
+}
+
+// Record returns a copy of the record of the object type name with the given
+// id, if it exists and is not deleted.
+func (s *Server) Record(name, id string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	object := s.objects[strings.ToLower(name)]
+	if object == nil {
+		return nil, false
+	}
+
+	record, ok := object.records[id]
+	if !ok || record["IsDeleted"] == true {
+		return nil, false
+	}
+
+	return copyRecord(record), true
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.URL.Path == tokenPath:
+		s.serveToken(w, r)
+	case r.URL.Path == revokePath:
+		s.serveRevoke(w, r)
+	case r.URL.Path == introspectPath:
+		s.serveIntrospect(w, r)
+	case r.URL.Path == userInfoPath || r.URL.Path == identityPath:
+		if !s.authorized(r) {
+			// Unlike the api, the identity endpoints answer in plain text.
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, "Bad_OAuth_Token")
+			return
+		}
+
+		s.serveIdentity(w, r)
+	case r.URL.Path == dataPath || r.URL.Path == dataPath+"/":
+		s.serveVersions(w)
+	case strings.HasPrefix(r.URL.Path, dataPath+"/"):
+		if !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, "INVALID_SESSION_ID", "Session expired or invalid")
+			return
+		}
+
+		s.apiRequests++
+		w.Header().Set("Sforce-Limit-Info", fmt.Sprintf("api-usage=%d/%d", s.apiRequests, dailyApiRequests))
+		s.serveData(w, r, strings.Split(strings.TrimPrefix(r.URL.Path, dataPath+"/"), "/"))
+	default:
+		writeNotFound(w)
+	}
+}
+
+func (s *Server) issueToken() string {
+	s.issued++
+	s.accessToken = fmt.Sprintf("%v!forcetest-token-%d", orgId, s.issued)
+	s.tokens[s.accessToken] = true
+
+	return s.accessToken
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.tokens[token]
+}
+
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "HTTP Method '"+r.Method+"' not allowed. Allowed are POST")
+		return
+	}
+
+	grant := r.PostFormValue("grant_type")
+	switch grant {
+	case "password", "refresh_token", "client_credentials", "authorization_code",
+		"urn:ietf:params:oauth:grant-type:jwt-bearer":
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{
+			"error":             "unsupported_grant_type",
+			"error_description": "grant type not supported",
+		})
+		return
+	}
+
+	token := map[string]string{
+		"access_token": s.issueToken(),
+		"instance_url": s.URL,
+		"id":           s.URL + identityPath,
+		"token_type":   "Bearer",
+		"issued_at":    strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10),
+	}
+
+	// Like Salesforce, sign the id and issued_at with the consumer secret.
+	mac := hmac.New(sha256.New, []byte(r.PostFormValue("client_secret")))
+	mac.Write([]byte(token["id"] + token["issued_at"]))
+	token["signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if grant == "authorization_code" {
+		token["refresh_token"] = fmt.Sprintf("forcetest-refresh-%d", s.issued)
+	}
+
+	writeJSON(w, token)
+}
+
+func (s *Server) serveRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "HTTP Method '"+r.Method+"' not allowed. Allowed are POST")
+		return
+	}
+
+	token := r.PostFormValue("token")
+	if !s.tokens[token] {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{
+			"error":             "invalid_token",
+			"error_description": "invalid token",
+		})
+		return
+	}
+
+	delete(s.tokens, token)
+}
+
+func (s *Server) serveIntrospect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "HTTP Method '"+r.Method+"' not allowed. Allowed are POST")
+		return
+	}
+
+	clientId := r.PostFormValue("client_id")
+	if clientId == "" || r.PostFormValue("client_secret") == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, map[string]string{
+			"error":             "invalid_client",
+			"error_description": "invalid client credentials",
+		})
+		return
+	}
+
+	if !s.tokens[r.PostFormValue("token")] {
+		writeJSON(w, map[string]interface{}{"active": false})
+		return
+	}
+
+	now := time.Now().Unix()
+	writeJSON(w, map[string]interface{}{
+		"active":     true,
+		"scope":      "api refresh_token",
+		"client_id":  clientId,
+		"username":   username,
+		"sub":        s.URL + identityPath,
+		"token_type": "access_token",
+		"exp":        now + 7200,
+		"iat":        now,
+		"nbf":        now,
+	})
+}
+
+// serveIdentity serves both the userinfo endpoint and the identity url, which
+// describe the user in slightly different formats.
+func (s *Server) serveIdentity(w http.ResponseWriter, r *http.Request) {
+	urls := map[string]string{}
+	for name, path := range map[string]string{
+		"rest":     "/",
+		"sobjects": "/sobjects/",
+		"query":    "/query/",
+		"search":   "/search/",
+		"recent":   "/recent/",
+	} {
+		urls[name] = s.URL + dataPath + "/v{version}" + path
+	}
+	urls["profile"] = s.URL + "/" + userId
+	urls["custom_domain"] = s.URL
+
+	identity := map[string]interface{}{
+		"user_id":         userId,
+		"organization_id": orgId,
+		"email":           username,
+		"email_verified":  true,
+		"locale":          "en_US",
+		"language":        "en_US",
+		"utcOffset":       -28800000,
+		"user_type":       "STANDARD",
+		"active":          true,
+		"urls":            urls,
+	}
+	if r.URL.Path == userInfoPath {
+		identity["sub"] = s.URL + identityPath
+		identity["preferred_username"] = username
+		identity["nickname"] = "user"
+		identity["name"] = "Forcetest User"
+		identity["given_name"] = "Forcetest"
+		identity["family_name"] = "User"
+		identity["zoneinfo"] = "America/Los_Angeles"
+	} else {
+		identity["id"] = s.URL + identityPath
+		identity["username"] = username
+		identity["nick_name"] = "user"
+		identity["display_name"] = "Forcetest User"
+		identity["first_name"] = "Forcetest"
+		identity["last_name"] = "User"
+		identity["timezone"] = "America/Los_Angeles"
+		identity["asserted_user"] = true
+	}
+
+	writeJSON(w, identity)
+}
+
+func (s *Server) serveVersions(w http.ResponseWriter) {
+	writeJSON(w, []map[string]string{{
+		"label":   "Fake",
+		"url":     dataPath + "/v36.0",
+		"version": "36.0",
+	}})
+}
+
+// serveData routes requests below /services/data, where path starts with the
+// version.
+func (s *Server) serveData(w http.ResponseWriter, r *http.Request, path []string) {
+	version := path[0]
+	if !versionPattern.MatchString(version) {
+		writeNotFound(w)
+		return
+	}
+	base := dataPath + "/" + version
+
+	if len(path) == 1 {
+		writeJSON(w, map[string]string{
+			"sobjects": base + "/sobjects",
+			"query":    base + "/query",
+			"queryAll": base + "/queryAll",
+			"limits":   base + "/limits",
+		})
+		return
+	}
+
+	switch path[1] {
+	case "sobjects":
+		s.serveSObjects(w, r, base, path[2:])
+	case "query", "queryAll":
+		if r.Method != http.MethodGet {
+			writeNotFound(w)
+			return
+		}
+		if len(path) == 3 {
+			s.serveQueryMore(w, base, path[2])
+			return
+		}
+		s.serveQuery(w, r, base, path[1] == "queryAll")
+	case "limits":
+		writeJSON(w, map[string]interface{}{
+			"DailyApiRequests": map[string]int{"Max": dailyApiRequests, "Remaining": dailyApiRequests - s.apiRequests},
+			"DataStorageMB":    map[string]int{"Max": 5, "Remaining": 5},
+		})
+	default:
+		writeNotFound(w)
+	}
+}
+
+func (s *Server) serveSObjects(w http.ResponseWriter, r *http.Request, base string, path []string) {
+	if len(path) == 0 {
+		names := make([]string, 0, len(s.objects))
+		for name := range s.objects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		list := []interface{}{}
+		for _, name := range names {
+			list = append(list, s.metadata(base, s.objects[name]))
+		}
+
+		writeJSON(w, map[string]interface{}{
+			"encoding":     "UTF-8",
+			"maxBatchSize": 200,
+			"sobjects":     list,
+		})
+		return
+	}
+
+	object := s.objects[strings.ToLower(path[0])]
+	if object == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "The requested resource does not exist")
+		return
+	}
+
+	switch {
+	case len(path) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, map[string]interface{}{
+			"objectDescribe": s.metadata(base, object),
+			"recentItems":    []interface{}{},
+		})
+	case len(path) == 1 && r.Method == http.MethodPost:
+		s.serveCreate(w, r, object)
+	case len(path) == 2 && path[1] == "describe" && r.Method == http.MethodGet:
+		writeJSON(w, s.describe(base, object))
+	case len(path) == 2:
+		s.serveRecord(w, r, base, object, object.records[path[1]])
+	case len(path) == 3:
+		s.serveExternalId(w, r, base, object, path[1], path[2])
+	default:
+		writeNotFound(w)
+	}
+}
+
+func (s *Server) serveCreate(w http.ResponseWriter, r *http.Request, object *sobject) {
+	values, ok := s.decodeValues(w, r, object)
+	if !ok {
+		return
+	}
+
+	id := s.insert(object, values)
+
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, map[string]interface{}{"id": id, "success": true, "errors": []interface{}{}})
+}
+
+// serveRecord serves the record with an Id, which is nil if it does not
+// exist.
+func (s *Server) serveRecord(w http.ResponseWriter, r *http.Request, base string, object *sobject, record Record) {
+	if record == nil || (record["IsDeleted"] == true && r.Method != http.MethodDelete) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "The requested resource does not exist")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		fields := []string{}
+		if param := r.URL.Query().Get("fields"); param != "" {
+			fields = strings.Split(param, ",")
+		} else {
+			for _, field := range object.Fields {
+				fields = append(fields, field.Name)
+			}
+		}
+
+		rendered, err := s.render(base, object, record, fields)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_FIELD", err.Error())
+			return
+		}
+		writeJSON(w, rendered)
+	case http.MethodPatch:
+		values, ok := s.decodeValues(w, r, object)
+		if !ok {
+			return
+		}
+		s.update(record, values)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if record["IsDeleted"] == true {
+			writeError(w, http.StatusNotFound, "ENTITY_IS_DELETED", "entity is deleted")
+			return
+		}
+		record["IsDeleted"] = true
+		s.touch(record)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeNotFound(w)
+	}
+}
+
+// serveExternalId serves the records whose external id field name equals
+// value, upserting on PATCH.
+func (s *Server) serveExternalId(w http.ResponseWriter, r *http.Request, base string, object *sobject, name, value string) {
+	field := object.field(name)
+	if field == nil || !(field.ExternalId || field.Name == "Id") {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Provided external ID field does not exist or is not accessible: %v", name))
+		return
+	}
+
+	matches := []Record{}
+	for _, id := range object.ids {
+		record := object.records[id]
+		if record["IsDeleted"] != true && equalValues(field, record[field.Name], value) {
+			matches = append(matches, record)
+		}
+	}
+
+	if len(matches) > 1 {
+		urls := make([]string, len(matches))
+		for i, record := range matches {
+			urls[i] = recordUrl(base, object, record)
+		}
+		w.WriteHeader(http.StatusMultipleChoices)
+		writeJSON(w, urls)
+		return
+	}
+
+	if r.Method != http.MethodPatch || field.Name == "Id" {
+		var record Record
+		if len(matches) == 1 {
+			record = matches[0]
+		}
+		s.serveRecord(w, r, base, object, record)
+		return
+	}
+
+	values, ok := s.decodeValues(w, r, object)
+	if !ok {
+		return
+	}
+
+	if len(matches) == 1 {
+		s.update(matches[0], values)
+		writeJSON(w, map[string]interface{}{"id": matches[0]["Id"], "success": true, "errors": []interface{}{}, "created": false})
+		return
+	}
+
+	values[field.Name] = value
+	id := s.insert(object, values)
+
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, map[string]interface{}{"id": id, "success": true, "errors": []interface{}{}, "created": true})
+}
+
+// decodeValues reads the field values of a create or update request, writing
+// an error response if they are not valid.
+func (s *Server) decodeValues(w http.ResponseWriter, r *http.Request, object *sobject) (Record, bool) {
+	body := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "JSON_PARSER_ERROR", fmt.Sprintf("Unable to parse request body: %v", err))
+		return nil, false
+	}
+
+	values := Record{}
+	for key, value := range body {
+		if key == "attributes" {
+			continue
+		}
+
+		field := object.field(key)
+		if field == nil {
+			writeError(w, http.StatusBadRequest, "INVALID_FIELD", fmt.Sprintf("No such column '%v' on sobject of type %v", key, object.Name), key)
+			return nil, false
+		}
+		if field.ReadOnly {
+			writeError(w, http.StatusBadRequest, "INVALID_FIELD_FOR_INSERT_UPDATE", fmt.Sprintf("Unable to create/update fields: %v. Please check the security settings of this field and verify that it is read/write for your profile or permission set.", field.Name), field.Name)
+			return nil, false
+		}
+		values[field.Name] = value
+	}
+
+	return values, true
+}
+
+// insert adds a record with values, filling in the system fields that are not
+// set.
+func (s *Server) insert(object *sobject, values Record) string {
+	now := time.Now().UTC().Format(timeFormat)
+	record := Record{
+		"IsDeleted":        false,
+		"CreatedDate":      now,
+		"CreatedById":      userId,
+		"LastModifiedDate": now,
+		"LastModifiedById": userId,
+		"SystemModstamp":   now,
+	}
+	for key, value := range values {
+		record[key] = value
+	}
+
+	id, _ := record["Id"].(string)
+	if id == "" {
+		s.ids++
+		id = fmt.Sprintf("%v%012d", object.KeyPrefix, s.ids)
+		record["Id"] = id
+	}
+
+	if _, ok := object.records[id]; !ok {
+		object.ids = append(object.ids, id)
+	}
+	object.records[id] = record
+
+	return id
+}
+
+func (s *Server) update(record, values Record) {
+	for key, value := range values {
+		record[key] = value
+	}
+	s.touch(record)
+}
+
+func (s *Server) touch(record Record) {
+	now := time.Now().UTC().Format(timeFormat)
+	record["LastModifiedDate"] = now
+	record["LastModifiedById"] = userId
+	record["SystemModstamp"] = now
+}
+
+// render returns the fields of record as the api returns them. A field may be
+// a path through parent relationships, such as "Account.Owner.Name".
+func (s *Server) render(base string, object *sobject, record Record, fields []string) (map[string]interface{}, error) {
+	rendered := map[string]interface{}{
+		"attributes": map[string]string{"type": object.Name, "url": recordUrl(base, object, record)},
+	}
+
+	for _, path := range fields {
+		if err := s.renderPath(base, object, record, rendered, strings.Split(strings.TrimSpace(path), ".")); err != nil {
+			return nil, err
+		}
+	}
+
+	return rendered, nil
+}
+
+func (s *Server) renderPath(base string, object *sobject, record Record, rendered map[string]interface{}, path []string) error {
+	if len(path) == 1 {
+		field := object.field(path[0])
+		if field == nil {
+			return fmt.Errorf("No such column '%v' on entity '%v'", path[0], object.Name)
+		}
+		rendered[field.Name] = record[field.Name]
+		return nil
+	}
+
+	reference := object.relationship(path[0])
+	if reference == nil {
+		return fmt.Errorf("Didn't understand relationship '%v' in field path", path[0])
+	}
+
+	parentObject, parent := s.parent(reference, record)
+	if parent == nil {
+		if _, ok := rendered[reference.RelationshipName]; !ok {
+			rendered[reference.RelationshipName] = nil
+		}
+		return s.checkPath(reference, path[1:])
+	}
+
+	nested, ok := rendered[reference.RelationshipName].(map[string]interface{})
+	if !ok {
+		nested = map[string]interface{}{
+			"attributes": map[string]string{"type": parentObject.Name, "url": recordUrl(base, parentObject, parent)},
+		}
+		rendered[reference.RelationshipName] = nested
+	}
+
+	return s.renderPath(base, parentObject, parent, nested, path[1:])
+}
+
+// checkPath reports whether path names a field of one of the objects
+// reference points to.
+func (s *Server) checkPath(reference *Field, path []string) error {
+	for _, name := range reference.ReferenceTo {
+		object := s.objects[strings.ToLower(name)]
+		if object == nil {
+			continue
+		}
+		if len(path) == 1 && object.field(path[0]) != nil {
+			return nil
+		}
+		if len(path) > 1 {
+			if next := object.relationship(path[0]); next != nil {
+				return s.checkPath(next, path[1:])
+			}
+		}
+	}
+
+	return fmt.Errorf("No such column '%v' on entity '%v'", strings.Join(path, "."), strings.Join(reference.ReferenceTo, ","))
+}
+
+// parent returns the record reference points to from record, if any.
+func (s *Server) parent(reference *Field, record Record) (*sobject, Record) {
+	id, _ := record[reference.Name].(string)
+	if id == "" {
+		return nil, nil
+	}
+
+	for _, name := range reference.ReferenceTo {
+		object := s.objects[strings.ToLower(name)]
+		if object == nil {
+			continue
+		}
+		if parent, ok := object.records[id]; ok {
+			return object, parent
+		}
+	}
+
+	return nil, nil
+}
+
+// value returns the value at path from record, following parent
+// relationships, along with the field it belongs to.
+func (s *Server) value(object *sobject, record Record, path []string) (interface{}, *Field, error) {
+	if len(path) == 1 {
+		field := object.field(path[0])
+		if field == nil {
+			return nil, nil, fmt.Errorf("No such column '%v' on entity '%v'", path[0], object.Name)
+		}
+		return record[field.Name], field, nil
+	}
+
+	reference := object.relationship(path[0])
+	if reference == nil {
+		return nil, nil, fmt.Errorf("Didn't understand relationship '%v' in field path", path[0])
+	}
+
+	parentObject, parent := s.parent(reference, record)
+	if parent == nil {
+		return nil, &Field{Type: "string"}, s.checkPath(reference, path[1:])
+	}
+
+	return s.value(parentObject, parent, path[1:])
+}
+
+func (s *Server) metadata(base string, object *sobject) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         object.Name,
+		"label":        object.Label,
+		"labelPlural":  object.Label,
+		"keyPrefix":    object.KeyPrefix,
+		"custom":       object.Custom,
+		"createable":   true,
+		"updateable":   true,
+		"deletable":    true,
+		"queryable":    true,
+		"retrieveable": true,
+		"urls":         objectUrls(base, object),
+	}
+}
+
+func (s *Server) describe(base string, object *sobject) map[string]interface{} {
+	description := s.metadata(base, object)
+
+	fields := []interface{}{}
+	for _, field := range object.Fields {
+		picklistValues := []interface{}{}
+		for _, value := range field.PicklistValues {
+			picklistValues = append(picklistValues, map[string]interface{}{
+				"value":  value,
+				"label":  value,
+				"active": true,
+			})
+		}
+
+		referenceTo := field.ReferenceTo
+		if referenceTo == nil {
+			referenceTo = []string{}
+		}
+
+		fields = append(fields, map[string]interface{}{
+			"name":             field.Name,
+			"label":            field.Label,
+			"type":             field.Type,
+			"nillable":         field.Nillable,
+			"createable":       !field.ReadOnly,
+			"updateable":       !field.ReadOnly,
+			"externalId":       field.ExternalId,
+			"idLookup":         field.ExternalId || field.Name == "Id",
+			"custom":           strings.HasSuffix(field.Name, "__c"),
+			"referenceTo":      referenceTo,
+			"relationshipName": nilIfEmpty(field.RelationshipName),
+			"picklistValues":   picklistValues,
+			"filterable":       true,
+			"sortable":         true,
+		})
+	}
+	description["fields"] = fields
+
+	childRelationships := []interface{}{}
+	names := make([]string, 0, len(s.objects))
+	for name := range s.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, field := range s.objects[name].Fields {
+			for _, parent := range field.ReferenceTo {
+				if strings.EqualFold(parent, object.Name) {
+					childRelationships = append(childRelationships, map[string]interface{}{
+						"childSObject": s.objects[name].Name,
+						"field":        field.Name,
+					})
+				}
+			}
+		}
+	}
+	description["childRelationships"] = childRelationships
+
+	return description
+}
+
+func objectUrls(base string, object *sobject) map[string]string {
+	uri := base + "/sobjects/" + object.Name
+	return map[string]string{
+		"sobject":     uri,
+		"describe":    uri + "/describe",
+		"rowTemplate": uri + "/{ID}",
+	}
+}
+
+func recordUrl(base string, object *sobject, record Record) string {
+	return fmt.Sprintf("%v/sobjects/%v/%v", base, object.Name, record["Id"])
+}
+
+func copyRecord(record Record) Record {
+	copied := make(Record, len(record))
+	for key, value := range record {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+func nilIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response in the format of the api.
+func writeError(w http.ResponseWriter, status int, code, message string, fields ...string) {
+	apiError := map[string]interface{}{"errorCode": code, "message": message}
+	if len(fields) > 0 {
+		apiError["fields"] = fields
+	}
+
+	w.WriteHeader(status)
+	writeJSON(w, []interface{}{apiError})
+}
+
+func writeNotFound(w http.ResponseWriter) {
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "The requested resource does not exist")
+}
@@ -0,0 +1,251 @@
+package forcetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *Server {
+	server := NewServer(
+		SObject{
+			Name:      "Account",
+			KeyPrefix: "001",
+			Fields: []Field{
+				{Name: "Name"},
+				{Name: "Industry", Type: "picklist", PicklistValues: []string{"Banking", "Retail"}, Nillable: true},
+				{Name: "NumberOfEmployees", Type: "int", Nillable: true},
+			},
+		},
+		SObject{
+			Name:   "Invoice__c",
+			Custom: true,
+			Fields: []Field{
+				{Name: "Name"},
+				{Name: "External_Id__c", ExternalId: true},
+				{Name: "Amount__c", Type: "currency", Nillable: true},
+				{Name: "Due_Date__c", Type: "date", Nillable: true},
+				{Name: "Account__c", Type: "reference", ReferenceTo: []string{"Account"}, RelationshipName: "Account__r"},
+			},
+		},
+	)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// do sends a request with a valid session and decodes the response into out.
+func do(t *testing.T, server *Server, method, path, body string, out interface{}) int {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+server.AccessToken())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("Failed to decode %v %v response: %v", method, path, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func TestToken(t *testing.T) {
+	server := newTestServer(t)
+
+	resp, err := http.PostForm(server.TokenURL(), url.Values{"grant_type": {"password"}, "username": {"user"}, "password": {"pass"}})
+	if err != nil {
+		t.Fatalf("Failed to request token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	token := map[string]string{}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token["access_token"] == "" || token["instance_url"] != server.URL {
+		t.Fatalf("Unexpected token response: %v %v", token, err)
+	}
+
+	resources := map[string]string{}
+	req, _ := http.NewRequest("GET", server.URL+"/services/data/v52.0", nil)
+	req.Header.Set("Authorization", "Bearer "+token["access_token"])
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to get resources: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&resources); err != nil || resources["sobjects"] != "/services/data/v52.0/sobjects" {
+		t.Fatalf("Unexpected resources: %v %v", resources, err)
+	}
+
+	resp, err = http.PostForm(server.TokenURL(), url.Values{"grant_type": {"bogus"}})
+	if err != nil {
+		t.Fatalf("Failed to request token: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected unsupported grant type to fail, got %v", resp.Status)
+	}
+}
+
+func TestExpireSessions(t *testing.T) {
+	server := newTestServer(t)
+	token := server.AccessToken()
+	server.ExpireSessions()
+
+	req, _ := http.NewRequest("GET", server.URL+"/services/data/v36.0", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	errs := []map[string]string{}
+	json.NewDecoder(resp.Body).Decode(&errs)
+	if resp.StatusCode != http.StatusUnauthorized || len(errs) != 1 || errs[0]["errorCode"] != "INVALID_SESSION_ID" {
+		t.Fatalf("Expected expired session, got %v %v", resp.Status, errs)
+	}
+
+	if server.AccessToken() == token {
+		t.Fatal("Expected a new access token")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	server := newTestServer(t)
+
+	list := struct {
+		SObjects []struct {
+			Name string
+			URLs map[string]string
+		}
+	}{}
+	do(t, server, "GET", "/services/data/v36.0/sobjects", "", &list)
+	if len(list.SObjects) != 2 || list.SObjects[0].Name != "Account" || list.SObjects[0].URLs["rowTemplate"] != "/services/data/v36.0/sobjects/Account/{ID}" {
+		t.Fatalf("Unexpected sobjects: %+v", list)
+	}
+
+	description := struct {
+		Name   string
+		Fields []struct {
+			Name       string
+			Type       string
+			Updateable bool
+			ExternalId bool
+		}
+	}{}
+	do(t, server, "GET", "/services/data/v36.0/sobjects/Invoice__c/describe", "", &description)
+
+	fields := []string{}
+	for _, field := range description.Fields {
+		fields = append(fields, fmt.Sprintf("%v:%v:%v:%v", field.Name, field.Type, field.Updateable, field.ExternalId))
+	}
+	want := "Name:string:true:false,External_Id__c:string:true:true,Amount__c:currency:true:false,Due_Date__c:date:true:false," +
+		"Account__c:reference:true:false,Id:id:false:false,IsDeleted:boolean:false:false,CreatedDate:datetime:false:false," +
+		"CreatedById:reference:false:false,LastModifiedDate:datetime:false:false,LastModifiedById:reference:false:false," +
+		"SystemModstamp:datetime:false:false"
+	if description.Name != "Invoice__c" || strings.Join(fields, ",") != want {
+		t.Fatalf("Unexpected description: %v", fields)
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	server := newTestServer(t)
+	base := "/services/data/v36.0/sobjects/Account"
+
+	created := map[string]interface{}{}
+	if status := do(t, server, "POST", base, `{"attributes":{"type":"Account"},"Name":"Acme","NumberOfEmployees":5}`, &created); status != http.StatusCreated {
+		t.Fatalf("Failed to create: %v %v", status, created)
+	}
+	id, _ := created["id"].(string)
+	if !strings.HasPrefix(id, "001") || len(id) != 15 || created["success"] != true {
+		t.Fatalf("Unexpected create response: %v", created)
+	}
+
+	if status := do(t, server, "PATCH", base+"/"+id, `{"Industry":"Banking"}`, nil); status != http.StatusNoContent {
+		t.Fatalf("Failed to update: %v", status)
+	}
+
+	record := Record{}
+	do(t, server, "GET", base+"/"+id+"?fields=Name,Industry", "", &record)
+	if record["Name"] != "Acme" || record["Industry"] != "Banking" || record["NumberOfEmployees"] != nil || len(record) != 3 {
+		t.Fatalf("Unexpected record: %v", record)
+	}
+
+	full := Record{}
+	do(t, server, "GET", base+"/"+id, "", &full)
+	if full["NumberOfEmployees"] != float64(5) || full["CreatedDate"] == nil || full["IsDeleted"] != false {
+		t.Fatalf("Unexpected record: %v", full)
+	}
+
+	errs := []map[string]interface{}{}
+	if status := do(t, server, "PATCH", base+"/"+id, `{"Bogus__c":1}`, &errs); status != http.StatusBadRequest || errs[0]["errorCode"] != "INVALID_FIELD" {
+		t.Fatalf("Expected invalid field, got %v %v", status, errs)
+	}
+	if status := do(t, server, "PATCH", base+"/"+id, `{"CreatedDate":"2020-01-01"}`, &errs); status != http.StatusBadRequest || errs[0]["errorCode"] != "INVALID_FIELD_FOR_INSERT_UPDATE" {
+		t.Fatalf("Expected read-only field, got %v %v", status, errs)
+	}
+
+	if status := do(t, server, "DELETE", base+"/"+id, "", nil); status != http.StatusNoContent {
+		t.Fatalf("Failed to delete: %v", status)
+	}
+	if status := do(t, server, "GET", base+"/"+id, "", &errs); status != http.StatusNotFound || errs[0]["errorCode"] != "NOT_FOUND" {
+		t.Fatalf("Expected deleted record to be gone, got %v %v", status, errs)
+	}
+	if _, ok := server.Record("Account", id); ok {
+		t.Fatal("Expected deleted record to be gone")
+	}
+	if records := server.Records("Account"); len(records) != 1 || records[0]["IsDeleted"] != true {
+		t.Fatalf("Expected deleted record to be kept, got %v", records)
+	}
+}
+
+func TestExternalId(t *testing.T) {
+	server := newTestServer(t)
+	base := "/services/data/v36.0/sobjects/Invoice__c/External_Id__c/INV-1"
+
+	result := map[string]interface{}{}
+	if status := do(t, server, "PATCH", base, `{"Name":"First"}`, &result); status != http.StatusCreated || result["created"] != true {
+		t.Fatalf("Expected upsert to create, got %v %v", status, result)
+	}
+	id := result["id"]
+
+	result = map[string]interface{}{}
+	if status := do(t, server, "PATCH", base, `{"Name":"Second"}`, &result); status != http.StatusOK || result["created"] != false || result["id"] != id {
+		t.Fatalf("Expected upsert to update, got %v %v", status, result)
+	}
+
+	record := Record{}
+	do(t, server, "GET", base, "", &record)
+	if record["Name"] != "Second" || record["External_Id__c"] != "INV-1" {
+		t.Fatalf("Unexpected record: %v", record)
+	}
+
+	errs := []map[string]interface{}{}
+	if status := do(t, server, "GET", "/services/data/v36.0/sobjects/Invoice__c/Name/Second", "", &errs); status != http.StatusNotFound {
+		t.Fatalf("Expected non external id field to be rejected, got %v %v", status, errs)
+	}
+
+	server.Seed("Invoice__c", Record{"Name": "Duplicate", "External_Id__c": "INV-1"})
+	urls := []string{}
+	if status := do(t, server, "GET", base, "", &urls); status != http.StatusMultipleChoices || len(urls) != 2 {
+		t.Fatalf("Expected multiple choices, got %v %v", status, urls)
+	}
+
+	if status := do(t, server, "DELETE", "/services/data/v36.0/sobjects/Invoice__c/Id/"+id.(string), "", nil); status != http.StatusNoContent {
+		t.Fatalf("Failed to delete by id: %v", status)
+	}
+	if status := do(t, server, "DELETE", base, "", nil); status != http.StatusNoContent {
+		t.Fatalf("Failed to delete by external id: %v", status)
+	}
+}
@@ -0,0 +1,777 @@
+package forcetest
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The SOQL subset understood by the server:
+//
+//	SELECT field, Parent.field, ... | COUNT() FROM Object
+//	[WHERE condition] [ORDER BY field [ASC|DESC] [NULLS FIRST|LAST], ...]
+//	[LIMIT n] [OFFSET n]
+//
+// Conditions compare a field to a literal with =, !=, <>, <, <=, >, >=, LIKE,
+// IN or NOT IN and are combined with AND, OR, NOT and parentheses.
+
+// cursor holds the records of a query that did not fit in one page.
+type cursor struct {
+	records []Record
+	size    int
+}
+
+type soqlQuery struct {
+	fields  []string
+	count   bool
+	object  string
+	where   condition
+	orderBy []ordering
+	limit   int
+	offset  int
+}
+
+type ordering struct {
+	field      string
+	descending bool
+	nullsLast  bool
+}
+
+// condition is a WHERE clause evaluated against a record. lookup returns the
+// value at a field path and the field it belongs to.
+type condition interface {
+	match(lookup func(path string) (interface{}, *Field, error)) (bool, error)
+}
+
+type andCondition struct{ left, right condition }
+type orCondition struct{ left, right condition }
+type notCondition struct{ condition condition }
+
+type comparison struct {
+	field  string
+	op     string
+	values []interface{}
+}
+
+func (c andCondition) match(lookup func(string) (interface{}, *Field, error)) (bool, error) {
+	ok, err := c.left.match(lookup)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return c.right.match(lookup)
+}
+
+func (c orCondition) match(lookup func(string) (interface{}, *Field, error)) (bool, error) {
+	ok, err := c.left.match(lookup)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	return c.right.match(lookup)
+}
+
+func (c notCondition) match(lookup func(string) (interface{}, *Field, error)) (bool, error) {
+	ok, err := c.condition.match(lookup)
+	return !ok, err
+}
+
+func (c comparison) match(lookup func(string) (interface{}, *Field, error)) (bool, error) {
+	value, field, err := lookup(c.field)
+	if err != nil {
+		return false, err
+	}
+
+	switch c.op {
+	case "=":
+		return equalValues(field, value, c.values[0]), nil
+	case "!=":
+		return !equalValues(field, value, c.values[0]), nil
+	case "IN", "NOT IN":
+		in := false
+		for _, literal := range c.values {
+			if equalValues(field, value, literal) {
+				in = true
+				break
+			}
+		}
+		return in == (c.op == "IN"), nil
+	case "LIKE":
+		s, ok := value.(string)
+		pattern, _ := c.values[0].(string)
+		return ok && likePattern(pattern).MatchString(s), nil
+	}
+
+	if isNull(value) || c.values[0] == nil {
+		return false, nil
+	}
+
+	order := compareValues(field, value, c.values[0])
+	switch c.op {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+func isNull(value interface{}) bool {
+	return value == nil || value == ""
+}
+
+// equalValues compares a stored value to a literal. Strings compare
+// case-insensitively as in SOQL.
+func equalValues(field *Field, value, literal interface{}) bool {
+	if isNull(value) || isNull(literal) {
+		return isNull(value) && isNull(literal)
+	}
+
+	switch field.Type {
+	case "date", "datetime", "int", "double", "currency", "percent":
+		return compareValues(field, value, literal) == 0
+	}
+
+	if s, ok := value.(string); ok {
+		l, ok := literal.(string)
+		return ok && strings.EqualFold(s, l)
+	}
+
+	return fmt.Sprint(value) == fmt.Sprint(literal)
+}
+
+// compareValues orders a stored value and a literal according to the type
+// of field.
+func compareValues(field *Field, value, literal interface{}) int {
+	switch field.Type {
+	case "date", "datetime":
+		a, aok := parseTime(value)
+		b, bok := parseTime(literal)
+		if aok && bok {
+			switch {
+			case a.Before(b):
+				return -1
+			case a.After(b):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	a, aok := toFloat(value)
+	b, bok := toFloat(literal)
+	if aok && bok {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(strings.ToLower(fmt.Sprint(value)), strings.ToLower(fmt.Sprint(literal)))
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+var timeFormats = []string{timeFormat, time.RFC3339Nano, "2006-01-02T15:04:05.999Z0700", "2006-01-02"}
+
+func parseTime(value interface{}) (time.Time, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	for _, format := range timeFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// likePattern compiles a LIKE pattern, where % matches any characters and _
+// a single one, case-insensitively.
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.MustCompile(b.String())
+}
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	stringToken
+	symbolToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a query into words, quoted strings and the symbols
+// ( ) , = != <> < <= > >=.
+func tokenize(query string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						b.WriteRune('\n')
+					case 't':
+						b.WriteRune('\t')
+					case 'r':
+						b.WriteRune('\r')
+					default:
+						b.WriteRune(runes[i])
+					}
+					continue
+				}
+				b.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string literal")
+			}
+			i++
+			tokens = append(tokens, token{stringToken, b.String()})
+		case strings.ContainsRune("(),", r):
+			tokens = append(tokens, token{symbolToken, string(r)})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) {
+				switch two := op + string(runes[i+1]); two {
+				case "!=", "<>", "<=", ">=":
+					op = two
+				}
+			}
+			i += len(op)
+			switch op {
+			case "!":
+				return nil, fmt.Errorf("unexpected token: !")
+			case "<>":
+				op = "!="
+			}
+			tokens = append(tokens, token{symbolToken, op})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("(),=!<>'", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{wordToken, string(runes[start:i])})
+		}
+	}
+
+	return tokens, nil
+}
+
+type soqlParser struct {
+	tokens []token
+	pos    int
+}
+
+// parseSOQL parses a query in the subset the server understands.
+func parseSOQL(query string) (*soqlQuery, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &soqlParser{tokens: tokens}
+	q := &soqlQuery{limit: -1}
+
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if p.keyword("COUNT") {
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, err
+		}
+		q.count = true
+	} else {
+		for {
+			field, err := p.word()
+			if err != nil {
+				return nil, err
+			}
+			q.fields = append(q.fields, field)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	if q.object, err = p.word(); err != nil {
+		return nil, err
+	}
+
+	if p.keyword("WHERE") {
+		if q.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.keyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			field, err := p.word()
+			if err != nil {
+				return nil, err
+			}
+			order := ordering{field: field}
+			if p.keyword("DESC") {
+				order.descending = true
+			} else {
+				p.keyword("ASC")
+			}
+			order.nullsLast = order.descending
+			if p.keyword("NULLS") {
+				switch {
+				case p.keyword("FIRST"):
+					order.nullsLast = false
+				case p.keyword("LAST"):
+					order.nullsLast = true
+				default:
+					return nil, p.unexpected()
+				}
+			}
+			q.orderBy = append(q.orderBy, order)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("LIMIT") {
+		if q.limit, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("OFFSET") {
+		if q.offset, err = p.integer(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, p.unexpected()
+	}
+
+	return q, nil
+}
+
+func (p *soqlParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *soqlParser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *soqlParser) parseNot() (condition, error) {
+	if p.keyword("NOT") {
+		c, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{c}, nil
+	}
+
+	if p.symbol("(") {
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expectSymbol(")")
+	}
+
+	return p.parseComparison()
+}
+
+func (p *soqlParser) parseComparison() (condition, error) {
+	field, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+
+	c := comparison{field: field}
+	switch {
+	case p.keyword("LIKE"):
+		c.op = "LIKE"
+	case p.keyword("IN"):
+		c.op = "IN"
+	case p.keyword("NOT"):
+		if err := p.expectKeyword("IN"); err != nil {
+			return nil, err
+		}
+		c.op = "NOT IN"
+	default:
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != symbolToken || strings.ContainsAny(p.tokens[p.pos].text, "(),") {
+			return nil, p.unexpected()
+		}
+		c.op = p.tokens[p.pos].text
+		p.pos++
+	}
+
+	if c.op == "IN" || c.op == "NOT IN" {
+		if err := p.expectSymbol("("); err != nil {
+			return nil, err
+		}
+		for {
+			value, err := p.literal()
+			if err != nil {
+				return nil, err
+			}
+			c.values = append(c.values, value)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		return c, p.expectSymbol(")")
+	}
+
+	value, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+	c.values = []interface{}{value}
+
+	return c, nil
+}
+
+// literal parses a quoted string, number, boolean, null or date literal.
+func (p *soqlParser) literal() (interface{}, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, p.unexpected()
+	}
+
+	t := p.tokens[p.pos]
+	switch {
+	case t.kind == stringToken:
+		p.pos++
+		return t.text, nil
+	case t.kind != wordToken:
+		return nil, p.unexpected()
+	}
+
+	p.pos++
+	switch strings.ToUpper(t.text) {
+	case "NULL":
+		return nil, nil
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	}
+	if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return f, nil
+	}
+	if _, ok := parseTime(t.text); ok {
+		return t.text, nil
+	}
+
+	p.pos--
+	return nil, p.unexpected()
+}
+
+func (p *soqlParser) keyword(keyword string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == wordToken && strings.EqualFold(p.tokens[p.pos].text, keyword) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *soqlParser) expectKeyword(keyword string) error {
+	if !p.keyword(keyword) {
+		return p.unexpected()
+	}
+
+	return nil
+}
+
+func (p *soqlParser) symbol(symbol string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == symbolToken && p.tokens[p.pos].text == symbol {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *soqlParser) expectSymbol(symbol string) error {
+	if !p.symbol(symbol) {
+		return p.unexpected()
+	}
+
+	return nil
+}
+
+func (p *soqlParser) word() (string, error) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != wordToken {
+		return "", p.unexpected()
+	}
+	p.pos++
+
+	return p.tokens[p.pos-1].text, nil
+}
+
+func (p *soqlParser) integer() (int, error) {
+	word, err := p.word()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(word)
+	if err != nil || n < 0 {
+		p.pos--
+		return 0, p.unexpected()
+	}
+
+	return n, nil
+}
+
+func (p *soqlParser) unexpected() error {
+	if p.pos >= len(p.tokens) {
+		return fmt.Errorf("unexpected end of query")
+	}
+
+	return fmt.Errorf("unexpected token: %v", p.tokens[p.pos].text)
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request, base string, all bool) {
+	q, err := parseSOQL(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "MALFORMED_QUERY", err.Error())
+		return
+	}
+
+	object := s.objects[strings.ToLower(q.object)]
+	if object == nil {
+		writeError(w, http.StatusBadRequest, "INVALID_TYPE", fmt.Sprintf("sObject type '%v' is not supported.", q.object))
+		return
+	}
+
+	records, err := s.execute(object, q, all)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_FIELD", err.Error())
+		return
+	}
+
+	if q.count {
+		writeJSON(w, map[string]interface{}{"totalSize": len(records), "done": true, "records": []interface{}{}})
+		return
+	}
+
+	rendered := make([]Record, len(records))
+	for i, record := range records {
+		if rendered[i], err = s.render(base, object, record, q.fields); err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_FIELD", err.Error())
+			return
+		}
+	}
+
+	s.writePage(w, base, "", rendered, len(rendered), batchSize(r, s.queryBatchSize))
+}
+
+// serveQueryMore serves the page of a query starting at the offset in
+// locator, which is made of a cursor id and an offset.
+func (s *Server) serveQueryMore(w http.ResponseWriter, base, locator string) {
+	id, offsetText, _ := strings.Cut(locator, "-")
+	offset, err := strconv.Atoi(offsetText)
+	cursor, ok := s.cursors[id]
+	if err != nil || !ok || offset < 0 || offset > len(cursor.records) {
+		writeError(w, http.StatusBadRequest, "INVALID_QUERY_LOCATOR", "invalid query locator")
+		return
+	}
+
+	s.writePage(w, base, id, cursor.records[offset:], len(cursor.records), cursor.size)
+}
+
+// writePage writes up to size of records, the last of the total records of a
+// query, keeping the rest behind a cursor.
+func (s *Server) writePage(w http.ResponseWriter, base, id string, records []Record, total, size int) {
+	page := map[string]interface{}{"totalSize": total, "done": true}
+
+	if len(records) > size {
+		if id == "" {
+			s.ids++
+			id = fmt.Sprintf("01g%012d", s.ids)
+			s.cursors[id] = &cursor{records: records, size: size}
+		}
+		page["done"] = false
+		page["nextRecordsUrl"] = fmt.Sprintf("%v/query/%v-%d", base, id, total-len(records)+size)
+		records = records[:size]
+	}
+	page["records"] = records
+
+	writeJSON(w, page)
+}
+
+// batchSize returns the batch size requested with the Sforce-Query-Options
+// header, or fallback.
+func batchSize(r *http.Request, fallback int) int {
+	for _, option := range strings.Split(r.Header.Get("Sforce-Query-Options"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		if name != "batchSize" {
+			continue
+		}
+		if size, err := strconv.Atoi(value); err == nil && size > 0 {
+			return size
+		}
+	}
+
+	return fallback
+}
+
+// execute returns the records of object matching q, ordered and limited.
+func (s *Server) execute(object *sobject, q *soqlQuery, all bool) ([]Record, error) {
+	for _, field := range q.fields {
+		if _, _, err := s.value(object, Record{}, strings.Split(field, ".")); err != nil {
+			return nil, err
+		}
+	}
+
+	records := []Record{}
+	for _, id := range object.ids {
+		record := object.records[id]
+		if record["IsDeleted"] == true && !all {
+			continue
+		}
+
+		if q.where != nil {
+			ok, err := q.where.match(func(path string) (interface{}, *Field, error) {
+				return s.value(object, record, strings.Split(path, "."))
+			})
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	var sortErr error
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, order := range q.orderBy {
+			path := strings.Split(order.field, ".")
+			a, field, err := s.value(object, records[i], path)
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			b, _, _ := s.value(object, records[j], path)
+
+			if isNull(a) || isNull(b) {
+				if isNull(a) == isNull(b) {
+					continue
+				}
+				return isNull(a) != order.nullsLast
+			}
+
+			c := compareValues(field, a, b)
+			if c == 0 {
+				continue
+			}
+			return (c < 0) != order.descending
+		}
+		return false
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	if q.offset >= len(records) {
+		return []Record{}, nil
+	}
+	records = records[q.offset:]
+	if q.limit >= 0 && q.limit < len(records) {
+		records = records[:q.limit]
+	}
+
+	return records, nil
+}
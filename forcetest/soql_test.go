@@ -0,0 +1,168 @@
+package forcetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type queryPage struct {
+	TotalSize      int
+	Done           bool
+	NextRecordsUrl string
+	Records        []Record
+}
+
+func query(t *testing.T, server *Server, resource, soql string) (*queryPage, []map[string]interface{}) {
+	body := json.RawMessage{}
+	status := do(t, server, "GET", "/services/data/v36.0/"+resource+"?q="+url.QueryEscape(soql), "", &body)
+
+	if status != http.StatusOK {
+		errs := []map[string]interface{}{}
+		if err := json.Unmarshal(body, &errs); err != nil {
+			t.Fatalf("Failed to decode errors: %v", err)
+		}
+		return nil, errs
+	}
+
+	page := &queryPage{}
+	if err := json.Unmarshal(body, page); err != nil {
+		t.Fatalf("Failed to decode page: %v", err)
+	}
+
+	return page, nil
+}
+
+func names(page *queryPage) string {
+	names := []string{}
+	for _, record := range page.Records {
+		name, _ := record["Name"].(string)
+		names = append(names, name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+func seedInvoices(t *testing.T) *Server {
+	server := newTestServer(t)
+	accounts := server.Seed("Account",
+		Record{"Name": "Acme", "Industry": "Banking", "NumberOfEmployees": 10},
+		Record{"Name": "Globex", "Industry": "Retail"},
+	)
+	server.Seed("Invoice__c",
+		Record{"Name": "INV-1", "Amount__c": 100, "Due_Date__c": "2026-01-15", "Account__c": accounts[0]},
+		Record{"Name": "INV-2", "Amount__c": 250.5, "Due_Date__c": "2026-03-01", "Account__c": accounts[1]},
+		Record{"Name": "INV-3", "Due_Date__c": "2026-02-01", "Account__c": accounts[0]},
+		Record{"Name": "O'Brien", "Amount__c": 5},
+	)
+
+	return server
+}
+
+func TestQuery(t *testing.T) {
+	server := seedInvoices(t)
+
+	for _, test := range []struct {
+		soql string
+		want string
+	}{
+		{"SELECT Name FROM Invoice__c", "INV-1,INV-2,INV-3,O'Brien"},
+		{"select name from invoice__c where amount__c > 50 order by amount__c desc", "INV-2,INV-1"},
+		{"SELECT Name FROM Invoice__c WHERE Amount__c = null", "INV-3"},
+		{"SELECT Name FROM Invoice__c WHERE Amount__c != null AND (Amount__c < 10 OR Amount__c >= 250.5)", "INV-2,O'Brien"},
+		{"SELECT Name FROM Invoice__c WHERE Name LIKE 'inv-%' AND NOT Name = 'INV-2'", "INV-1,INV-3"},
+		{"SELECT Name FROM Invoice__c WHERE Name = 'O\\'Brien'", "O'Brien"},
+		{"SELECT Name FROM Invoice__c WHERE Name IN ('INV-1', 'INV-3') ORDER BY Name DESC", "INV-3,INV-1"},
+		{"SELECT Name FROM Invoice__c WHERE Name NOT IN ('INV-1', 'INV-3')", "INV-2,O'Brien"},
+		{"SELECT Name FROM Invoice__c WHERE Due_Date__c > 2026-01-31 ORDER BY Due_Date__c", "INV-3,INV-2"},
+		{"SELECT Name FROM Invoice__c WHERE Account__r.Industry = 'banking' ORDER BY Name", "INV-1,INV-3"},
+		{"SELECT Name FROM Invoice__c ORDER BY Amount__c NULLS LAST LIMIT 2 OFFSET 1", "INV-1,INV-2"},
+		{"SELECT Name FROM Invoice__c ORDER BY Amount__c LIMIT 1", "INV-3"},
+	} {
+		page, errs := query(t, server, "query", test.soql)
+		if page == nil {
+			t.Fatalf("Failed to query %v: %v", test.soql, errs)
+		}
+		if got := names(page); got != test.want || page.TotalSize != len(page.Records) || !page.Done {
+			t.Errorf("%v: expected %v, got %v", test.soql, test.want, got)
+		}
+	}
+}
+
+func TestQueryRelationships(t *testing.T) {
+	server := seedInvoices(t)
+
+	page, errs := query(t, server, "query", "SELECT Name, Account__r.Name, Account__r.NumberOfEmployees FROM Invoice__c WHERE Name IN ('INV-1', 'O\\'Brien')")
+	if page == nil || len(page.Records) != 2 {
+		t.Fatalf("Failed to query: %v %v", page, errs)
+	}
+
+	account, ok := page.Records[0]["Account__r"].(map[string]interface{})
+	if !ok || account["Name"] != "Acme" || account["NumberOfEmployees"] != float64(10) {
+		t.Fatalf("Unexpected parent: %v", page.Records[0])
+	}
+	if attributes, _ := account["attributes"].(map[string]interface{}); attributes["type"] != "Account" {
+		t.Fatalf("Unexpected parent attributes: %v", account)
+	}
+	if parent, ok := page.Records[1]["Account__r"]; !ok || parent != nil {
+		t.Fatalf("Expected null parent, got %v", page.Records[1])
+	}
+}
+
+func TestQueryAll(t *testing.T) {
+	server := seedInvoices(t)
+	page, _ := query(t, server, "query", "SELECT Id FROM Invoice__c WHERE Name = 'INV-1'")
+	id := page.Records[0]["Id"].(string)
+	do(t, server, "DELETE", "/services/data/v36.0/sobjects/Invoice__c/"+id, "", nil)
+
+	if page, _ := query(t, server, "query", "SELECT COUNT() FROM Invoice__c"); page.TotalSize != 3 || len(page.Records) != 0 {
+		t.Fatalf("Expected deleted record to be excluded, got %+v", page)
+	}
+	if page, _ := query(t, server, "queryAll", "SELECT Name, IsDeleted FROM Invoice__c WHERE IsDeleted = true"); names(page) != "INV-1" {
+		t.Fatalf("Expected deleted record to be included, got %+v", page)
+	}
+}
+
+func TestQueryMore(t *testing.T) {
+	server := seedInvoices(t)
+	server.SetQueryBatchSize(3)
+
+	page, _ := query(t, server, "query", "SELECT Name FROM Invoice__c")
+	if page.Done || page.TotalSize != 4 || names(page) != "INV-1,INV-2,INV-3" || page.NextRecordsUrl == "" {
+		t.Fatalf("Unexpected first page: %+v", page)
+	}
+
+	next := &queryPage{}
+	do(t, server, "GET", page.NextRecordsUrl, "", next)
+	if !next.Done || next.TotalSize != 4 || names(next) != "O'Brien" {
+		t.Fatalf("Unexpected last page: %+v", next)
+	}
+
+	errs := []map[string]interface{}{}
+	if status := do(t, server, "GET", "/services/data/v36.0/query/01gbogus-3", "", &errs); status != http.StatusBadRequest || errs[0]["errorCode"] != "INVALID_QUERY_LOCATOR" {
+		t.Fatalf("Expected invalid locator, got %v %v", status, errs)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	server := seedInvoices(t)
+
+	for soql, code := range map[string]string{
+		"SELECT Name FROM":                                   "MALFORMED_QUERY",
+		"SELECT Name FROM Invoice__c WHERE":                  "MALFORMED_QUERY",
+		"SELECT Name FROM Invoice__c WHERE Name = 'open":     "MALFORMED_QUERY",
+		"SELECT Name FROM Invoice__c GROUP BY Name":          "MALFORMED_QUERY",
+		"SELECT Name FROM Nothing__c":                        "INVALID_TYPE",
+		"SELECT Bogus__c FROM Invoice__c":                    "INVALID_FIELD",
+		"SELECT Account__r.Bogus__c FROM Invoice__c":         "INVALID_FIELD",
+		"SELECT Name FROM Invoice__c WHERE Bogus__c = 'x'":   "INVALID_FIELD",
+		"SELECT Name FROM Invoice__c ORDER BY Bogus__c DESC": "INVALID_FIELD",
+	} {
+		page, errs := query(t, server, "query", soql)
+		if page != nil || len(errs) != 1 || errs[0]["errorCode"] != code {
+			t.Errorf("%v: expected %v, got %+v %v", soql, code, page, errs)
+		}
+	}
+}
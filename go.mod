@@ -1,5 +1,5 @@
 module github.com/nimajalali/go-force
 
-go 1.13
+go 1.18
 
 require github.com/biter777/countries v1.6.5